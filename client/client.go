@@ -0,0 +1,584 @@
+// Package client talks to the snippet manager HTTP API. It is used by the
+// snippet command line tool and its terminal UI.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// maxPageSize is the largest page the server returns
+const maxPageSize = 100
+
+// refreshMargin is how long before the access token expires the client
+// renews it
+const refreshMargin = 30 * time.Second
+
+// Client is an API client for one server. Token is sent as a bearer token
+// when set. Workspace, when set, makes requests work on the library of that
+// workspace instead of the user's.
+//
+// When RefreshToken and TokenExpiry are set, the client renews the tokens
+// shortly before the access token expires and calls OnRefresh, if set, with
+// the new ones.
+type Client struct {
+	BaseURL      string
+	Token        string
+	RefreshToken string
+	TokenExpiry  time.Time
+	OnRefresh    func(Tokens)
+	Workspace    uuid.UUID
+	HTTP         *http.Client
+}
+
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is returned for responses with a non-2xx status. Message is the
+// plain text body written by the server.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Message
+}
+
+// IsStatus reports whether err is an API error with the given status code
+func IsStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// Tokens are the tokens of a session: the access token, the time it expires
+// and the refresh token that renews it
+type Tokens struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// LoginResponse is the result of a successful login
+type LoginResponse struct {
+	Tokens
+	User models.User `json:"user"`
+}
+
+func (c *Client) Login(username, password string) (LoginResponse, error) {
+	credentials := map[string]string{"username": username, "password": password}
+	var login LoginResponse
+	err := c.do(http.MethodPost, "/login", nil, credentials, &login)
+	return login, err
+}
+
+// Refresh exchanges the refresh token for new tokens and starts using them
+func (c *Client) Refresh() (Tokens, error) {
+	var tokens Tokens
+	body := map[string]string{"refresh_token": c.RefreshToken}
+	if err := c.do(http.MethodPost, "/token/refresh", nil, body, &tokens); err != nil {
+		return Tokens{}, err
+	}
+	c.Token = tokens.Token
+	c.RefreshToken = tokens.RefreshToken
+	c.TokenExpiry = tokens.ExpiresAt
+	if c.OnRefresh != nil {
+		c.OnRefresh(tokens)
+	}
+	return tokens, nil
+}
+
+// Logout ends the session of the token, or every session of the user if all
+// is set
+func (c *Client) Logout(all bool) error {
+	query := url.Values{}
+	if all {
+		query.Set("all", "true")
+	}
+	return c.do(http.MethodPost, "/logout", query, nil, nil)
+}
+
+// Sessions returns the sessions of the user, most recently used first
+func (c *Client) Sessions() ([]models.Session, error) {
+	var sessions []models.Session
+	err := c.do(http.MethodGet, "/sessions", nil, nil, &sessions)
+	return sessions, err
+}
+
+// DeleteSession ends a session of the user
+func (c *Client) DeleteSession(id uuid.UUID) error {
+	return c.do(http.MethodDelete, "/sessions/"+id.String(), nil, nil, nil)
+}
+
+// ListParams selects a page of snippets; see GET /snippets
+type ListParams struct {
+	Limit    int
+	Cursor   string
+	Sort     string
+	Order    string
+	Language string
+	Tags     []string
+	AnyTag   bool
+	FolderID *uuid.UUID
+}
+
+func (p ListParams) values() url.Values {
+	v := url.Values{}
+	if p.Limit > 0 {
+		v.Set("limit", strconv.Itoa(p.Limit))
+	}
+	setIf(v, "cursor", p.Cursor)
+	setIf(v, "sort", p.Sort)
+	setIf(v, "order", p.Order)
+	setIf(v, "language", p.Language)
+	for _, tag := range p.Tags {
+		v.Add("tag", tag)
+	}
+	if p.AnyTag {
+		v.Set("tag_mode", "any")
+	}
+	if p.FolderID != nil {
+		v.Set("folder_id", p.FolderID.String())
+	}
+	return v
+}
+
+func (c *Client) ListSnippets(params ListParams) (models.SnippetPage, error) {
+	var page models.SnippetPage
+	err := c.do(http.MethodGet, "/snippets", params.values(), nil, &page)
+	return page, err
+}
+
+// AllSnippets follows cursors to return every snippet matching params
+func (c *Client) AllSnippets(params ListParams) ([]models.Snippet, error) {
+	params.Limit = maxPageSize
+	var snippets []models.Snippet
+	for {
+		page, err := c.ListSnippets(params)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, page.Snippets...)
+		if page.NextCursor == "" {
+			return snippets, nil
+		}
+		params.Cursor = page.NextCursor
+	}
+}
+
+// SearchParams describes a full-text search; see GET /snippets/search
+type SearchParams struct {
+	Query    string
+	Language string
+	Tags     []string
+	FolderID *uuid.UUID
+	Limit    int
+}
+
+func (c *Client) Search(params SearchParams) ([]models.SearchResult, error) {
+	v := url.Values{"q": {params.Query}}
+	setIf(v, "language", params.Language)
+	for _, tag := range params.Tags {
+		v.Add("tag", tag)
+	}
+	if params.FolderID != nil {
+		v.Set("folder_id", params.FolderID.String())
+	}
+	if params.Limit > 0 {
+		v.Set("limit", strconv.Itoa(params.Limit))
+	}
+
+	var results []models.SearchResult
+	err := c.do(http.MethodGet, "/snippets/search", v, nil, &results)
+	return results, err
+}
+
+func (c *Client) GetSnippet(id uuid.UUID) (models.Snippet, error) {
+	var snippet models.Snippet
+	err := c.do(http.MethodGet, "/snippets/"+id.String(), nil, nil, &snippet)
+	return snippet, err
+}
+
+// SnippetCode returns the code of a snippet as stored, without the rest of
+// the snippet. The caller must close the returned reader.
+func (c *Client) SnippetCode(id uuid.UUID) (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, "/snippets/"+id.String()+"/raw", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// RenderOptions select the output of RenderSnippet. Format is html or ansi
+// and Theme is empty for the default theme.
+type RenderOptions struct {
+	Format      string
+	Theme       string
+	LineNumbers bool
+}
+
+// RenderSnippet returns the code of a snippet highlighted by the server
+func (c *Client) RenderSnippet(id uuid.UUID, opts RenderOptions) (string, error) {
+	query := url.Values{}
+	setIf(query, "format", opts.Format)
+	setIf(query, "theme", opts.Theme)
+	if opts.LineNumbers {
+		query.Set("line_numbers", "true")
+	}
+	resp, err := c.request(http.MethodGet, "/snippets/"+id.String()+"/render", query, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func (c *Client) CreateSnippet(snippet models.Snippet) (models.Snippet, error) {
+	var created models.Snippet
+	err := c.do(http.MethodPost, "/snippets", nil, snippet, &created)
+	return created, err
+}
+
+func (c *Client) UpdateSnippet(snippet models.Snippet) (models.Snippet, error) {
+	var updated models.Snippet
+	err := c.do(http.MethodPut, "/snippets/"+snippet.ID.String(), nil, snippet, &updated)
+	return updated, err
+}
+
+func (c *Client) DeleteSnippet(id uuid.UUID) error {
+	return c.do(http.MethodDelete, "/snippets/"+id.String(), nil, nil, nil)
+}
+
+func (c *Client) AddTag(snippetID uuid.UUID, tag string) error {
+	return c.do(http.MethodPost, tagPath(snippetID, tag), nil, nil, nil)
+}
+
+func (c *Client) RemoveTag(snippetID uuid.UUID, tag string) error {
+	return c.do(http.MethodDelete, tagPath(snippetID, tag), nil, nil, nil)
+}
+
+// Tags returns the caller's tags with the number of snippets carrying each
+func (c *Client) Tags() ([]models.Tag, error) {
+	var tags []models.Tag
+	err := c.do(http.MethodGet, "/tags", nil, nil, &tags)
+	return tags, err
+}
+
+// Languages returns the known languages and those the caller uses, with the
+// number of snippets in each. usedOnly leaves out the languages the caller
+// does not use.
+func (c *Client) Languages(usedOnly bool) ([]models.Language, error) {
+	query := url.Values{}
+	if usedOnly {
+		query.Set("used", "true")
+	}
+	var list []models.Language
+	err := c.do(http.MethodGet, "/languages", query, nil, &list)
+	return list, err
+}
+
+func (c *Client) RenameTag(name, newName string) error {
+	return c.do(http.MethodPatch, "/tags/"+url.PathEscape(name), nil, map[string]string{"name": newName}, nil)
+}
+
+// MergeTags replaces the source tag with the target tag on every snippet
+func (c *Client) MergeTags(source, target string) error {
+	return c.do(http.MethodPost, "/tags/merge", nil, map[string]string{"source": source, "target": target}, nil)
+}
+
+// DeleteTag removes a tag from every snippet
+func (c *Client) DeleteTag(name string) error {
+	return c.do(http.MethodDelete, "/tags/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// Folders returns every folder of the given user, who must be the one the
+// token was issued to
+func (c *Client) Folders(userID uuid.UUID) ([]models.Folder, error) {
+	var folders []models.Folder
+	err := c.do(http.MethodGet, "/folders/user/"+userID.String(), nil, nil, &folders)
+	return folders, err
+}
+
+// FolderTree returns the caller's folders nested by parent, down to depth
+// levels if depth is positive
+func (c *Client) FolderTree(depth int, withSnippets bool) ([]models.FolderNode, error) {
+	query := url.Values{}
+	if depth > 0 {
+		query.Set("depth", strconv.Itoa(depth))
+	}
+	if withSnippets {
+		query.Set("snippets", "true")
+	}
+	var tree []models.FolderNode
+	err := c.do(http.MethodGet, "/folders/tree", query, nil, &tree)
+	return tree, err
+}
+
+// FolderPath returns the folders from the top level down to the given one
+func (c *Client) FolderPath(id uuid.UUID) ([]models.Folder, error) {
+	var path []models.Folder
+	err := c.do(http.MethodGet, "/folders/"+id.String()+"/breadcrumbs", nil, nil, &path)
+	return path, err
+}
+
+func (c *Client) FolderContents(id uuid.UUID) (models.FolderContents, error) {
+	var contents models.FolderContents
+	err := c.do(http.MethodGet, "/folders", url.Values{"id": {id.String()}}, nil, &contents)
+	return contents, err
+}
+
+func (c *Client) CreateFolder(name string, parentID *uuid.UUID) (models.Folder, error) {
+	var folder models.Folder
+	err := c.do(http.MethodPost, "/folders", nil, models.Folder{Name: name, ParentID: parentID}, &folder)
+	return folder, err
+}
+
+func (c *Client) RenameFolder(id uuid.UUID, name string) (models.Folder, error) {
+	var folder models.Folder
+	err := c.do(http.MethodPatch, "/folders/"+id.String(), nil, map[string]string{"name": name}, &folder)
+	return folder, err
+}
+
+// MoveFolder moves a folder under parentID, or to the root if it is nil
+func (c *Client) MoveFolder(id uuid.UUID, parentID *uuid.UUID) (models.Folder, error) {
+	var folder models.Folder
+	err := c.do(http.MethodPatch, "/folders/"+id.String(), nil, map[string]*uuid.UUID{"parent_id": parentID}, &folder)
+	return folder, err
+}
+
+// DeleteFolder deletes a folder. Its snippets and subfolders are deleted
+// with it if deleteContents is set, and moved to its parent otherwise.
+func (c *Client) DeleteFolder(id uuid.UUID, deleteContents bool) error {
+	contents := "move"
+	if deleteContents {
+		contents = "delete"
+	}
+	return c.do(http.MethodDelete, "/folders/"+id.String(), url.Values{"contents": {contents}}, nil, nil)
+}
+
+func (c *Client) FolderShares(id uuid.UUID) ([]models.FolderShare, error) {
+	var shares []models.FolderShare
+	err := c.do(http.MethodGet, "/folders/"+id.String()+"/shares", nil, nil, &shares)
+	return shares, err
+}
+
+// ShareFolder shares a folder with the user with the given username or
+// email, replacing any earlier permission of that user
+func (c *Client) ShareFolder(id uuid.UUID, user string, permission models.Permission) (models.FolderShare, error) {
+	var share models.FolderShare
+	body := map[string]any{"user": user, "permission": permission}
+	err := c.do(http.MethodPost, "/folders/"+id.String()+"/shares", nil, body, &share)
+	return share, err
+}
+
+func (c *Client) UnshareFolder(id, userID uuid.UUID) error {
+	return c.do(http.MethodDelete, "/folders/"+id.String()+"/shares/"+userID.String(), nil, nil, nil)
+}
+
+// SharedFolders lists the folders other users shared with the caller
+func (c *Client) SharedFolders() ([]models.IncomingShare, error) {
+	var shares []models.IncomingShare
+	err := c.do(http.MethodGet, "/folders/shared", nil, nil, &shares)
+	return shares, err
+}
+
+// Export downloads the caller's library as an archive in the given format,
+// json or tar.gz. The caller must close the returned reader.
+func (c *Client) Export(format string) (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, "/export", url.Values{"format": {format}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ExportFolder downloads a folder and everything below it as a tar.gz of
+// source files or as Markdown, for format files or markdown. The caller must
+// close the returned reader.
+func (c *Client) ExportFolder(id uuid.UUID, format string) (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, "/folders/"+id.String()+"/export", url.Values{"format": {format}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Import uploads an archive made by Export. conflict is skip, overwrite or
+// duplicate.
+func (c *Client) Import(archive io.Reader, conflict string, newIDs bool) (models.ImportResult, error) {
+	query := url.Values{"conflict": {conflict}}
+	if newIDs {
+		query.Set("new_ids", "true")
+	}
+	var result models.ImportResult
+	err := c.do(http.MethodPost, "/import", query, archive, &result)
+	return result, err
+}
+
+// ImportDirectory uploads a zip, tar or tar.gz archive of source files, each
+// of which becomes a snippet
+func (c *Client) ImportDirectory(archive io.Reader) (models.DirectoryImportResult, error) {
+	var result models.DirectoryImportResult
+	err := c.do(http.MethodPost, "/import/directory", nil, archive, &result)
+	return result, err
+}
+
+// CreateShareLink creates a link giving anonymous access to the snippet or
+// folder set in link. The returned link holds the token, which the server
+// does not return again.
+func (c *Client) CreateShareLink(link models.ShareLink) (models.ShareLink, error) {
+	var created models.ShareLink
+	err := c.do(http.MethodPost, "/share-links", nil, link, &created)
+	return created, err
+}
+
+// ShareLinks lists the caller's active share links
+func (c *Client) ShareLinks() ([]models.ShareLink, error) {
+	var links []models.ShareLink
+	err := c.do(http.MethodGet, "/share-links", nil, nil, &links)
+	return links, err
+}
+
+func (c *Client) DeleteShareLink(id uuid.UUID) error {
+	return c.do(http.MethodDelete, "/share-links/"+id.String(), nil, nil, nil)
+}
+
+// Workspaces lists the workspaces the user is a member of
+func (c *Client) Workspaces() ([]models.Workspace, error) {
+	var workspaces []models.Workspace
+	err := c.do(http.MethodGet, "/workspaces", nil, nil, &workspaces)
+	return workspaces, err
+}
+
+func (c *Client) CreateWorkspace(name string) (models.Workspace, error) {
+	var workspace models.Workspace
+	err := c.do(http.MethodPost, "/workspaces", nil, models.Workspace{Name: name}, &workspace)
+	return workspace, err
+}
+
+// DeleteWorkspace deletes a workspace with all its folders and snippets
+func (c *Client) DeleteWorkspace(id uuid.UUID) error {
+	return c.do(http.MethodDelete, "/workspaces/"+id.String(), nil, nil, nil)
+}
+
+func (c *Client) WorkspaceMembers(id uuid.UUID) ([]models.WorkspaceMember, error) {
+	var members []models.WorkspaceMember
+	err := c.do(http.MethodGet, "/workspaces/"+id.String()+"/members", nil, nil, &members)
+	return members, err
+}
+
+// AddWorkspaceMember adds the user with the given username or email to a
+// workspace
+func (c *Client) AddWorkspaceMember(id uuid.UUID, user string, role models.Role) (models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	body := map[string]any{"user": user, "role": role}
+	err := c.do(http.MethodPost, "/workspaces/"+id.String()+"/members", nil, body, &member)
+	return member, err
+}
+
+func (c *Client) SetWorkspaceRole(id, userID uuid.UUID, role models.Role) error {
+	body := map[string]models.Role{"role": role}
+	return c.do(http.MethodPatch, "/workspaces/"+id.String()+"/members/"+userID.String(), nil, body, nil)
+}
+
+func (c *Client) RemoveWorkspaceMember(id, userID uuid.UUID) error {
+	return c.do(http.MethodDelete, "/workspaces/"+id.String()+"/members/"+userID.String(), nil, nil, nil)
+}
+
+// do sends a request and decodes a JSON response into out, if not nil
+func (c *Client) do(method, path string, query url.Values, body, out any) error {
+	resp, err := c.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// request sends a request and returns the response if its status is 2xx
+func (c *Client) request(method, path string, query url.Values, body any) (*http.Response, error) {
+	if c.RefreshToken != "" && !c.TokenExpiry.IsZero() && path != "/token/refresh" &&
+		time.Until(c.TokenExpiry) < refreshMargin {
+		if _, err := c.Refresh(); err != nil {
+			return nil, fmt.Errorf("refresh token: %w", err)
+		}
+	}
+
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	// A reader is sent as is, anything else as JSON
+	reader, raw := body.(io.Reader)
+	if body != nil && !raw {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && !raw {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.Workspace != uuid.Nil {
+		req.Header.Set("X-Workspace-ID", c.Workspace.String())
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+func tagPath(snippetID uuid.UUID, tag string) string {
+	return "/tags/" + snippetID.String() + "/" + url.PathEscape(tag)
+}
+
+func setIf(v url.Values, key, value string) {
+	if value != "" {
+		v.Set(key, value)
+	}
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// EditorCommand returns the command that opens path in the user's editor:
+// $VISUAL, then $EDITOR, then vi. The variables may carry arguments, as in
+// "code --wait".
+func EditorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	args = append(args, path)
+	return exec.Command(args[0], args[1:]...)
+}
@@ -0,0 +1,1570 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+
+	"snippet-manager-go/client"
+	"snippet-manager-go/languages"
+	"snippet-manager-go/models"
+	"snippet-manager-go/tui"
+)
+
+var loginCommand = &cli.Command{
+	Name:  "login",
+	Usage: "log in and save the session token",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "username", Aliases: []string{"u"}, Usage: "account name (prompted if missing)"},
+		&cli.BoolFlag{Name: "password-stdin", Usage: "read the password from standard input"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := newSession(c)
+		if err != nil {
+			return err
+		}
+		if err := s.login(c.String("username"), c.Bool("password-stdin")); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.App.Writer, "Logged in to %s as %s\n", s.client.BaseURL, s.cfg.Username)
+		return nil
+	},
+}
+
+// login prompts for the credentials that were not given, logs in and saves
+// the token
+func (s *session) login(username string, passwordStdin bool) error {
+	in := bufio.NewReader(os.Stdin)
+
+	var err error
+	if username == "" {
+		fmt.Fprint(os.Stderr, "Username: ")
+		if username, err = readLine(in); err != nil {
+			return err
+		}
+	}
+
+	var password string
+	if !passwordStdin && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		raw, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return err
+		}
+		password = string(raw)
+	} else if password, err = readLine(in); err != nil {
+		return err
+	}
+
+	login, err := s.client.Login(username, password)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	s.client.Token = login.Token
+	s.client.RefreshToken = login.RefreshToken
+	s.client.TokenExpiry = login.ExpiresAt
+	s.cfg.Server = s.client.BaseURL
+	s.cfg.Username = username
+	s.cfg.UserID = login.User.ID
+	s.cfg.setTokens(login.Tokens)
+	return s.cfg.save(s.path)
+}
+
+var logoutCommand = &cli.Command{
+	Name:  "logout",
+	Usage: "end the session on the server and forget its tokens",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "all", Usage: "end every session of the account, on all devices"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := newSession(c)
+		if err != nil {
+			return err
+		}
+		if s.client.Token != "" {
+			// A session the server already ended needs no logging out
+			if err := s.client.Logout(c.Bool("all")); err != nil && !client.IsStatus(err, http.StatusUnauthorized) {
+				return err
+			}
+		}
+		s.cfg.setTokens(client.Tokens{})
+		return s.cfg.save(s.path)
+	},
+}
+
+var sessionCommand = &cli.Command{
+	Name:  "session",
+	Usage: "manage the devices logged in to the account",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "ls",
+			Usage: "list the active sessions, most recently used first",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				sessions, err := s.client.Sessions()
+				if err != nil {
+					return err
+				}
+				printSessions(c.App.Writer, sessions)
+				return nil
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "end a session, logging its device out",
+			ArgsUsage: "ID",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				id, err := s.resolveSession(c.Args().First())
+				if err != nil {
+					return err
+				}
+				return s.client.DeleteSession(id)
+			},
+		},
+	},
+}
+
+// resolveSession accepts a full session ID or a unique prefix of one, as
+// printed by session ls
+func (s *session) resolveSession(arg string) (uuid.UUID, error) {
+	if arg == "" {
+		return uuid.Nil, errors.New("expected a session ID")
+	}
+	if id, err := uuid.Parse(arg); err == nil {
+		return id, nil
+	}
+	sessions, err := s.client.Sessions()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var matches []uuid.UUID
+	for _, session := range sessions {
+		if strings.HasPrefix(session.ID.String(), arg) {
+			matches = append(matches, session.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return uuid.Nil, fmt.Errorf("no session matches %q", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return uuid.Nil, fmt.Errorf("%q matches %d sessions", arg, len(matches))
+	}
+}
+
+var addCommand = &cli.Command{
+	Name:      "add",
+	Usage:     "create a snippet from a file or standard input",
+	ArgsUsage: "[FILE]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "title", Aliases: []string{"t"}, Usage: "snippet title (default: the file name)"},
+		&cli.StringFlag{Name: "desc", Aliases: []string{"d"}, Usage: "snippet description"},
+		&cli.StringFlag{Name: "lang", Aliases: []string{"l"}, Usage: "language (default: guessed from the file name and code)"},
+		&cli.StringSliceFlag{Name: "tag", Usage: "tag to attach; may be repeated"},
+		&cli.StringFlag{Name: "folder", Aliases: []string{"f"}, Usage: "folder name or ID"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+		if c.NArg() > 1 {
+			return errors.New("add takes at most one file")
+		}
+
+		file := c.Args().First()
+		var code []byte
+		if file == "" || file == "-" {
+			file = ""
+			code, err = io.ReadAll(os.Stdin)
+		} else {
+			code, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return err
+		}
+
+		snippet := models.Snippet{
+			Title:       c.String("title"),
+			Description: c.String("desc"),
+			Language:    c.String("lang"),
+			Code:        string(code),
+			Tags:        c.StringSlice("tag"),
+		}
+		if snippet.Title == "" {
+			if file == "" {
+				return errors.New("--title is required when reading from standard input")
+			}
+			snippet.Title = filepath.Base(file)
+		}
+		// The server guesses from the code alone, so the file name is
+		// tried here first
+		if snippet.Language == "" {
+			snippet.Language = languages.Detect(file, snippet.Code)
+		}
+		if folder := c.String("folder"); folder != "" {
+			folderID, err := s.resolveFolder(folder)
+			if err != nil {
+				return err
+			}
+			snippet.FolderID = &folderID
+		}
+
+		created, err := s.client.CreateSnippet(snippet)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.App.Writer, created.ID)
+		return nil
+	},
+}
+
+var lsCommand = &cli.Command{
+	Name:  "ls",
+	Usage: "list snippets",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "lang", Aliases: []string{"l"}, Usage: "only snippets in this language"},
+		&cli.StringSliceFlag{Name: "tag", Usage: "only snippets with this tag; may be repeated"},
+		&cli.BoolFlag{Name: "any-tag", Usage: "match snippets with any of the tags instead of all"},
+		&cli.StringFlag{Name: "folder", Aliases: []string{"f"}, Usage: "only snippets in this folder (name or ID)"},
+		&cli.StringFlag{Name: "sort", Usage: "created_at, updated_at or title"},
+		&cli.BoolFlag{Name: "reverse", Aliases: []string{"r"}, Usage: "reverse the sort order"},
+		&cli.IntFlag{Name: "limit", Aliases: []string{"n"}, Value: 50, Usage: "maximum number of snippets; 0 lists all"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+
+		params := client.ListParams{
+			Sort:     c.String("sort"),
+			Language: c.String("lang"),
+			Tags:     c.StringSlice("tag"),
+			AnyTag:   c.Bool("any-tag"),
+		}
+		if c.Bool("reverse") {
+			// Titles default to ascending and timestamps to descending
+			params.Order = "asc"
+			if params.Sort == "title" {
+				params.Order = "desc"
+			}
+		}
+		if folder := c.String("folder"); folder != "" {
+			folderID, err := s.resolveFolder(folder)
+			if err != nil {
+				return err
+			}
+			params.FolderID = &folderID
+		}
+
+		snippets, err := s.listSnippets(params, c.Int("limit"))
+		if err != nil {
+			return err
+		}
+		printSnippets(c.App.Writer, snippets)
+		return nil
+	},
+}
+
+var showCommand = &cli.Command{
+	Name:      "show",
+	Usage:     "print a snippet",
+	ArgsUsage: "ID",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "code", Aliases: []string{"c"}, Usage: "print only the code"},
+		&cli.BoolFlag{Name: "color", Usage: "highlight the code"},
+		&cli.StringFlag{Name: "theme", Usage: "highlighting theme, with --color"},
+		&cli.BoolFlag{Name: "line-numbers", Aliases: []string{"n"}, Usage: "number the lines of code, with --color"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+		id, err := s.resolveSnippet(c.Args().First())
+		if err != nil {
+			return err
+		}
+		snippet, err := s.client.GetSnippet(id)
+		if err != nil {
+			return err
+		}
+
+		w := c.App.Writer
+		if !c.Bool("code") {
+			fmt.Fprintf(w, "%s\n", snippet.Title)
+			fmt.Fprintf(w, "ID:       %s\n", snippet.ID)
+			fmt.Fprintf(w, "Language: %s\n", snippet.Language)
+			if len(snippet.Tags) > 0 {
+				fmt.Fprintf(w, "Tags:     %s\n", strings.Join(snippet.Tags, ", "))
+			}
+			if snippet.FolderID != nil {
+				fmt.Fprintf(w, "Folder:   %s\n", snippet.FolderID)
+			}
+			fmt.Fprintf(w, "Updated:  %s\n", snippet.UpdatedAt.Local().Format("2006-01-02 15:04"))
+			if snippet.Description != "" {
+				fmt.Fprintf(w, "\n%s\n", snippet.Description)
+			}
+			fmt.Fprintln(w)
+		}
+		code := snippet.Code
+		if c.Bool("color") {
+			code, err = s.client.RenderSnippet(id, client.RenderOptions{
+				Format:      "ansi",
+				Theme:       c.String("theme"),
+				LineNumbers: c.Bool("line-numbers"),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(w, code)
+		if !strings.HasSuffix(code, "\n") {
+			fmt.Fprintln(w)
+		}
+		return nil
+	},
+}
+
+var editCommand = &cli.Command{
+	Name:      "edit",
+	Usage:     "edit the code of a snippet in $EDITOR",
+	ArgsUsage: "ID",
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+		id, err := s.resolveSnippet(c.Args().First())
+		if err != nil {
+			return err
+		}
+		snippet, err := s.client.GetSnippet(id)
+		if err != nil {
+			return err
+		}
+
+		code, err := editText(snippet.Code, languages.Extension(snippet.Language))
+		if err != nil {
+			return err
+		}
+		if code == snippet.Code {
+			fmt.Fprintln(c.App.Writer, "No changes")
+			return nil
+		}
+		snippet.Code = code
+		if _, err := s.client.UpdateSnippet(snippet); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.App.Writer, "Updated", snippet.ID)
+		return nil
+	},
+}
+
+var rmCommand = &cli.Command{
+	Name:      "rm",
+	Usage:     "delete snippets",
+	ArgsUsage: "ID...",
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+		if c.NArg() == 0 {
+			return errors.New("missing snippet ID")
+		}
+		for _, arg := range c.Args().Slice() {
+			id, err := s.resolveSnippet(arg)
+			if err != nil {
+				return err
+			}
+			if err := s.client.DeleteSnippet(id); err != nil {
+				return err
+			}
+			fmt.Fprintln(c.App.Writer, "Deleted", id)
+		}
+		return nil
+	},
+}
+
+var tagCommand = &cli.Command{
+	Name:  "tag",
+	Usage: "manage tags",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "ls",
+			Usage: "list tags with the number of snippets carrying each",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				tags, err := s.client.Tags()
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "TAG\tSNIPPETS")
+				for _, tag := range tags {
+					fmt.Fprintf(tw, "%s\t%d\n", tag.Name, tag.SnippetCount)
+				}
+				return tw.Flush()
+			},
+		},
+		{
+			Name:      "add",
+			Usage:     "attach tags to a snippet",
+			ArgsUsage: "ID TAG...",
+			Action: func(c *cli.Context) error {
+				return changeTags(c, (*client.Client).AddTag)
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "detach tags from a snippet",
+			ArgsUsage: "ID TAG...",
+			Action: func(c *cli.Context) error {
+				return changeTags(c, (*client.Client).RemoveTag)
+			},
+		},
+		{
+			Name:      "rename",
+			Usage:     "rename a tag on every snippet",
+			ArgsUsage: "TAG NAME",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 2 {
+					return errors.New("expected a tag and its new name")
+				}
+				return s.client.RenameTag(c.Args().Get(0), c.Args().Get(1))
+			},
+		},
+		{
+			Name:      "merge",
+			Usage:     "replace a tag with another one on every snippet",
+			ArgsUsage: "SOURCE TARGET",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 2 {
+					return errors.New("expected the tag to merge and the tag to merge it into")
+				}
+				return s.client.MergeTags(c.Args().Get(0), c.Args().Get(1))
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "remove tags from every snippet",
+			ArgsUsage: "TAG...",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() == 0 {
+					return errors.New("expected at least one tag")
+				}
+				for _, tag := range c.Args().Slice() {
+					if err := s.client.DeleteTag(tag); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	},
+}
+
+func changeTags(c *cli.Context, change func(*client.Client, uuid.UUID, string) error) error {
+	s, err := loggedIn(c)
+	if err != nil {
+		return err
+	}
+	if c.NArg() < 2 {
+		return errors.New("expected a snippet ID and at least one tag")
+	}
+	id, err := s.resolveSnippet(c.Args().First())
+	if err != nil {
+		return err
+	}
+	for _, tag := range c.Args().Tail() {
+		if err := change(s.client, id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var folderCommand = &cli.Command{
+	Name:  "folder",
+	Usage: "manage folders",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "ls",
+			Usage: "print the folder tree with the number of snippets in each folder",
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "depth", Aliases: []string{"d"}, Usage: "number of levels to print, all if 0"},
+			},
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.Int("depth") < 0 {
+					return errors.New("depth must not be negative")
+				}
+				tree, err := s.client.FolderTree(c.Int("depth"), false)
+				if err != nil {
+					return err
+				}
+				printFolderTree(c.App.Writer, tree)
+				return nil
+			},
+		},
+		{
+			Name:      "create",
+			Usage:     "create a folder",
+			ArgsUsage: "NAME",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "parent", Aliases: []string{"p"}, Usage: "parent folder name or ID"},
+			},
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				name := strings.TrimSpace(c.Args().First())
+				if name == "" || c.NArg() > 1 {
+					return errors.New("expected one folder name")
+				}
+				var parentID *uuid.UUID
+				if parent := c.String("parent"); parent != "" {
+					id, err := s.resolveFolder(parent)
+					if err != nil {
+						return err
+					}
+					parentID = &id
+				}
+				folder, err := s.client.CreateFolder(name, parentID)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(c.App.Writer, folder.ID)
+				return nil
+			},
+		},
+		{
+			Name:      "show",
+			Usage:     "list the subfolders and snippets of a folder",
+			ArgsUsage: "FOLDER",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				contents, err := s.client.FolderContents(id)
+				if err != nil {
+					return err
+				}
+				// The path of a folder shared by another user is theirs
+				// to see, so it is left out
+				path, err := s.client.FolderPath(id)
+				if err != nil && !client.IsStatus(err, http.StatusNotFound) {
+					return err
+				}
+				if err == nil {
+					names := make([]string, len(path))
+					for i, folder := range path {
+						names[i] = folder.Name
+					}
+					fmt.Fprintf(c.App.Writer, "/%s\n", strings.Join(names, "/"))
+				}
+				for _, folder := range contents.Folders {
+					fmt.Fprintf(c.App.Writer, "%s/\t%s\n", folder.Name, folder.ID)
+				}
+				printSnippets(c.App.Writer, contents.Snippets)
+				return nil
+			},
+		},
+		{
+			Name:      "rename",
+			Usage:     "rename a folder",
+			ArgsUsage: "FOLDER NAME",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				name := strings.TrimSpace(c.Args().Get(1))
+				if name == "" || c.NArg() > 2 {
+					return errors.New("expected a folder and its new name")
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				_, err = s.client.RenameFolder(id, name)
+				return err
+			},
+		},
+		{
+			Name:      "mv",
+			Usage:     "move a folder into another one, or to the top level with /",
+			ArgsUsage: "FOLDER PARENT",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 2 {
+					return errors.New("expected a folder and its new parent")
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				var parentID *uuid.UUID
+				if parent := c.Args().Get(1); parent != "/" {
+					id, err := s.resolveFolder(parent)
+					if err != nil {
+						return err
+					}
+					parentID = &id
+				}
+				_, err = s.client.MoveFolder(id, parentID)
+				return err
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "delete a folder, moving its content to the parent folder",
+			ArgsUsage: "FOLDER",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "recursive", Aliases: []string{"r"}, Usage: "delete the subfolders and snippets instead"},
+			},
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				return s.client.DeleteFolder(id, c.Bool("recursive"))
+			},
+		},
+		{
+			Name:      "export",
+			Usage:     "save a folder and everything below it as source files or a Markdown cheat sheet",
+			ArgsUsage: "FOLDER [FILE]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "format", Usage: "files (a tar.gz) or markdown (default: markdown if FILE ends in .md, files otherwise)"},
+			},
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() < 1 || c.NArg() > 2 {
+					return errors.New("expected a folder and at most one file")
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				file := c.Args().Get(1)
+				format := c.String("format")
+				if format == "" {
+					format = "files"
+					if strings.HasSuffix(file, ".md") {
+						format = "markdown"
+					}
+				}
+
+				export, err := s.client.ExportFolder(id, format)
+				if err != nil {
+					return err
+				}
+				defer export.Close()
+				return writeOutput(c.App.Writer, file, export)
+			},
+		},
+		{
+			Name:      "share",
+			Usage:     "share a folder and everything below it with another user",
+			ArgsUsage: "FOLDER USER",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "permission", Aliases: []string{"p"}, Value: string(models.PermissionRead), Usage: "read, or write to also let the user update snippets"},
+			},
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 2 {
+					return errors.New("expected a folder and a username or email")
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				_, err = s.client.ShareFolder(id, c.Args().Get(1), models.Permission(c.String("permission")))
+				return err
+			},
+		},
+		{
+			Name:      "unshare",
+			Usage:     "stop sharing a folder with a user",
+			ArgsUsage: "FOLDER USERNAME",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 2 {
+					return errors.New("expected a folder and a username")
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				shares, err := s.client.FolderShares(id)
+				if err != nil {
+					return err
+				}
+				username := c.Args().Get(1)
+				for _, share := range shares {
+					if share.Username == username {
+						return s.client.UnshareFolder(id, share.UserID)
+					}
+				}
+				return fmt.Errorf("the folder is not shared with %s", username)
+			},
+		},
+		{
+			Name:      "shares",
+			Usage:     "list the users a folder is shared with",
+			ArgsUsage: "FOLDER",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				id, err := s.resolveFolder(c.Args().First())
+				if err != nil {
+					return err
+				}
+				shares, err := s.client.FolderShares(id)
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "USERNAME\tPERMISSION\tSINCE")
+				for _, share := range shares {
+					fmt.Fprintf(tw, "%s\t%s\t%s\n", share.Username, share.Permission, share.CreatedAt.Local().Format("2006-01-02"))
+				}
+				return tw.Flush()
+			},
+		},
+		{
+			Name:  "shared",
+			Usage: "list the folders other users shared with you",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				shares, err := s.client.SharedFolders()
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "ID\tNAME\tOWNER\tPERMISSION")
+				for _, share := range shares {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", share.Folder.ID, share.Folder.Name, share.Owner, share.Permission)
+				}
+				return tw.Flush()
+			},
+		},
+	},
+}
+
+var shareCommand = &cli.Command{
+	Name:  "share",
+	Usage: "manage public links to snippets and folders",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "create",
+			Usage:     "create a link anyone can open without an account, and print its URL",
+			ArgsUsage: "[SNIPPET]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "folder", Aliases: []string{"f"}, Usage: "share this folder (name or ID) instead of a snippet"},
+				&cli.DurationFlag{Name: "expires", Aliases: []string{"e"}, Usage: "lifetime of the link, such as 24h (default: no expiry)"},
+				&cli.IntFlag{Name: "max-views", Usage: "number of times the link can be opened (default: no limit)"},
+				&cli.BoolFlag{Name: "password", Aliases: []string{"p"}, Usage: "prompt for a password the link will require"},
+			},
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				var link models.ShareLink
+				if folder := c.String("folder"); folder != "" {
+					if c.NArg() > 0 {
+						return errors.New("expected a snippet or --folder, not both")
+					}
+					id, err := s.resolveFolder(folder)
+					if err != nil {
+						return err
+					}
+					link.FolderID = &id
+				} else {
+					if c.NArg() != 1 {
+						return errors.New("expected one snippet or --folder")
+					}
+					id, err := s.resolveSnippet(c.Args().First())
+					if err != nil {
+						return err
+					}
+					link.SnippetID = &id
+				}
+				if c.IsSet("expires") {
+					if c.Duration("expires") <= 0 {
+						return errors.New("expires must be positive")
+					}
+					expiresAt := time.Now().Add(c.Duration("expires"))
+					link.ExpiresAt = &expiresAt
+				}
+				if c.IsSet("max-views") {
+					maxViews := c.Int("max-views")
+					link.MaxViews = &maxViews
+				}
+				if c.Bool("password") {
+					if link.Password, err = readPassword("Link password: "); err != nil {
+						return err
+					}
+				}
+
+				created, err := s.client.CreateShareLink(link)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(c.App.Writer, "%s/s/%s\n", s.client.BaseURL, created.Token)
+				return nil
+			},
+		},
+		{
+			Name:  "ls",
+			Usage: "list the active share links",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				links, err := s.client.ShareLinks()
+				if err != nil {
+					return err
+				}
+				printShareLinks(c.App.Writer, links)
+				return nil
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "revoke a share link",
+			ArgsUsage: "ID",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				id, err := s.resolveShareLink(c.Args().First())
+				if err != nil {
+					return err
+				}
+				return s.client.DeleteShareLink(id)
+			},
+		},
+	},
+}
+
+// resolveShareLink accepts a full share link ID or a unique prefix of one,
+// as printed by share ls
+func (s *session) resolveShareLink(arg string) (uuid.UUID, error) {
+	if arg == "" {
+		return uuid.Nil, errors.New("expected a share link ID")
+	}
+	if id, err := uuid.Parse(arg); err == nil {
+		return id, nil
+	}
+	links, err := s.client.ShareLinks()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var matches []uuid.UUID
+	for _, link := range links {
+		if strings.HasPrefix(link.ID.String(), arg) {
+			matches = append(matches, link.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return uuid.Nil, fmt.Errorf("no share link matches %q", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return uuid.Nil, fmt.Errorf("%q matches %d share links", arg, len(matches))
+	}
+}
+
+var workspaceCommand = &cli.Command{
+	Name:  "workspace",
+	Usage: "manage workspaces, whose libraries are shared by their members; select one with --workspace",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "ls",
+			Usage: "list your workspaces with your role in each",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				workspaces, err := s.client.Workspaces()
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "ID\tNAME\tROLE")
+				for _, workspace := range workspaces {
+					fmt.Fprintf(tw, "%s\t%s\t%s\n", workspace.ID.String()[:8], workspace.Name, workspace.Role)
+				}
+				return tw.Flush()
+			},
+		},
+		{
+			Name:      "create",
+			Usage:     "create a workspace",
+			ArgsUsage: "NAME",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				name := strings.TrimSpace(c.Args().First())
+				if name == "" || c.NArg() > 1 {
+					return errors.New("expected one workspace name")
+				}
+				workspace, err := s.client.CreateWorkspace(name)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(c.App.Writer, workspace.ID)
+				return nil
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "delete a workspace with all its folders and snippets",
+			ArgsUsage: "WORKSPACE",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				id, err := s.resolveWorkspace(c.Args().First())
+				if err != nil {
+					return err
+				}
+				return s.client.DeleteWorkspace(id)
+			},
+		},
+		{
+			Name:      "members",
+			Usage:     "list the members of a workspace",
+			ArgsUsage: "WORKSPACE",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				id, err := s.resolveWorkspace(c.Args().First())
+				if err != nil {
+					return err
+				}
+				members, err := s.client.WorkspaceMembers(id)
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "USERNAME\tROLE\tSINCE")
+				for _, member := range members {
+					fmt.Fprintf(tw, "%s\t%s\t%s\n", member.Username, member.Role, member.CreatedAt.Local().Format("2006-01-02"))
+				}
+				return tw.Flush()
+			},
+		},
+		{
+			Name:      "add",
+			Usage:     "add a user to a workspace",
+			ArgsUsage: "WORKSPACE USER",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "role", Aliases: []string{"r"}, Value: string(models.RoleEditor), Usage: "owner, editor or viewer"},
+			},
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 2 {
+					return errors.New("expected a workspace and a username or email")
+				}
+				id, err := s.resolveWorkspace(c.Args().First())
+				if err != nil {
+					return err
+				}
+				_, err = s.client.AddWorkspaceMember(id, c.Args().Get(1), models.Role(c.String("role")))
+				return err
+			},
+		},
+		{
+			Name:      "role",
+			Usage:     "change the role of a member",
+			ArgsUsage: "WORKSPACE USERNAME ROLE",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 3 {
+					return errors.New("expected a workspace, a username and a role")
+				}
+				id, userID, err := s.resolveMember(c.Args().First(), c.Args().Get(1))
+				if err != nil {
+					return err
+				}
+				return s.client.SetWorkspaceRole(id, userID, models.Role(c.Args().Get(2)))
+			},
+		},
+		{
+			Name:      "remove",
+			Usage:     "remove a member from a workspace; remove yourself to leave it",
+			ArgsUsage: "WORKSPACE USERNAME",
+			Action: func(c *cli.Context) error {
+				s, err := loggedIn(c)
+				if err != nil {
+					return err
+				}
+				if c.NArg() != 2 {
+					return errors.New("expected a workspace and a username")
+				}
+				id, userID, err := s.resolveMember(c.Args().First(), c.Args().Get(1))
+				if err != nil {
+					return err
+				}
+				return s.client.RemoveWorkspaceMember(id, userID)
+			},
+		},
+	},
+}
+
+// resolveWorkspace accepts a workspace name, ignoring case, or its ID or a
+// unique prefix of it
+func (s *session) resolveWorkspace(arg string) (uuid.UUID, error) {
+	if arg == "" {
+		return uuid.Nil, errors.New("missing workspace")
+	}
+	if id, err := uuid.Parse(arg); err == nil {
+		return id, nil
+	}
+	workspaces, err := s.client.Workspaces()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var matches []uuid.UUID
+	for _, workspace := range workspaces {
+		if strings.EqualFold(workspace.Name, arg) || strings.HasPrefix(workspace.ID.String(), arg) {
+			matches = append(matches, workspace.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return uuid.Nil, fmt.Errorf("no workspace matches %q", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return uuid.Nil, fmt.Errorf("%q matches %d workspaces; use the workspace ID", arg, len(matches))
+	}
+}
+
+// resolveMember returns the IDs of a workspace and of one of its members,
+// given by username
+func (s *session) resolveMember(workspace, username string) (uuid.UUID, uuid.UUID, error) {
+	id, err := s.resolveWorkspace(workspace)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	members, err := s.client.WorkspaceMembers(id)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	for _, member := range members {
+		if member.Username == username {
+			return id, member.UserID, nil
+		}
+	}
+	return uuid.Nil, uuid.Nil, fmt.Errorf("%s is not a member of the workspace", username)
+}
+
+var languagesCommand = &cli.Command{
+	Name:  "languages",
+	Usage: "list the known languages with the number of snippets in each",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "used", Aliases: []string{"u"}, Usage: "only list languages with snippets"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+		list, err := s.client.Languages(c.Bool("used"))
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "LANGUAGE\tSNIPPETS\tALIASES\tEXTENSIONS")
+		for _, lang := range list {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", lang.Name, lang.SnippetCount,
+				strings.Join(lang.Aliases, ","), strings.Join(lang.Extensions, ","))
+		}
+		return tw.Flush()
+	},
+}
+
+var exportCommand = &cli.Command{
+	Name:      "export",
+	Usage:     "save all snippets and folders to an archive",
+	ArgsUsage: "[FILE]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "format", Usage: "json or tar.gz (default: tar.gz if FILE ends in .tar.gz or .tgz, json otherwise)"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+		if c.NArg() > 1 {
+			return errors.New("export takes at most one file")
+		}
+		file := c.Args().First()
+		format := c.String("format")
+		if format == "" {
+			format = "json"
+			if strings.HasSuffix(file, ".tar.gz") || strings.HasSuffix(file, ".tgz") {
+				format = "tar.gz"
+			}
+		}
+
+		archive, err := s.client.Export(format)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+		return writeOutput(c.App.Writer, file, archive)
+	},
+}
+
+// writeOutput copies r to file, or to w if file is empty or -
+func writeOutput(w io.Writer, file string, r io.Reader) error {
+	if file == "" || file == "-" {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var importCommand = &cli.Command{
+	Name:  "import",
+	Usage: "restore an archive made by export, or add the files of a directory as snippets",
+	Description: "If FILE is a directory, each text file below it becomes a snippet and each subdirectory a folder.\n" +
+		"A leading block of comments such as \"# title: ...\", \"# description: ...\" and \"# tags: a, b\" sets the metadata of a snippet.",
+	ArgsUsage: "FILE|DIR",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "conflict", Value: "skip", Usage: "what to do with snippets and folders that already exist: skip, overwrite or duplicate"},
+		&cli.BoolFlag{Name: "new-ids", Usage: "import everything as new snippets and folders"},
+	},
+	Action: func(c *cli.Context) error {
+		s, err := loggedIn(c)
+		if err != nil {
+			return err
+		}
+		if c.NArg() != 1 {
+			return errors.New("expected one archive file or directory, or - for standard input")
+		}
+		file := c.Args().First()
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			return s.importDirectory(c.App.Writer, file)
+		}
+
+		var archive io.Reader = os.Stdin
+		if file != "-" {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			archive = f
+		}
+
+		result, err := s.client.Import(archive, c.String("conflict"), c.Bool("new-ids"))
+		if err != nil {
+			return err
+		}
+		printImportCounts(c.App.Writer, result.Folders, result.Snippets)
+		return nil
+	},
+}
+
+// importDirectory uploads the files below dir as a tar.gz, streamed as it is
+// written
+func (s *session) importDirectory(w io.Writer, dir string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTarGz(pw, dir))
+	}()
+	result, err := s.client.ImportDirectory(pr)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	printImportCounts(w, result.Folders, result.Snippets)
+	for _, skipped := range result.SkippedFiles {
+		fmt.Fprintf(w, "skipped %s: %s\n", skipped.Path, skipped.Reason)
+	}
+	return nil
+}
+
+// writeTarGz writes the regular files below dir, with paths relative to it,
+// leaving out hidden files and directories
+func writeTarGz(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func printImportCounts(w io.Writer, folders, snippets models.ImportCounts) {
+	for _, line := range []struct {
+		name   string
+		counts models.ImportCounts
+	}{{"folders", folders}, {"snippets", snippets}} {
+		fmt.Fprintf(w, "%s: %d created, %d updated, %d skipped\n",
+			line.name, line.counts.Created, line.counts.Updated, line.counts.Skipped)
+	}
+}
+
+var tuiCommand = &cli.Command{
+	Name:  "tui",
+	Usage: "browse, copy and edit snippets in a terminal interface",
+	Action: func(c *cli.Context) error {
+		s, err := newSession(c)
+		if err != nil {
+			return err
+		}
+		if s.client.Token == "" {
+			if err := s.login("", false); err != nil {
+				return err
+			}
+		}
+		return tui.Run(s.client, s.ownerID())
+	},
+}
+
+// listSnippets follows cursors until limit snippets were read, or all of them
+// if limit is zero
+func (s *session) listSnippets(params client.ListParams, limit int) ([]models.Snippet, error) {
+	const pageSize = 100
+
+	var snippets []models.Snippet
+	for {
+		params.Limit = pageSize
+		if limit > 0 {
+			params.Limit = min(pageSize, limit-len(snippets))
+		}
+		page, err := s.client.ListSnippets(params)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, page.Snippets...)
+		if page.NextCursor == "" || (limit > 0 && len(snippets) >= limit) {
+			return snippets, nil
+		}
+		params.Cursor = page.NextCursor
+	}
+}
+
+// resolveSnippet accepts a full snippet ID or a unique prefix of one, as
+// printed by ls
+func (s *session) resolveSnippet(arg string) (uuid.UUID, error) {
+	if arg == "" {
+		return uuid.Nil, errors.New("missing snippet ID")
+	}
+	if id, err := uuid.Parse(arg); err == nil {
+		return id, nil
+	}
+
+	snippets, err := s.listSnippets(client.ListParams{}, 0)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var matches []uuid.UUID
+	for _, snip := range snippets {
+		if strings.HasPrefix(snip.ID.String(), strings.ToLower(arg)) {
+			matches = append(matches, snip.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return uuid.Nil, fmt.Errorf("no snippet matches %q", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return uuid.Nil, fmt.Errorf("%q matches %d snippets; give more of the ID", arg, len(matches))
+	}
+}
+
+// resolveFolder accepts a folder ID or a folder name that is unique among
+// the user's folders or, failing that, among the folders shared with them
+func (s *session) resolveFolder(arg string) (uuid.UUID, error) {
+	if arg == "" {
+		return uuid.Nil, errors.New("missing folder")
+	}
+	if id, err := uuid.Parse(arg); err == nil {
+		return id, nil
+	}
+
+	folders, err := s.client.Folders(s.ownerID())
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var matches []uuid.UUID
+	for _, folder := range folders {
+		if folder.Name == arg {
+			matches = append(matches, folder.ID)
+		}
+	}
+	if len(matches) == 0 {
+		shares, err := s.client.SharedFolders()
+		if err != nil {
+			return uuid.Nil, err
+		}
+		for _, share := range shares {
+			if share.Folder.Name == arg {
+				matches = append(matches, share.Folder.ID)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return uuid.Nil, fmt.Errorf("no folder named %q", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return uuid.Nil, fmt.Errorf("%d folders are named %q; use the folder ID", len(matches), arg)
+	}
+}
+
+func printSnippets(w io.Writer, snippets []models.Snippet) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tLANGUAGE\tTAGS\tUPDATED")
+	for _, snip := range snippets {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			snip.ID.String()[:8],
+			snip.Title,
+			snip.Language,
+			strings.Join(snip.Tags, ","),
+			snip.UpdatedAt.Local().Format("2006-01-02 15:04"),
+		)
+	}
+	tw.Flush()
+}
+
+func printShareLinks(w io.Writer, links []models.ShareLink) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTARGET\tEXPIRES\tVIEWS\tPASSWORD")
+	for _, link := range links {
+		var target string
+		if link.SnippetID != nil {
+			target = "snippet " + link.SnippetID.String()[:8]
+		} else {
+			target = "folder " + link.FolderID.String()[:8]
+		}
+		expires := "never"
+		if link.ExpiresAt != nil {
+			expires = link.ExpiresAt.Local().Format("2006-01-02 15:04")
+		}
+		views := strconv.Itoa(link.Views)
+		if link.MaxViews != nil {
+			views += "/" + strconv.Itoa(*link.MaxViews)
+		}
+		password := "no"
+		if link.HasPassword {
+			password = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", link.ID.String()[:8], target, expires, views, password)
+	}
+	tw.Flush()
+}
+
+func printSessions(w io.Writer, sessions []models.Session) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tIP\tLAST USED\tDEVICE")
+	for _, session := range sessions {
+		id := session.ID.String()[:8]
+		if session.Current {
+			id += " *"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", id, session.IP,
+			session.LastUsedAt.Local().Format("2006-01-02 15:04"), session.UserAgent)
+	}
+	tw.Flush()
+}
+
+func printFolderTree(w io.Writer, tree []models.FolderNode) {
+	for _, node := range tree {
+		fmt.Fprintf(w, "%s%s/  %s  (%d)\n", strings.Repeat("  ", node.Depth), node.Name, node.ID.String()[:8], node.SnippetCount)
+		printFolderTree(w, node.Children)
+	}
+}
+
+// editText opens text in the user's editor and returns the saved result
+func editText(text, ext string) (string, error) {
+	f, err := os.CreateTemp("", "snippet-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	cmd := client.EditorCommand(f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor: %w", err)
+	}
+
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
+
+// readPassword prompts for a password without echoing it, or reads a line of
+// standard input if it is not a terminal
+func readPassword(prompt string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return readLine(bufio.NewReader(os.Stdin))
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	raw, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(raw), err
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
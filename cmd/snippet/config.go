@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"snippet-manager-go/client"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// cliConfig is the per-user state of the command line client. It holds the
+// session tokens, so it is written readable by its owner only.
+type cliConfig struct {
+	Server       string    `yaml:"server"`
+	Username     string    `yaml:"username,omitempty"`
+	UserID       uuid.UUID `yaml:"user_id,omitempty"`
+	Token        string    `yaml:"token,omitempty"`
+	RefreshToken string    `yaml:"refresh_token,omitempty"`
+	TokenExpiry  time.Time `yaml:"token_expiry,omitempty"`
+}
+
+// configPath returns $SNIPPET_CLI_CONFIG or snippet/config.yaml in the
+// user's config directory
+func configPath() (string, error) {
+	if path := os.Getenv("SNIPPET_CLI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snippet", "config.yaml"), nil
+}
+
+// loadConfig reads the config file, returning an empty config if it does not
+// exist yet
+func loadConfig(path string) (*cliConfig, error) {
+	cfg := &cliConfig{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *cliConfig) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a failed write never leaves a
+	// truncated config behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// setTokens saves the tokens of a session, or forgets them when empty
+func (c *cliConfig) setTokens(tokens client.Tokens) {
+	c.Token = tokens.Token
+	c.RefreshToken = tokens.RefreshToken
+	c.TokenExpiry = tokens.ExpiresAt
+}
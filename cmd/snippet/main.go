@@ -0,0 +1,128 @@
+// Command snippet is a command line client for the snippet manager API.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+
+	"snippet-manager-go/client"
+)
+
+func main() {
+	app := &cli.App{
+		Name:  "snippet",
+		Usage: "manage snippets stored on a snippet manager server",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "server",
+				Usage:   "server URL (default: the server of the last login, or " + defaultServer + ")",
+				EnvVars: []string{"SNIPPET_SERVER"},
+			},
+			&cli.StringFlag{
+				Name:    "workspace",
+				Usage:   "work on the library of this workspace (name or ID) instead of your own",
+				EnvVars: []string{"SNIPPET_WORKSPACE"},
+			},
+		},
+		Commands: []*cli.Command{
+			loginCommand,
+			logoutCommand,
+			addCommand,
+			lsCommand,
+			showCommand,
+			editCommand,
+			rmCommand,
+			tagCommand,
+			folderCommand,
+			shareCommand,
+			workspaceCommand,
+			sessionCommand,
+			languagesCommand,
+			exportCommand,
+			importCommand,
+			tuiCommand,
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		if client.IsStatus(err, http.StatusUnauthorized) {
+			err = errors.New("not logged in or the session expired; run snippet login")
+		}
+		fmt.Fprintln(os.Stderr, "snippet:", err)
+		os.Exit(1)
+	}
+}
+
+// session is the state shared by the commands: the config file and a client
+// for the selected server
+type session struct {
+	cfg    *cliConfig
+	path   string
+	client *client.Client
+}
+
+// newSession loads the config and connects to the server given by --server,
+// the config file or the default, in that order
+func newSession(c *cli.Context) (*session, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	server := c.String("server")
+	if server == "" {
+		server = cfg.Server
+	}
+	if server == "" {
+		server = defaultServer
+	}
+	s := &session{cfg: cfg, path: path, client: client.New(server, "")}
+	// The saved tokens may belong to another server
+	if cfg.Server == server {
+		s.client.Token = cfg.Token
+		s.client.RefreshToken = cfg.RefreshToken
+		s.client.TokenExpiry = cfg.TokenExpiry
+		s.client.OnRefresh = func(tokens client.Tokens) {
+			s.cfg.setTokens(tokens)
+			if err := s.cfg.save(s.path); err != nil {
+				fmt.Fprintln(os.Stderr, "snippet: save renewed session:", err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// loggedIn returns a session that has a token
+func loggedIn(c *cli.Context) (*session, error) {
+	s, err := newSession(c)
+	if err != nil {
+		return nil, err
+	}
+	if s.client.Token == "" {
+		return nil, errors.New("not logged in; run snippet login")
+	}
+	if workspace := c.String("workspace"); workspace != "" {
+		if s.client.Workspace, err = s.resolveWorkspace(workspace); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// ownerID returns the ID owning the library the session works on: the
+// selected workspace, or the logged in user
+func (s *session) ownerID() uuid.UUID {
+	if s.client.Workspace != uuid.Nil {
+		return s.client.Workspace
+	}
+	return s.cfg.UserID
+}
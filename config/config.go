@@ -0,0 +1,254 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// legacySecret is the signing key that used to be hardcoded in the source.
+// Tokens signed with it can be forged by anyone who has read the code.
+const legacySecret = "my_secret_key"
+
+// minSecretLength is the minimum JWT secret length in bytes (256 bits for
+// HS256)
+const minSecretLength = 32
+
+// Config holds every setting of the server. Values are resolved in order of
+// increasing precedence: built-in defaults, the YAML config file,
+// SNIPPET_* environment variables and command line flags.
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Database Database `yaml:"database"`
+	Auth     Auth     `yaml:"auth"`
+	Limits   Limits   `yaml:"limits"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr"`
+}
+
+type Database struct {
+	// Driver selects the storage backend: postgres or sqlite
+	Driver     string `yaml:"driver"`
+	DSN        string `yaml:"dsn"`
+	SQLitePath string `yaml:"sqlite_path"`
+	// AutoMigrate applies pending migrations on startup. It is off by
+	// default; run the migrate command instead.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type Auth struct {
+	JWTSecret string `yaml:"jwt_secret"`
+	// TokenTTL is the lifetime of access tokens, which cannot be renewed
+	// past RefreshTTL without logging in again
+	TokenTTL   time.Duration `yaml:"token_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+// Limits bounds the size of user supplied snippet fields and of uploaded
+// archives
+type Limits struct {
+	MaxTitleLength int `yaml:"max_title_length"`
+	MaxCodeLength  int `yaml:"max_code_length"`
+	MaxTagLength   int `yaml:"max_tag_length"`
+	// MaxImportSize is the maximum size in bytes of an import request body
+	MaxImportSize int `yaml:"max_import_size"`
+}
+
+// Default returns the configuration used when nothing else is set. It has
+// no JWT secret, so it does not pass Validate on its own.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr: ":8080",
+		},
+		Database: Database{
+			Driver:     "postgres",
+			DSN:        "host=localhost port=5432 user=postgres password=mysecretpassword dbname=snippet_manager sslmode=disable",
+			SQLitePath: "snippets.db",
+		},
+		Auth: Auth{
+			TokenTTL:   15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Limits: Limits{
+			MaxTitleLength: 100,
+			MaxCodeLength:  10000,
+			MaxTagLength:   50,
+			MaxImportSize:  32 << 20,
+		},
+	}
+}
+
+// setting binds one configuration value to its environment variable and
+// command line flag
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	value flag.Value
+}
+
+func settings(c *Config) []setting {
+	return []setting{
+		{"SNIPPET_ADDR", "addr", "address to listen on", (*stringValue)(&c.Server.Addr)},
+		{"SNIPPET_DB_DRIVER", "db", "storage backend: postgres or sqlite", (*stringValue)(&c.Database.Driver)},
+		{"SNIPPET_DB_DSN", "db-dsn", "Postgres connection string", (*stringValue)(&c.Database.DSN)},
+		{"SNIPPET_SQLITE_PATH", "sqlite-path", "path of the SQLite database file", (*stringValue)(&c.Database.SQLitePath)},
+		{"SNIPPET_AUTO_MIGRATE", "auto-migrate", "apply pending database migrations on startup", (*boolValue)(&c.Database.AutoMigrate)},
+		{"SNIPPET_JWT_SECRET", "jwt-secret", "secret used to sign authentication tokens", (*stringValue)(&c.Auth.JWTSecret)},
+		{"SNIPPET_TOKEN_TTL", "token-ttl", "lifetime of access tokens", (*durationValue)(&c.Auth.TokenTTL)},
+		{"SNIPPET_REFRESH_TTL", "refresh-ttl", "time a session lasts without being refreshed", (*durationValue)(&c.Auth.RefreshTTL)},
+		{"SNIPPET_MAX_TITLE_LENGTH", "max-title-length", "maximum snippet title length", (*intValue)(&c.Limits.MaxTitleLength)},
+		{"SNIPPET_MAX_CODE_LENGTH", "max-code-length", "maximum snippet code length", (*intValue)(&c.Limits.MaxCodeLength)},
+		{"SNIPPET_MAX_TAG_LENGTH", "max-tag-length", "maximum tag length", (*intValue)(&c.Limits.MaxTagLength)},
+		{"SNIPPET_MAX_IMPORT_SIZE", "max-import-size", "maximum size in bytes of an imported archive", (*intValue)(&c.Limits.MaxImportSize)},
+	}
+}
+
+// Load resolves the configuration from the config file, the environment
+// and the given command line arguments (without the program name). It
+// returns the arguments left over after the flags. The result is not
+// validated, since subcommands only need part of it.
+func Load(args []string) (*Config, []string, error) {
+	// Flags are parsed into a scratch config first so that only flags that
+	// were actually given override the file and the environment
+	var fromFlags Config
+	fs := flag.NewFlagSet("snippet-manager", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("SNIPPET_CONFIG"), "path of a YAML config file (env SNIPPET_CONFIG)")
+	for _, s := range settings(&fromFlags) {
+		fs.Var(s.value, s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range settings(&cfg) {
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.value.Set(v); err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	targets := make(map[string]setting)
+	for _, s := range settings(&cfg) {
+		targets[s.flag] = s
+	}
+	// The values were already validated when the flags were parsed
+	fs.Visit(func(f *flag.Flag) {
+		if s, ok := targets[f.Name]; ok {
+			s.value.Set(f.Value.String())
+		}
+	})
+
+	return &cfg, fs.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting
+func (c *Config) Validate() error {
+	if strings.TrimSpace(c.Server.Addr) == "" {
+		return errors.New("server address cannot be empty")
+	}
+
+	if err := c.Database.Validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case c.Auth.JWTSecret == "":
+		return errors.New("JWT secret is not set; set SNIPPET_JWT_SECRET or auth.jwt_secret")
+	case c.Auth.JWTSecret == legacySecret:
+		return errors.New("JWT secret must not be the default value")
+	case len(c.Auth.JWTSecret) < minSecretLength:
+		return fmt.Errorf("JWT secret must be at least %d bytes long", minSecretLength)
+	}
+	if c.Auth.TokenTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+		return errors.New("token TTLs must be positive")
+	}
+
+	if c.Limits.MaxTitleLength <= 0 || c.Limits.MaxCodeLength <= 0 || c.Limits.MaxTagLength <= 0 || c.Limits.MaxImportSize <= 0 {
+		return errors.New("limits must be positive")
+	}
+	return nil
+}
+
+// Validate reports whether the selected storage backend is configured
+func (d Database) Validate() error {
+	switch d.Driver {
+	case "postgres":
+		if strings.TrimSpace(d.DSN) == "" {
+			return errors.New("database DSN cannot be empty")
+		}
+	case "sqlite":
+		if strings.TrimSpace(d.SQLitePath) == "" {
+			return errors.New("SQLite path cannot be empty")
+		}
+	default:
+		return fmt.Errorf("unknown database driver %q", d.Driver)
+	}
+	return nil
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	*v = boolValue(b)
+	return err
+}
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	*v = intValue(i)
+	return err
+}
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	*v = durationValue(d)
+	return err
+}
+func (v *durationValue) String() string { return time.Duration(*v).String() }
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every SNIPPET_* variable for the duration of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	keys := []string{"SNIPPET_CONFIG"}
+	for _, s := range settings(&Config{}) {
+		keys = append(keys, s.env)
+	}
+	for _, key := range keys {
+		if v, ok := os.LookupEnv(key); ok {
+			t.Setenv(key, v)
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := `
+server:
+  addr: ":1000"
+database:
+  driver: sqlite
+  sqlite_path: file.db
+auth:
+  token_ttl: 1m
+`
+	tests := []struct {
+		name     string
+		file     bool
+		env      map[string]string
+		args     []string
+		addr     string
+		driver   string
+		sqlite   string
+		tokenTTL time.Duration
+	}{
+		{name: "defaults", addr: ":8080", driver: "postgres", sqlite: "snippets.db", tokenTTL: 15 * time.Minute},
+		{name: "file", file: true, addr: ":1000", driver: "sqlite", sqlite: "file.db", tokenTTL: time.Minute},
+		{
+			name: "env over file",
+			file: true,
+			env:  map[string]string{"SNIPPET_ADDR": ":2000", "SNIPPET_TOKEN_TTL": "2m"},
+			addr: ":2000", driver: "sqlite", sqlite: "file.db", tokenTTL: 2 * time.Minute,
+		},
+		{
+			name: "flags over env",
+			file: true,
+			env:  map[string]string{"SNIPPET_ADDR": ":2000", "SNIPPET_SQLITE_PATH": "env.db"},
+			args: []string{"-addr", ":3000", "-token-ttl", "3m"},
+			addr: ":3000", driver: "sqlite", sqlite: "env.db", tokenTTL: 3 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file {
+				args = append([]string{"-config", writeConfig(t, file)}, args...)
+			}
+
+			cfg, rest, err := Load(append(args, "migrate", "up"))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if strings.Join(rest, " ") != "migrate up" {
+				t.Errorf("remaining args = %q, want migrate up", rest)
+			}
+			if cfg.Server.Addr != tt.addr || cfg.Database.Driver != tt.driver || cfg.Database.SQLitePath != tt.sqlite || cfg.Auth.TokenTTL != tt.tokenTTL {
+				t.Errorf("got addr %q, driver %q, sqlite path %q, token TTL %v; want %q, %q, %q, %v",
+					cfg.Server.Addr, cfg.Database.Driver, cfg.Database.SQLitePath, cfg.Auth.TokenTTL,
+					tt.addr, tt.driver, tt.sqlite, tt.tokenTTL)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "unknown key", file: "server:\n  adress: \":1\"\n", want: "field adress not found"},
+		{name: "invalid env", env: map[string]string{"SNIPPET_TOKEN_TTL": "soon"}, want: "invalid SNIPPET_TOKEN_TTL"},
+		{name: "invalid flag", args: []string{"-max-code-length", "many"}, want: "max-code-length"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/config.yaml"}, want: "open config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfig(t, tt.file)}, args...)
+			}
+			if _, _, err := Load(args); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load: err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := strings.Repeat("s", minSecretLength)
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{name: "valid", change: func(c *Config) {}},
+		{name: "empty secret", change: func(c *Config) { c.Auth.JWTSecret = "" }, want: "not set"},
+		{name: "legacy secret", change: func(c *Config) { c.Auth.JWTSecret = legacySecret }, want: "default value"},
+		{name: "short secret", change: func(c *Config) { c.Auth.JWTSecret = valid[1:] }, want: "at least 32 bytes"},
+		{name: "empty address", change: func(c *Config) { c.Server.Addr = " " }, want: "address"},
+		{name: "unknown driver", change: func(c *Config) { c.Database.Driver = "mysql" }, want: "unknown database driver"},
+		{name: "empty SQLite path", change: func(c *Config) { c.Database.Driver, c.Database.SQLitePath = "sqlite", "" }, want: "SQLite path"},
+		{name: "zero TTL", change: func(c *Config) { c.Auth.RefreshTTL = 0 }, want: "TTLs must be positive"},
+		{name: "zero limit", change: func(c *Config) { c.Limits.MaxTagLength = 0 }, want: "limits must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Auth.JWTSecret = valid
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate: err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// ImportConflict decides what Import does with an archived folder or snippet
+// whose ID is already used by one of the user's own
+type ImportConflict int
+
+const (
+	// ImportSkip keeps the existing object and ignores the archived one
+	ImportSkip ImportConflict = iota
+	// ImportOverwrite replaces the existing object with the archived one
+	ImportOverwrite
+	// ImportDuplicate imports the archived object under a new ID
+	ImportDuplicate
+)
+
+// ImportOptions controls Import
+type ImportOptions struct {
+	OnConflict ImportConflict
+	// NewIDs imports every object under a new ID, so that nothing
+	// conflicts. Objects whose ID belongs to another user always get a
+	// new ID.
+	NewIDs bool
+}
+
+// ErrInvalidArchive is returned for an archive that cannot be imported, such
+// as one whose folders refer to a parent missing from the archive
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// Export reads the whole library of a user into an archive
+func Export(s Store, userID uuid.UUID) (models.Archive, error) {
+	folders, err := s.GetFoldersByUser(userID)
+	if err != nil {
+		return models.Archive{}, err
+	}
+	snippets, err := s.GetAll(userID)
+	if err != nil {
+		return models.Archive{}, err
+	}
+
+	archive := models.Archive{
+		Version:    models.ArchiveVersion,
+		ExportedAt: time.Now().UTC(),
+		Folders:    make([]models.ArchiveFolder, 0, len(folders)),
+		Snippets:   make([]models.ArchiveSnippet, 0, len(snippets)),
+	}
+	for _, f := range folders {
+		archive.Folders = append(archive.Folders, models.ArchiveFolder{
+			ID:        f.ID,
+			Name:      f.Name,
+			ParentID:  f.ParentID,
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		})
+	}
+	for _, snip := range snippets {
+		tags := snip.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		archive.Snippets = append(archive.Snippets, models.ArchiveSnippet{
+			ID:          snip.ID,
+			Title:       snip.Title,
+			Description: snip.Description,
+			Language:    snip.Language,
+			Code:        snip.Code,
+			FolderID:    snip.FolderID,
+			Tags:        tags,
+			CreatedAt:   snip.CreatedAt,
+			UpdatedAt:   snip.UpdatedAt,
+		})
+	}
+	// Oldest first, so that archives of the same library compare equal
+	sort.SliceStable(archive.Folders, func(i, j int) bool {
+		return archive.Folders[i].CreatedAt.Before(archive.Folders[j].CreatedAt)
+	})
+	sort.SliceStable(archive.Snippets, func(i, j int) bool {
+		return archive.Snippets[i].CreatedAt.Before(archive.Snippets[j].CreatedAt)
+	})
+	return archive, nil
+}
+
+type importAction int
+
+const (
+	importCreate importAction = iota
+	importUpdate
+)
+
+// importPlan lists the writes of an import. Folders come before their
+// subfolders and every ID is already remapped.
+type importPlan struct {
+	folders  []plannedFolder
+	snippets []plannedSnippet
+	result   models.ImportResult
+}
+
+type plannedFolder struct {
+	action importAction
+	folder models.Folder
+}
+
+type plannedSnippet struct {
+	action  importAction
+	snippet models.Snippet
+}
+
+// ownerFunc looks up the owner of a folder or snippet by ID
+type ownerFunc func(table string, id uuid.UUID) (owner uuid.UUID, exists bool, err error)
+
+// planImport checks an archive and decides, object by object, whether it
+// is created, updated or skipped and under which ID. It is shared by every
+// backend, which then only has to carry out the plan.
+func planImport(userID uuid.UUID, archive models.Archive, opts ImportOptions, ownerOf ownerFunc) (importPlan, error) {
+	plan := importPlan{result: models.ImportResult{IDs: make(map[uuid.UUID]uuid.UUID)}}
+	if archive.Version != models.ArchiveVersion {
+		return plan, fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, archive.Version)
+	}
+	folders, err := sortArchiveFolders(archive.Folders)
+	if err != nil {
+		return plan, err
+	}
+	now := time.Now().UTC()
+
+	// ids maps every archive ID to the ID the object ends up with
+	ids := make(map[uuid.UUID]uuid.UUID)
+	resolve := func(table string, id uuid.UUID, counts *models.ImportCounts) (uuid.UUID, importAction, bool, error) {
+		if id == uuid.Nil || opts.NewIDs {
+			return uuid.New(), importCreate, true, nil
+		}
+		owner, exists, err := ownerOf(table, id)
+		switch {
+		case err != nil:
+			return uuid.Nil, 0, false, err
+		case !exists:
+			return id, importCreate, true, nil
+		case owner != userID:
+			return uuid.New(), importCreate, true, nil
+		case opts.OnConflict == ImportSkip:
+			counts.Skipped++
+			return id, 0, false, nil
+		case opts.OnConflict == ImportOverwrite:
+			return id, importUpdate, true, nil
+		default:
+			return uuid.New(), importCreate, true, nil
+		}
+	}
+	remap := func(id *uuid.UUID) *uuid.UUID {
+		if id == nil {
+			return nil
+		}
+		newID := ids[*id]
+		return &newID
+	}
+
+	for _, f := range folders {
+		id, action, write, err := resolve("folders", f.ID, &plan.result.Folders)
+		if err != nil {
+			return plan, err
+		}
+		ids[f.ID] = id
+		if id != f.ID {
+			plan.result.IDs[f.ID] = id
+		}
+		if !write {
+			continue
+		}
+		createdAt, updatedAt := archiveTimes(f.CreatedAt, f.UpdatedAt, now)
+		plan.folders = append(plan.folders, plannedFolder{action, models.Folder{
+			ID:        id,
+			Name:      f.Name,
+			ParentID:  remap(f.ParentID),
+			UserID:    userID,
+			CreatedAt: createdAt,
+			UpdatedAt: updatedAt,
+		}})
+		if action == importCreate {
+			plan.result.Folders.Created++
+		} else {
+			plan.result.Folders.Updated++
+		}
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for _, s := range archive.Snippets {
+		if s.ID != uuid.Nil {
+			if seen[s.ID] {
+				return plan, fmt.Errorf("%w: snippet %s is listed twice", ErrInvalidArchive, s.ID)
+			}
+			seen[s.ID] = true
+		}
+		if s.FolderID != nil {
+			if _, ok := ids[*s.FolderID]; !ok {
+				return plan, fmt.Errorf("%w: snippet %s is in folder %s, which is not in the archive", ErrInvalidArchive, s.ID, *s.FolderID)
+			}
+		}
+		id, action, write, err := resolve("snippets", s.ID, &plan.result.Snippets)
+		if err != nil {
+			return plan, err
+		}
+		if s.ID != uuid.Nil && id != s.ID {
+			plan.result.IDs[s.ID] = id
+		}
+		if !write {
+			continue
+		}
+		createdAt, updatedAt := archiveTimes(s.CreatedAt, s.UpdatedAt, now)
+		plan.snippets = append(plan.snippets, plannedSnippet{action, models.Snippet{
+			ID:          id,
+			Title:       s.Title,
+			Description: s.Description,
+			Language:    s.Language,
+			Code:        s.Code,
+			UserID:      userID,
+			FolderID:    remap(s.FolderID),
+			Tags:        s.Tags,
+			CreatedAt:   createdAt,
+			UpdatedAt:   updatedAt,
+		}})
+		if action == importCreate {
+			plan.result.Snippets.Created++
+		} else {
+			plan.result.Snippets.Updated++
+		}
+	}
+	return plan, nil
+}
+
+// sortArchiveFolders returns the folders with every parent before its
+// subfolders, and rejects duplicate IDs, parents missing from the archive
+// and cycles
+func sortArchiveFolders(folders []models.ArchiveFolder) ([]models.ArchiveFolder, error) {
+	byID := make(map[uuid.UUID]models.ArchiveFolder, len(folders))
+	for _, f := range folders {
+		if f.ID == uuid.Nil {
+			return nil, fmt.Errorf("%w: folder %q has no ID", ErrInvalidArchive, f.Name)
+		}
+		if _, ok := byID[f.ID]; ok {
+			return nil, fmt.Errorf("%w: folder %s is listed twice", ErrInvalidArchive, f.ID)
+		}
+		byID[f.ID] = f
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[uuid.UUID]int, len(folders))
+	sorted := make([]models.ArchiveFolder, 0, len(folders))
+	var visit func(f models.ArchiveFolder) error
+	visit = func(f models.ArchiveFolder) error {
+		switch state[f.ID] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: folder %s is its own ancestor", ErrInvalidArchive, f.ID)
+		}
+		state[f.ID] = visiting
+		if f.ParentID != nil {
+			parent, ok := byID[*f.ParentID]
+			if !ok {
+				return fmt.Errorf("%w: parent %s of folder %s is not in the archive", ErrInvalidArchive, *f.ParentID, f.ID)
+			}
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[f.ID] = done
+		sorted = append(sorted, f)
+		return nil
+	}
+	for _, f := range folders {
+		if err := visit(f); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// archiveTimes fills in missing timestamps. Times are kept in UTC, which
+// SQLite needs to sort them.
+func archiveTimes(createdAt, updatedAt, now time.Time) (time.Time, time.Time) {
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+	return createdAt.UTC(), updatedAt.UTC()
+}
+
+// importArchive plans and carries out an import in the transaction of the
+// SQL backends; see queryer for the placeholder rules
+func importArchive(tx *sql.Tx, userID uuid.UUID, archive models.Archive, opts ImportOptions) (models.ImportResult, error) {
+	ownerOf := func(table string, id uuid.UUID) (uuid.UUID, bool, error) {
+		var owner uuid.UUID
+		err := tx.QueryRow("SELECT user_id FROM "+table+" WHERE id = $1", id).Scan(&owner)
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, false, nil
+		}
+		return owner, err == nil, err
+	}
+	plan, err := planImport(userID, archive, opts, ownerOf)
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+
+	for _, p := range plan.folders {
+		f := p.folder
+		if p.action == importCreate {
+			_, err = tx.Exec(
+				"INSERT INTO folders (id, name, parent_id, user_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)",
+				f.ID, f.Name, f.ParentID, f.UserID, f.CreatedAt, f.UpdatedAt,
+			)
+		} else {
+			_, err = tx.Exec(
+				"UPDATE folders SET name = $1, parent_id = $2, updated_at = $3 WHERE id = $4 AND user_id = $5",
+				f.Name, f.ParentID, f.UpdatedAt, f.ID, f.UserID,
+			)
+		}
+		if err != nil {
+			return models.ImportResult{}, err
+		}
+	}
+
+	for _, p := range plan.snippets {
+		s := p.snippet
+		if p.action == importCreate {
+			_, err = tx.Exec(
+				"INSERT INTO snippets (id, title, description, language, code, user_id, folder_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+				s.ID, s.Title, s.Description, s.Language, s.Code, s.UserID, s.FolderID, s.CreatedAt, s.UpdatedAt,
+			)
+		} else {
+			_, err = tx.Exec(
+				"UPDATE snippets SET title = $1, description = $2, language = $3, code = $4, folder_id = $5, updated_at = $6 WHERE id = $7 AND user_id = $8",
+				s.Title, s.Description, s.Language, s.Code, s.FolderID, s.UpdatedAt, s.ID, s.UserID,
+			)
+			if err == nil {
+				_, err = tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = $1", s.ID)
+			}
+		}
+		if err != nil {
+			return models.ImportResult{}, err
+		}
+		if err := attachTags(tx, userID, s.ID, s.Tags); err != nil {
+			return models.ImportResult{}, err
+		}
+		if err := recordRevision(tx, s); err != nil {
+			return models.ImportResult{}, err
+		}
+	}
+
+	if err := deleteOrphanTags(tx, userID); err != nil {
+		return models.ImportResult{}, err
+	}
+	return plan.result, nil
+}
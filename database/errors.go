@@ -0,0 +1,45 @@
+package database
+
+import "errors"
+
+// Errors returned by the storage layer when an object does not exist or is
+// not owned by the requesting user. Handlers map these to 404 responses so
+// that callers cannot probe for other users' objects.
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrSnippetNotFound     = errors.New("snippet not found")
+	ErrFolderNotFound      = errors.New("folder not found")
+	ErrRevisionNotFound    = errors.New("revision not found")
+	ErrTagNotFound         = errors.New("tag not found")
+	ErrWorkspaceNotFound   = errors.New("workspace not found")
+	ErrMemberNotFound      = errors.New("member not found")
+	ErrFolderShareNotFound = errors.New("folder share not found")
+)
+
+// ErrInvalidCursor is returned for a pagination cursor that is malformed or
+// was issued for a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrFolderCycle is returned when moving a folder into itself or one of its
+// subfolders
+var ErrFolderCycle = errors.New("folder cannot be moved into itself or one of its subfolders")
+
+// ErrTagExists is returned when renaming a tag to the name of another tag
+// of the same user
+var ErrTagExists = errors.New("tag already exists")
+
+// ErrShareLinkNotFound is returned for share links that do not exist, have
+// expired or have used up their views
+var ErrShareLinkNotFound = errors.New("share link not found")
+
+// ErrMemberExists is returned when adding a user to a workspace they are
+// already a member of
+var ErrMemberExists = errors.New("user is already a member of the workspace")
+
+// ErrLastOwner is returned when removing or demoting the only owner of a
+// workspace
+var ErrLastOwner = errors.New("workspace must keep an owner")
+
+// ErrSessionNotFound is returned for sessions that do not exist or have
+// expired, and for refresh tokens that were already rotated
+var ErrSessionNotFound = errors.New("session not found")
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// FolderDeleteMode decides what DeleteFolder does with the content of the
+// deleted folder
+type FolderDeleteMode int
+
+const (
+	// MoveContentsToParent moves the folder's snippets and subfolders to its
+	// parent, or to the root, before deleting it
+	MoveContentsToParent FolderDeleteMode = iota
+	// DeleteContents deletes the folder's subfolders and every snippet in
+	// the folder or below it
+	DeleteContents
+)
+
+// FolderTreeOptions selects what GetFolderTree returns
+type FolderTreeOptions struct {
+	// MaxDepth is the number of levels returned; 1 returns the top-level
+	// folders only. Zero means no limit.
+	MaxDepth int
+	// WithSnippets fills in the snippets of each folder
+	WithSnippets bool
+}
+
+// The queries below are shared by PostgresStorage and SQLiteStorage; see
+// queryer for the placeholder rules.
+
+func getFolder(q queryer, userID, folderID uuid.UUID) (models.Folder, error) {
+	var folder models.Folder
+	err := q.QueryRow(
+		"SELECT id, name, parent_id, user_id, created_at, updated_at FROM folders WHERE id = $1 AND user_id = $2",
+		folderID,
+		userID,
+	).Scan(&folder.ID, &folder.Name, &folder.ParentID, &folder.UserID, &folder.CreatedAt, &folder.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Folder{}, ErrFolderNotFound
+	}
+	return folder, err
+}
+
+// checkFolderMove returns ErrFolderCycle if parentID is the folder itself or
+// one of its descendants, found by walking up from the new parent
+func checkFolderMove(q queryer, folderID uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+	var cycle bool
+	err := q.QueryRow(`
+        WITH RECURSIVE ancestors (id, parent_id) AS (
+            SELECT id, parent_id FROM folders WHERE id = $1
+            UNION
+            SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+        )
+        SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+    `, *parentID, folderID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrFolderCycle
+	}
+	return nil
+}
+
+// updateFolder renames and moves a folder inside tx after checking that the
+// new parent belongs to the same user and is not below the folder
+func updateFolder(tx *sql.Tx, folder models.Folder, now time.Time) error {
+	if err := checkFolderOwner(tx, folder.UserID, &folder.ID); err != nil {
+		return err
+	}
+	if err := checkFolderOwner(tx, folder.UserID, folder.ParentID); err != nil {
+		return err
+	}
+	if err := checkFolderMove(tx, folder.ID, folder.ParentID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(
+		"UPDATE folders SET name = $1, parent_id = $2, updated_at = $3 WHERE id = $4",
+		folder.Name,
+		folder.ParentID,
+		now,
+		folder.ID,
+	)
+	return err
+}
+
+// deleteFolder deletes a folder inside tx. The foreign keys on
+// folders.parent_id and snippets.folder_id cascade to the folder's content,
+// so MoveContentsToParent moves it out of the way first.
+func deleteFolder(tx *sql.Tx, userID, folderID uuid.UUID, mode FolderDeleteMode) error {
+	folder, err := getFolder(tx, userID, folderID)
+	if err != nil {
+		return err
+	}
+
+	if mode == MoveContentsToParent {
+		if _, err := tx.Exec("UPDATE snippets SET folder_id = $1 WHERE folder_id = $2", folder.ParentID, folderID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE folders SET parent_id = $1 WHERE parent_id = $2", folder.ParentID, folderID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM folders WHERE id = $1", folderID); err != nil {
+		return err
+	}
+	return deleteOrphanTags(tx, userID)
+}
+
+// getFolderTree loads the folders of a user level by level with a recursive
+// query and nests them
+func getFolderTree(q *sql.DB, userID uuid.UUID, opts FolderTreeOptions) ([]models.FolderNode, error) {
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = math.MaxInt32
+	}
+	rows, err := q.Query(`
+        WITH RECURSIVE tree (id, depth) AS (
+            SELECT id, 0 FROM folders WHERE user_id = $1 AND parent_id IS NULL
+            UNION ALL
+            SELECT f.id, t.depth + 1 FROM folders f JOIN tree t ON f.parent_id = t.id
+            WHERE t.depth + 1 < $2
+        )
+        SELECT f.id, f.name, f.parent_id, f.user_id, f.created_at, f.updated_at, t.depth,
+            (SELECT COUNT(*) FROM snippets s WHERE s.folder_id = f.id)
+        FROM tree t
+        JOIN folders f ON f.id = t.id
+    `, userID, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []models.FolderNode
+	for rows.Next() {
+		var node models.FolderNode
+		if err := rows.Scan(&node.ID, &node.Name, &node.ParentID, &node.UserID, &node.CreatedAt, &node.UpdatedAt,
+			&node.Depth, &node.SnippetCount); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if opts.WithSnippets && len(nodes) > 0 {
+		rows, err := q.Query(
+			"SELECT id, title, language, updated_at, folder_id FROM snippets WHERE user_id = $1 AND folder_id IS NOT NULL",
+			userID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		index := make(map[uuid.UUID]int, len(nodes))
+		for i, node := range nodes {
+			index[node.ID] = i
+		}
+		for rows.Next() {
+			var summary models.SnippetSummary
+			var folderID uuid.UUID
+			if err := rows.Scan(&summary.ID, &summary.Title, &summary.Language, &summary.UpdatedAt, &folderID); err != nil {
+				return nil, err
+			}
+			if i, ok := index[folderID]; ok {
+				nodes[i].Snippets = append(nodes[i].Snippets, summary)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return nestFolders(nodes), nil
+}
+
+// nestFolders arranges a flat list of folder nodes into trees sorted by
+// name. Nodes whose parent is not in the list become roots.
+func nestFolders(nodes []models.FolderNode) []models.FolderNode {
+	byName := func(a, b string) bool {
+		if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+			return la < lb
+		}
+		return a < b
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return byName(nodes[i].Name, nodes[j].Name)
+	})
+
+	present := make(map[uuid.UUID]bool, len(nodes))
+	for _, node := range nodes {
+		present[node.ID] = true
+		sort.Slice(node.Snippets, func(i, j int) bool {
+			return byName(node.Snippets[i].Title, node.Snippets[j].Title)
+		})
+	}
+	children := make(map[uuid.UUID][]models.FolderNode)
+	var roots []models.FolderNode
+	for _, node := range nodes {
+		if node.ParentID != nil && present[*node.ParentID] {
+			children[*node.ParentID] = append(children[*node.ParentID], node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	var attach func(nodes []models.FolderNode) []models.FolderNode
+	attach = func(nodes []models.FolderNode) []models.FolderNode {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		if nodes == nil {
+			return []models.FolderNode{}
+		}
+		return nodes
+	}
+	return attach(roots)
+}
+
+// getFolderPath walks up from a folder to the top level
+func getFolderPath(q *sql.DB, userID, folderID uuid.UUID) ([]models.Folder, error) {
+	rows, err := q.Query(`
+        WITH RECURSIVE path (id, parent_id, depth) AS (
+            SELECT id, parent_id, 0 FROM folders WHERE id = $1 AND user_id = $2
+            UNION ALL
+            SELECT f.id, f.parent_id, p.depth + 1 FROM folders f JOIN path p ON f.id = p.parent_id
+        )
+        SELECT f.id, f.name, f.parent_id, f.user_id, f.created_at, f.updated_at
+        FROM path p
+        JOIN folders f ON f.id = p.id
+        ORDER BY p.depth DESC
+    `, folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := scanFolders(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(folders) == 0 {
+		return nil, ErrFolderNotFound
+	}
+	return folders, nil
+}
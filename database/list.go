@@ -0,0 +1,246 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// SortField is a snippet column a listing can be ordered by
+type SortField string
+
+const (
+	SortCreatedAt SortField = "created_at"
+	SortUpdatedAt SortField = "updated_at"
+	SortTitle     SortField = "title"
+)
+
+// ListOptions selects one page of a user's snippets. Results are ordered by
+// Sort, with ties broken by ID, and the zero value lists everything newest
+// first.
+type ListOptions struct {
+	Sort      SortField
+	Ascending bool
+	// Limit is the page size; zero returns all remaining snippets
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+
+	Language string
+	Tags     []string
+	// AnyTag matches snippets carrying any of Tags instead of all of them
+	AnyTag        bool
+	FolderID      *uuid.UUID
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// cursor is the position of the last snippet of a page. It records the
+// ordering it was made for so that it cannot be replayed against another.
+type cursor struct {
+	Sort      SortField `json:"s"`
+	Ascending bool      `json:"a,omitempty"`
+	Key       string    `json:"k"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (o ListOptions) sortField() SortField {
+	switch o.Sort {
+	case SortUpdatedAt, SortTitle:
+		return o.Sort
+	default:
+		return SortCreatedAt
+	}
+}
+
+// sortValue returns the value the snippet is ordered by
+func (o ListOptions) sortValue(snip models.Snippet) any {
+	switch o.sortField() {
+	case SortTitle:
+		return snip.Title
+	case SortUpdatedAt:
+		return snip.UpdatedAt
+	default:
+		return snip.CreatedAt
+	}
+}
+
+// compare orders a snippet against a sort value and ID in ascending order,
+// the way the SQL backends do
+func (o ListOptions) compare(snip models.Snippet, value any, id uuid.UUID) int {
+	var c int
+	switch v := value.(type) {
+	case string:
+		c = strings.Compare(snip.Title, v)
+	case time.Time:
+		c = o.sortValue(snip).(time.Time).Compare(v)
+	}
+	if c == 0 {
+		c = strings.Compare(snip.ID.String(), id.String())
+	}
+	return c
+}
+
+// decodeCursor returns the position after which the page starts, or nil for
+// the first page
+func (o ListOptions) decodeCursor() (*cursor, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != o.sortField() || c.Ascending != o.Ascending {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != SortTitle {
+		if _, err := time.Parse(time.RFC3339Nano, c.Key); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// value returns the cursor key as the type of its column
+func (c *cursor) value() any {
+	if c.Sort == SortTitle {
+		return c.Key
+	}
+	t, _ := time.Parse(time.RFC3339Nano, c.Key)
+	return t
+}
+
+func cursorKey(value any) string {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return value.(string)
+}
+
+// page trims a result fetched with one extra row to the requested limit and
+// sets the cursor of the next page
+func (o ListOptions) page(snippets []models.Snippet) models.SnippetPage {
+	if o.Limit <= 0 || len(snippets) <= o.Limit {
+		return models.SnippetPage{Snippets: snippets}
+	}
+	snippets = snippets[:o.Limit]
+	last := snippets[len(snippets)-1]
+	raw, _ := json.Marshal(cursor{
+		Sort:      o.sortField(),
+		Ascending: o.Ascending,
+		Key:       cursorKey(o.sortValue(last)),
+		ID:        last.ID,
+	})
+	return models.SnippetPage{
+		Snippets:   snippets,
+		NextCursor: base64.RawURLEncoding.EncodeToString(raw),
+	}
+}
+
+// listQuery builds the listing query shared by the SQL backends. It numbers
+// its parameters in order of first use, which SQLite binds positionally.
+// Times are bound in UTC, the zone SQLite stores them in, so that they
+// compare correctly as text.
+func listQuery(userID uuid.UUID, opts ListOptions, after *cursor) (string, []any) {
+	args := []any{userID}
+	arg := func(v any) string {
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC()
+		}
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := []string{"s.user_id = $1"}
+	if opts.Language != "" {
+		where = append(where, "lower(s.language) = lower("+arg(opts.Language)+")")
+	}
+	if opts.FolderID != nil {
+		where = append(where, "s.folder_id = "+arg(*opts.FolderID))
+	}
+	if !opts.CreatedAfter.IsZero() {
+		where = append(where, "s.created_at > "+arg(opts.CreatedAfter))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where = append(where, "s.created_at < "+arg(opts.CreatedBefore))
+	}
+	if len(opts.Tags) > 0 {
+		names := make([]string, len(opts.Tags))
+		for i, tag := range opts.Tags {
+			names[i] = arg(tag)
+		}
+		// With all semantics every tag must be present, so the number of
+		// distinct matching tags has to equal the number asked for
+		need := "1"
+		if !opts.AnyTag {
+			need = arg(len(uniqueStrings(opts.Tags)))
+		}
+		where = append(where, `(
+            SELECT count(DISTINCT t.name) FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+            WHERE st.snippet_id = s.id AND t.name IN (`+strings.Join(names, ", ")+`)) >= `+need)
+	}
+
+	column := "s." + string(opts.sortField())
+	cmp, dir := "<", "DESC"
+	if opts.Ascending {
+		cmp, dir = ">", "ASC"
+	}
+	if after != nil {
+		key, id := arg(after.value()), arg(after.ID)
+		where = append(where, fmt.Sprintf("(%s %s %s OR (%s = %s AND s.id %s %s))", column, cmp, key, column, key, cmp, id))
+	}
+
+	query := `
+        SELECT s.id, s.title, s.description, s.language, s.code, s.user_id, s.folder_id, s.created_at, s.updated_at
+        FROM snippets s
+        WHERE ` + strings.Join(where, " AND ") + fmt.Sprintf(`
+        ORDER BY %s %s, s.id %s`, column, dir, dir)
+	if opts.Limit > 0 {
+		query += " LIMIT " + arg(opts.Limit+1)
+	}
+	return query, args
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// countLanguages is shared by PostgresStorage and SQLiteStorage
+func countLanguages(db *sql.DB, userID uuid.UUID) (map[string]int, error) {
+	rows, err := db.Query("SELECT language, COUNT(*) FROM snippets WHERE user_id = $1 GROUP BY language", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var language string
+		var count int
+		if err := rows.Scan(&language, &count); err != nil {
+			return nil, err
+		}
+		counts[language] += count
+	}
+	return counts, rows.Err()
+}
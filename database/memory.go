@@ -0,0 +1,1450 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"snippet-manager-go/models"
+)
+
+// MemoryStorage is a Store that keeps everything in process memory. It
+// mirrors the semantics of PostgresStorage and is meant for tests and for
+// embedding the handlers without a database.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	users       map[uuid.UUID]models.User
+	snippets    map[uuid.UUID]models.Snippet
+	tags        map[tagKey]uuid.UUID
+	tagNames    map[uuid.UUID]string
+	snippetTags map[uuid.UUID][]uuid.UUID
+	revisions   map[uuid.UUID][]models.Revision
+	folders     map[uuid.UUID]models.Folder
+	// shareLinks holds links by the hash of their token, with Password set
+	// to the hash of their password
+	shareLinks map[string]models.ShareLink
+	// folderShares leave Username unset; it is filled in when read
+	folderShares map[folderShareKey]models.FolderShare
+	// workspaces and members leave Role and Username unset; they are filled
+	// in when read
+	workspaces map[uuid.UUID]models.Workspace
+	members    map[memberKey]models.WorkspaceMember
+	// sessions holds sessions by the hash of their refresh token
+	sessions map[string]models.Session
+	// revokedTokens holds the expiry of each revoked access token
+	revokedTokens map[uuid.UUID]time.Time
+}
+
+// tagKey identifies a tag by owner and name
+type tagKey struct {
+	userID uuid.UUID
+	name   string
+}
+
+// folderShareKey identifies a grant by folder and user
+type folderShareKey struct {
+	folderID uuid.UUID
+	userID   uuid.UUID
+}
+
+// memberKey identifies a membership by workspace and user
+type memberKey struct {
+	workspaceID uuid.UUID
+	userID      uuid.UUID
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		users:         make(map[uuid.UUID]models.User),
+		snippets:      make(map[uuid.UUID]models.Snippet),
+		tags:          make(map[tagKey]uuid.UUID),
+		tagNames:      make(map[uuid.UUID]string),
+		snippetTags:   make(map[uuid.UUID][]uuid.UUID),
+		revisions:     make(map[uuid.UUID][]models.Revision),
+		folders:       make(map[uuid.UUID]models.Folder),
+		shareLinks:    make(map[string]models.ShareLink),
+		folderShares:  make(map[folderShareKey]models.FolderShare),
+		workspaces:    make(map[uuid.UUID]models.Workspace),
+		members:       make(map[memberKey]models.WorkspaceMember),
+		sessions:      make(map[string]models.Session),
+		revokedTokens: make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (s *MemoryStorage) CreateUser(user *models.User) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == user.Username || u.Email == user.Email {
+			return errors.New("username or email already exists")
+		}
+	}
+
+	user.ID = uuid.New()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+
+	stored := *user
+	stored.Password = string(hashedPassword)
+	s.users[stored.ID] = stored
+	return nil
+}
+
+func (s *MemoryStorage) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Username == username {
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (s *MemoryStorage) GetUserByEmail(email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (s *MemoryStorage) GetUserByID(id uuid.UUID) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	u.Password = ""
+	return &u, nil
+}
+
+func (s *MemoryStorage) Create(snippet models.Snippet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[snippet.UserID]; !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := s.snippets[snippet.ID]; ok {
+		return errors.New("snippet already exists")
+	}
+	if err := s.checkFolderOwner(snippet.UserID, snippet.FolderID); err != nil {
+		return err
+	}
+
+	snippet.CreatedAt = time.Now()
+	snippet.UpdatedAt = time.Now()
+	snippet.FolderID = copyID(snippet.FolderID)
+
+	s.snippets[snippet.ID] = withoutTags(snippet)
+	s.setTags(snippet.UserID, snippet.ID, snippet.Tags)
+	s.recordRevision(snippet.ID)
+	return nil
+}
+
+func (s *MemoryStorage) Update(snippet models.Snippet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.snippets[snippet.ID]
+	if !ok || existing.UserID != snippet.UserID {
+		return ErrSnippetNotFound
+	}
+	if err := s.checkFolderOwner(snippet.UserID, snippet.FolderID); err != nil {
+		return err
+	}
+
+	existing.Title = snippet.Title
+	existing.Description = snippet.Description
+	existing.Language = snippet.Language
+	existing.Code = snippet.Code
+	existing.FolderID = copyID(snippet.FolderID)
+	existing.UpdatedAt = time.Now()
+
+	s.snippets[snippet.ID] = existing
+	s.setTags(snippet.UserID, snippet.ID, snippet.Tags)
+	s.deleteOrphanTags(snippet.UserID)
+	s.recordRevision(snippet.ID)
+	return nil
+}
+
+func (s *MemoryStorage) GetAll(userID uuid.UUID) ([]models.Snippet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var snippets []models.Snippet
+	for _, snip := range s.snippets {
+		if snip.UserID == userID {
+			snippets = append(snippets, s.withTags(snip))
+		}
+	}
+	return snippets, nil
+}
+
+func (s *MemoryStorage) CountLanguages(userID uuid.UUID) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, snip := range s.snippets {
+		if snip.UserID == userID {
+			counts[snip.Language]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *MemoryStorage) List(userID uuid.UUID, opts ListOptions) (models.SnippetPage, error) {
+	after, err := opts.decodeCursor()
+	if err != nil {
+		return models.SnippetPage{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var snippets []models.Snippet
+	for _, snip := range s.snippets {
+		if snip.UserID != userID {
+			continue
+		}
+		if opts.Language != "" && !strings.EqualFold(snip.Language, opts.Language) {
+			continue
+		}
+		if opts.FolderID != nil && (snip.FolderID == nil || *snip.FolderID != *opts.FolderID) {
+			continue
+		}
+		if !opts.CreatedAfter.IsZero() && !snip.CreatedAt.After(opts.CreatedAfter) {
+			continue
+		}
+		if !opts.CreatedBefore.IsZero() && !snip.CreatedAt.Before(opts.CreatedBefore) {
+			continue
+		}
+		snip = s.withTags(snip)
+		if len(opts.Tags) > 0 {
+			if opts.AnyTag && !hasAnyTag(snip.Tags, opts.Tags) || !opts.AnyTag && !hasAllTags(snip.Tags, opts.Tags) {
+				continue
+			}
+		}
+		if after != nil {
+			c := opts.compare(snip, after.value(), after.ID)
+			if opts.Ascending && c <= 0 || !opts.Ascending && c >= 0 {
+				continue
+			}
+		}
+		snippets = append(snippets, snip)
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		c := opts.compare(snippets[i], opts.sortValue(snippets[j]), snippets[j].ID)
+		if opts.Ascending {
+			return c < 0
+		}
+		return c > 0
+	})
+	if opts.Limit > 0 && len(snippets) > opts.Limit+1 {
+		snippets = snippets[:opts.Limit+1]
+	}
+	return opts.page(snippets), nil
+}
+
+func (s *MemoryStorage) Get(userID, id uuid.UUID) (models.Snippet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snip, ok := s.snippets[id]
+	if !ok || snip.UserID != userID {
+		return models.Snippet{}, ErrSnippetNotFound
+	}
+	return s.withTags(snip), nil
+}
+
+func (s *MemoryStorage) Delete(userID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snip, ok := s.snippets[id]
+	if !ok || snip.UserID != userID {
+		return ErrSnippetNotFound
+	}
+	delete(s.snippets, id)
+	delete(s.snippetTags, id)
+	delete(s.revisions, id)
+	s.deleteOrphanTags(userID)
+	return nil
+}
+
+func (s *MemoryStorage) ListRevisions(userID, snippetID uuid.UUID) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkSnippetOwner(userID, snippetID); err != nil {
+		return nil, err
+	}
+	history := s.revisions[snippetID]
+	revisions := make([]models.Revision, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		revisions = append(revisions, copyRevision(history[i]))
+	}
+	return revisions, nil
+}
+
+func (s *MemoryStorage) GetRevision(userID, snippetID uuid.UUID, number int) (models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkSnippetOwner(userID, snippetID); err != nil {
+		return models.Revision{}, err
+	}
+	history := s.revisions[snippetID]
+	if number < 1 || number > len(history) {
+		return models.Revision{}, ErrRevisionNotFound
+	}
+	return copyRevision(history[number-1]), nil
+}
+
+func (s *MemoryStorage) RestoreRevision(userID, snippetID uuid.UUID, number int) (models.Snippet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkSnippetOwner(userID, snippetID); err != nil {
+		return models.Snippet{}, err
+	}
+	history := s.revisions[snippetID]
+	if number < 1 || number > len(history) {
+		return models.Snippet{}, ErrRevisionNotFound
+	}
+	rev := history[number-1]
+
+	snip := s.snippets[snippetID]
+	snip.Title = rev.Title
+	snip.Description = rev.Description
+	snip.Language = rev.Language
+	snip.Code = rev.Code
+	snip.UpdatedAt = time.Now()
+	s.snippets[snippetID] = snip
+	s.setTags(userID, snippetID, rev.Tags)
+	s.deleteOrphanTags(userID)
+	s.recordRevision(snippetID)
+	return s.withTags(snip), nil
+}
+
+func (s *MemoryStorage) Search(userID uuid.UUID, opts SearchOptions) ([]models.SearchResult, error) {
+	query := parseSearchQuery(opts.Query)
+	if query.empty() {
+		return nil, nil
+	}
+	pattern := termPattern(query.terms())
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []models.SearchResult
+	for _, snip := range s.snippets {
+		if snip.UserID != userID {
+			continue
+		}
+		if opts.Language != "" && !strings.EqualFold(snip.Language, opts.Language) {
+			continue
+		}
+		if opts.FolderID != nil && (snip.FolderID == nil || *snip.FolderID != *opts.FolderID) {
+			continue
+		}
+		snip = s.withTags(snip)
+		if !hasAllTags(snip.Tags, opts.Tags) {
+			continue
+		}
+		rank, ok := query.matchSnippet(snip)
+		if !ok {
+			continue
+		}
+		results = append(results, models.SearchResult{
+			Snippet: snip,
+			Rank:    rank,
+			Highlights: models.SearchHighlights{
+				Title:       highlight(snip.Title, pattern, 0),
+				Description: highlight(snip.Description, pattern, 60),
+				Code:        highlight(snip.Code, pattern, 60),
+			},
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Snippet.UpdatedAt.After(results[j].Snippet.UpdatedAt)
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+func (s *MemoryStorage) AddTag(userID, snippetID uuid.UUID, tagName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkSnippetOwner(userID, snippetID); err != nil {
+		return err
+	}
+	if s.attachTag(snippetID, s.upsertTag(userID, tagName)) {
+		s.recordTagChange(snippetID)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) RemoveTag(userID, snippetID uuid.UUID, tagName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkSnippetOwner(userID, snippetID); err != nil {
+		return err
+	}
+	tagID, ok := s.tags[tagKey{userID, tagName}]
+	if !ok || !s.detachTag(snippetID, tagID) {
+		return nil
+	}
+	s.deleteOrphanTags(userID)
+	s.recordTagChange(snippetID)
+	return nil
+}
+
+func (s *MemoryStorage) ListTags(userID uuid.UUID) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]int)
+	for _, ids := range s.snippetTags {
+		for _, id := range ids {
+			counts[id]++
+		}
+	}
+	tags := []models.Tag{}
+	for key, id := range s.tags {
+		if key.userID == userID {
+			tags = append(tags, models.Tag{Name: key.name, SnippetCount: counts[id]})
+		}
+	}
+	sortTags(tags)
+	return tags, nil
+}
+
+func (s *MemoryStorage) RenameTag(userID uuid.UUID, name, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.tags[tagKey{userID, name}]
+	if !ok {
+		return ErrTagNotFound
+	}
+	if newName == name {
+		return nil
+	}
+	if _, ok := s.tags[tagKey{userID, newName}]; ok {
+		return ErrTagExists
+	}
+	delete(s.tags, tagKey{userID, name})
+	s.tags[tagKey{userID, newName}] = id
+	s.tagNames[id] = newName
+	for snippetID, ids := range s.snippetTags {
+		if slices.Contains(ids, id) {
+			s.recordTagChange(snippetID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) MergeTags(userID uuid.UUID, source, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sourceID, ok := s.tags[tagKey{userID, source}]
+	if !ok {
+		return ErrTagNotFound
+	}
+	targetID, ok := s.tags[tagKey{userID, target}]
+	if !ok {
+		return ErrTagNotFound
+	}
+	if sourceID == targetID {
+		return nil
+	}
+	for snippetID := range s.snippetTags {
+		if s.detachTag(snippetID, sourceID) {
+			s.attachTag(snippetID, targetID)
+			s.recordTagChange(snippetID)
+		}
+	}
+	s.deleteOrphanTags(userID)
+	return nil
+}
+
+func (s *MemoryStorage) DeleteTag(userID uuid.UUID, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tagID, ok := s.tags[tagKey{userID, name}]
+	if !ok {
+		return ErrTagNotFound
+	}
+	for snippetID := range s.snippetTags {
+		if s.detachTag(snippetID, tagID) {
+			s.recordTagChange(snippetID)
+		}
+	}
+	s.deleteOrphanTags(userID)
+	return nil
+}
+
+func (s *MemoryStorage) CreateFolder(folder models.Folder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[folder.UserID]; !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := s.folders[folder.ID]; ok {
+		return errors.New("folder already exists")
+	}
+	if err := s.checkFolderOwner(folder.UserID, folder.ParentID); err != nil {
+		return err
+	}
+
+	folder.CreatedAt = time.Now()
+	folder.UpdatedAt = time.Now()
+	folder.ParentID = copyID(folder.ParentID)
+	s.folders[folder.ID] = folder
+	return nil
+}
+
+func (s *MemoryStorage) GetFolder(userID, folderID uuid.UUID) (models.Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkFolderOwner(userID, &folderID); err != nil {
+		return models.Folder{}, err
+	}
+	folder := s.folders[folderID]
+	folder.ParentID = copyID(folder.ParentID)
+	return folder, nil
+}
+
+func (s *MemoryStorage) GetFoldersByUser(userID uuid.UUID) ([]models.Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var folders []models.Folder
+	for _, folder := range s.folders {
+		if folder.UserID == userID {
+			folder.ParentID = copyID(folder.ParentID)
+			folders = append(folders, folder)
+		}
+	}
+	return folders, nil
+}
+
+func (s *MemoryStorage) GetFolderContents(
+	userID, folderID uuid.UUID,
+) ([]models.Snippet, []models.Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkFolderOwner(userID, &folderID); err != nil {
+		return nil, nil, err
+	}
+
+	var snippetList []models.Snippet
+	for _, snip := range s.snippets {
+		if snip.UserID == userID && snip.FolderID != nil && *snip.FolderID == folderID {
+			snippetList = append(snippetList, s.withTags(snip))
+		}
+	}
+
+	var folderList []models.Folder
+	for _, folder := range s.folders {
+		if folder.UserID == userID && folder.ParentID != nil && *folder.ParentID == folderID {
+			folder.ParentID = copyID(folder.ParentID)
+			folderList = append(folderList, folder)
+		}
+	}
+
+	return snippetList, folderList, nil
+}
+
+func (s *MemoryStorage) GetFolderTree(userID uuid.UUID, opts FolderTreeOptions) ([]models.FolderNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var nodes []models.FolderNode
+	index := make(map[uuid.UUID]int)
+	for _, folder := range s.folders {
+		if folder.UserID != userID {
+			continue
+		}
+		depth := 0
+		for id := folder.ParentID; id != nil; id = s.folders[*id].ParentID {
+			depth++
+		}
+		if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+			continue
+		}
+		folder.ParentID = copyID(folder.ParentID)
+		index[folder.ID] = len(nodes)
+		nodes = append(nodes, models.FolderNode{Folder: folder, Depth: depth})
+	}
+
+	for _, snip := range s.snippets {
+		if snip.FolderID == nil {
+			continue
+		}
+		if i, ok := index[*snip.FolderID]; ok {
+			nodes[i].SnippetCount++
+			if opts.WithSnippets {
+				nodes[i].Snippets = append(nodes[i].Snippets, models.SnippetSummary{
+					ID:        snip.ID,
+					Title:     snip.Title,
+					Language:  snip.Language,
+					UpdatedAt: snip.UpdatedAt,
+				})
+			}
+		}
+	}
+	return nestFolders(nodes), nil
+}
+
+func (s *MemoryStorage) GetFolderPath(userID, folderID uuid.UUID) ([]models.Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkFolderOwner(userID, &folderID); err != nil {
+		return nil, err
+	}
+	var path []models.Folder
+	for id := &folderID; id != nil; id = s.folders[*id].ParentID {
+		folder := s.folders[*id]
+		folder.ParentID = copyID(folder.ParentID)
+		path = append([]models.Folder{folder}, path...)
+	}
+	return path, nil
+}
+
+func (s *MemoryStorage) UpdateFolder(folder models.Folder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkFolderOwner(folder.UserID, &folder.ID); err != nil {
+		return err
+	}
+	if err := s.checkFolderOwner(folder.UserID, folder.ParentID); err != nil {
+		return err
+	}
+	for id := folder.ParentID; id != nil; id = s.folders[*id].ParentID {
+		if *id == folder.ID {
+			return ErrFolderCycle
+		}
+	}
+
+	stored := s.folders[folder.ID]
+	stored.Name = folder.Name
+	stored.ParentID = copyID(folder.ParentID)
+	stored.UpdatedAt = time.Now()
+	s.folders[folder.ID] = stored
+	return nil
+}
+
+func (s *MemoryStorage) DeleteFolder(userID, folderID uuid.UUID, mode FolderDeleteMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkFolderOwner(userID, &folderID); err != nil {
+		return err
+	}
+	parentID := s.folders[folderID].ParentID
+
+	if mode == MoveContentsToParent {
+		for id, snip := range s.snippets {
+			if snip.FolderID != nil && *snip.FolderID == folderID {
+				snip.FolderID = copyID(parentID)
+				s.snippets[id] = snip
+			}
+		}
+		for id, folder := range s.folders {
+			if folder.ParentID != nil && *folder.ParentID == folderID {
+				folder.ParentID = copyID(parentID)
+				s.folders[id] = folder
+			}
+		}
+		delete(s.folders, folderID)
+		return nil
+	}
+
+	// Mirror the cascading foreign keys of the SQL schema
+	deleted := map[uuid.UUID]bool{folderID: true}
+	for grown := true; grown; {
+		grown = false
+		for id, folder := range s.folders {
+			if !deleted[id] && folder.ParentID != nil && deleted[*folder.ParentID] {
+				deleted[id] = true
+				grown = true
+			}
+		}
+	}
+	for id := range deleted {
+		delete(s.folders, id)
+	}
+	for id, snip := range s.snippets {
+		if snip.FolderID != nil && deleted[*snip.FolderID] {
+			delete(s.snippets, id)
+			delete(s.snippetTags, id)
+			delete(s.revisions, id)
+		}
+	}
+	s.deleteOrphanTags(userID)
+	return nil
+}
+
+func (s *MemoryStorage) Import(userID uuid.UUID, archive models.Archive, opts ImportOptions) (models.ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return models.ImportResult{}, ErrUserNotFound
+	}
+	ownerOf := func(table string, id uuid.UUID) (uuid.UUID, bool, error) {
+		if table == "folders" {
+			folder, ok := s.folders[id]
+			return folder.UserID, ok, nil
+		}
+		snip, ok := s.snippets[id]
+		return snip.UserID, ok, nil
+	}
+	// The plan is checked up front and carrying it out cannot fail, so
+	// nothing is left half imported
+	plan, err := planImport(userID, archive, opts, ownerOf)
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+
+	for _, p := range plan.folders {
+		folder := p.folder
+		if p.action == importUpdate {
+			folder.CreatedAt = s.folders[folder.ID].CreatedAt
+		}
+		s.folders[folder.ID] = folder
+	}
+	for _, p := range plan.snippets {
+		snip := p.snippet
+		if p.action == importUpdate {
+			snip.CreatedAt = s.snippets[snip.ID].CreatedAt
+		}
+		s.snippets[snip.ID] = withoutTags(snip)
+		s.setTags(userID, snip.ID, snip.Tags)
+		s.recordRevision(snip.ID)
+	}
+	s.deleteOrphanTags(userID)
+	return plan.result, nil
+}
+
+func (s *MemoryStorage) CreateShareLink(link *models.ShareLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if link.SnippetID != nil {
+		err = s.checkSnippetOwner(link.UserID, *link.SnippetID)
+	} else {
+		err = s.checkFolderOwner(link.UserID, link.FolderID)
+	}
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := prepareShareLink(link)
+	if err != nil {
+		return err
+	}
+	stored := *link
+	stored.Token = ""
+	if passwordHash != nil {
+		stored.Password = *passwordHash
+	}
+	s.shareLinks[hashToken(link.Token)] = stored
+	return nil
+}
+
+func (s *MemoryStorage) GetShareLink(token string) (models.ShareLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.shareLinks[hashToken(token)]
+	if !ok || !s.shareLinkActive(link, time.Now()) {
+		return models.ShareLink{}, ErrShareLinkNotFound
+	}
+	return link, nil
+}
+
+func (s *MemoryStorage) RecordShareView(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, link := range s.shareLinks {
+		if link.ID != id {
+			continue
+		}
+		if link.MaxViews != nil && link.Views >= *link.MaxViews {
+			break
+		}
+		link.Views++
+		s.shareLinks[hash] = link
+		return nil
+	}
+	return ErrShareLinkNotFound
+}
+
+func (s *MemoryStorage) ListShareLinks(userID uuid.UUID) ([]models.ShareLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	links := []models.ShareLink{}
+	now := time.Now()
+	for _, link := range s.shareLinks {
+		if link.UserID == userID && s.shareLinkActive(link, now) {
+			link.Password = ""
+			links = append(links, link)
+		}
+	}
+	sortShareLinks(links)
+	return links, nil
+}
+
+func (s *MemoryStorage) DeleteShareLink(userID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, link := range s.shareLinks {
+		if link.ID == id && link.UserID == userID {
+			delete(s.shareLinks, hash)
+			return nil
+		}
+	}
+	return ErrShareLinkNotFound
+}
+
+// shareLinkActive reports whether a link can be used. Links are not deleted
+// along with their target, so they are also inactive once it is gone.
+func (s *MemoryStorage) shareLinkActive(link models.ShareLink, now time.Time) bool {
+	if link.SnippetID != nil && s.checkSnippetOwner(link.UserID, *link.SnippetID) != nil {
+		return false
+	}
+	if link.FolderID != nil && s.checkFolderOwner(link.UserID, link.FolderID) != nil {
+		return false
+	}
+	return link.Active(now)
+}
+
+func (s *MemoryStorage) ShareFolder(ownerID uuid.UUID, share *models.FolderShare) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkFolderOwner(ownerID, &share.FolderID); err != nil {
+		return err
+	}
+	if _, ok := s.users[share.UserID]; !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := s.workspaces[share.UserID]; ok {
+		return ErrUserNotFound
+	}
+	share.CreatedAt = time.Now()
+	stored := *share
+	stored.Username = ""
+	s.folderShares[folderShareKey{share.FolderID, share.UserID}] = stored
+	return nil
+}
+
+func (s *MemoryStorage) ListFolderShares(ownerID, folderID uuid.UUID) ([]models.FolderShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkFolderOwner(ownerID, &folderID); err != nil {
+		return nil, err
+	}
+	shares := []models.FolderShare{}
+	for key, share := range s.folderShares {
+		if key.folderID == folderID {
+			share.Username = s.users[key.userID].Username
+			shares = append(shares, share)
+		}
+	}
+	sortFolderShares(shares)
+	return shares, nil
+}
+
+func (s *MemoryStorage) UnshareFolder(ownerID, folderID, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := folderShareKey{folderID, userID}
+	if _, ok := s.folderShares[key]; !ok || s.checkFolderOwner(ownerID, &folderID) != nil {
+		return ErrFolderShareNotFound
+	}
+	delete(s.folderShares, key)
+	return nil
+}
+
+func (s *MemoryStorage) ListIncomingShares(userID uuid.UUID) ([]models.IncomingShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shares := []models.IncomingShare{}
+	for key, share := range s.folderShares {
+		folder, ok := s.folders[key.folderID]
+		// Grants are not deleted along with their folder
+		if key.userID != userID || !ok {
+			continue
+		}
+		shares = append(shares, models.IncomingShare{
+			Folder:     folder,
+			Owner:      s.users[folder.UserID].Username,
+			Permission: share.Permission,
+			CreatedAt:  share.CreatedAt,
+		})
+	}
+	sortIncomingShares(shares)
+	return shares, nil
+}
+
+func (s *MemoryStorage) FolderAccess(userID, folderID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.folderAccess(userID, folderID)
+}
+
+func (s *MemoryStorage) SnippetAccess(userID, snippetID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snip, ok := s.snippets[snippetID]
+	if !ok {
+		return uuid.Nil, "", ErrSnippetNotFound
+	}
+	if snip.UserID == userID {
+		return userID, models.PermissionWrite, nil
+	}
+	if snip.FolderID == nil {
+		return uuid.Nil, "", ErrSnippetNotFound
+	}
+	ownerID, permission, err := s.folderAccess(userID, *snip.FolderID)
+	if errors.Is(err, ErrFolderNotFound) {
+		return uuid.Nil, "", ErrSnippetNotFound
+	}
+	return ownerID, permission, err
+}
+
+// folderAccess mirrors the SQL helper of the same name; the caller must
+// hold the lock
+func (s *MemoryStorage) folderAccess(userID, folderID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	folder, ok := s.folders[folderID]
+	if !ok {
+		return uuid.Nil, "", ErrFolderNotFound
+	}
+	if folder.UserID == userID {
+		return userID, models.PermissionWrite, nil
+	}
+	// As in SQL, "write" sorts after "read"
+	var permission models.Permission
+	for id := &folderID; id != nil; id = s.folders[*id].ParentID {
+		if share, ok := s.folderShares[folderShareKey{*id, userID}]; ok && share.Permission > permission {
+			permission = share.Permission
+		}
+	}
+	if permission == "" {
+		return uuid.Nil, "", ErrFolderNotFound
+	}
+	return folder.UserID, permission, nil
+}
+
+func (s *MemoryStorage) CreateWorkspace(workspace *models.Workspace, ownerID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[ownerID]; !ok {
+		return ErrUserNotFound
+	}
+	workspace.ID = uuid.New()
+	workspace.Role = models.RoleOwner
+	workspace.CreatedAt = time.Now()
+	username, email := workspaceAccount(workspace.ID)
+	s.users[workspace.ID] = models.User{
+		ID:        workspace.ID,
+		Username:  username,
+		Email:     email,
+		Password:  workspacePassword,
+		CreatedAt: workspace.CreatedAt,
+		UpdatedAt: workspace.CreatedAt,
+	}
+	stored := *workspace
+	stored.Role = ""
+	s.workspaces[workspace.ID] = stored
+	s.members[memberKey{workspace.ID, ownerID}] = models.WorkspaceMember{
+		UserID:    ownerID,
+		Role:      models.RoleOwner,
+		CreatedAt: workspace.CreatedAt,
+	}
+	return nil
+}
+
+func (s *MemoryStorage) GetWorkspace(userID, id uuid.UUID) (models.Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	member, ok := s.members[memberKey{id, userID}]
+	if !ok {
+		return models.Workspace{}, ErrWorkspaceNotFound
+	}
+	workspace := s.workspaces[id]
+	workspace.Role = member.Role
+	return workspace, nil
+}
+
+func (s *MemoryStorage) ListWorkspaces(userID uuid.UUID) ([]models.Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	workspaces := []models.Workspace{}
+	for key, member := range s.members {
+		if key.userID == userID {
+			workspace := s.workspaces[key.workspaceID]
+			workspace.Role = member.Role
+			workspaces = append(workspaces, workspace)
+		}
+	}
+	sortWorkspaces(workspaces)
+	return workspaces, nil
+}
+
+func (s *MemoryStorage) DeleteWorkspace(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[id]; !ok {
+		return ErrWorkspaceNotFound
+	}
+	// Mirror the cascades that deleting the account runs in SQL
+	for snippetID, snip := range s.snippets {
+		if snip.UserID == id {
+			delete(s.snippets, snippetID)
+			delete(s.snippetTags, snippetID)
+			delete(s.revisions, snippetID)
+		}
+	}
+	for folderID, folder := range s.folders {
+		if folder.UserID == id {
+			delete(s.folders, folderID)
+		}
+	}
+	for key, tagID := range s.tags {
+		if key.userID == id {
+			delete(s.tags, key)
+			delete(s.tagNames, tagID)
+		}
+	}
+	for hash, link := range s.shareLinks {
+		if link.UserID == id {
+			delete(s.shareLinks, hash)
+		}
+	}
+	for key := range s.members {
+		if key.workspaceID == id {
+			delete(s.members, key)
+		}
+	}
+	delete(s.workspaces, id)
+	delete(s.users, id)
+	return nil
+}
+
+func (s *MemoryStorage) ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := []models.WorkspaceMember{}
+	for key, member := range s.members {
+		if key.workspaceID == workspaceID {
+			member.Username = s.users[key.userID].Username
+			members = append(members, member)
+		}
+	}
+	sortMembers(members)
+	return members, nil
+}
+
+func (s *MemoryStorage) AddMember(workspaceID uuid.UUID, member *models.WorkspaceMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[workspaceID]; !ok {
+		return ErrWorkspaceNotFound
+	}
+	if _, ok := s.users[member.UserID]; !ok {
+		return ErrUserNotFound
+	}
+	// Workspaces cannot be members of workspaces
+	if _, ok := s.workspaces[member.UserID]; ok {
+		return ErrUserNotFound
+	}
+	key := memberKey{workspaceID, member.UserID}
+	if _, ok := s.members[key]; ok {
+		return ErrMemberExists
+	}
+
+	member.CreatedAt = time.Now()
+	stored := *member
+	stored.Username = ""
+	s.members[key] = stored
+	return nil
+}
+
+func (s *MemoryStorage) SetMemberRole(workspaceID, userID uuid.UUID, role models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memberKey{workspaceID, userID}
+	member, ok := s.members[key]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	if role != models.RoleOwner {
+		if err := s.checkNotLastOwner(workspaceID, userID); err != nil {
+			return err
+		}
+	}
+	member.Role = role
+	s.members[key] = member
+	return nil
+}
+
+func (s *MemoryStorage) RemoveMember(workspaceID, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNotLastOwner(workspaceID, userID); err != nil {
+		return err
+	}
+	delete(s.members, memberKey{workspaceID, userID})
+	return nil
+}
+
+// checkNotLastOwner mirrors the SQL helper of the same name; the caller must
+// hold the lock
+func (s *MemoryStorage) checkNotLastOwner(workspaceID, userID uuid.UUID) error {
+	member, ok := s.members[memberKey{workspaceID, userID}]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	if member.Role != models.RoleOwner {
+		return nil
+	}
+	for key, other := range s.members {
+		if key.workspaceID == workspaceID && key.userID != userID && other.Role == models.RoleOwner {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
+
+func (s *MemoryStorage) CreateSession(session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, stored := range s.sessions {
+		if stored.UserID == session.UserID && !now.Before(stored.ExpiresAt) {
+			delete(s.sessions, hash)
+		}
+	}
+	for id, expiresAt := range s.revokedTokens {
+		if !now.Before(expiresAt) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	session.CreatedAt = now
+	session.LastUsedAt = now
+	stored := *session
+	stored.RefreshToken = ""
+	s.sessions[hashToken(session.RefreshToken)] = stored
+	return nil
+}
+
+func (s *MemoryStorage) GetSession(refreshToken string) (models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[hashToken(refreshToken)]
+	if !ok || !time.Now().Before(session.ExpiresAt) {
+		return models.Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *MemoryStorage) RotateSession(refreshToken string, session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := hashToken(refreshToken)
+	current, ok := s.sessions[hash]
+	if !ok || !time.Now().Before(current.ExpiresAt) {
+		return ErrSessionNotFound
+	}
+	s.revokedTokens[current.TokenID] = current.TokenExpiresAt
+	delete(s.sessions, hash)
+
+	session.ID = current.ID
+	session.UserID = current.UserID
+	session.CreatedAt = current.CreatedAt
+	session.LastUsedAt = time.Now()
+	stored := *session
+	stored.RefreshToken = ""
+	s.sessions[hashToken(session.RefreshToken)] = stored
+	return nil
+}
+
+func (s *MemoryStorage) ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := []models.Session{}
+	now := time.Now()
+	for _, session := range s.sessions {
+		if session.UserID == userID && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+func (s *MemoryStorage) DeleteSession(userID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range s.sessions {
+		if session.ID == id && session.UserID == userID {
+			s.revokedTokens[session.TokenID] = session.TokenExpiresAt
+			delete(s.sessions, hash)
+			return nil
+		}
+	}
+	return ErrSessionNotFound
+}
+
+func (s *MemoryStorage) DeleteSessions(userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range s.sessions {
+		if session.UserID == userID {
+			s.revokedTokens[session.TokenID] = session.TokenExpiresAt
+			delete(s.sessions, hash)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) IsTokenRevoked(tokenID uuid.UUID) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, revoked := s.revokedTokens[tokenID]
+	return revoked, nil
+}
+
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+// checkSnippetOwner mirrors the Postgres helper of the same name; the caller
+// must hold the lock
+func (s *MemoryStorage) checkSnippetOwner(userID, snippetID uuid.UUID) error {
+	snip, ok := s.snippets[snippetID]
+	if !ok || snip.UserID != userID {
+		return ErrSnippetNotFound
+	}
+	return nil
+}
+
+// checkFolderOwner mirrors the Postgres helper of the same name; the caller
+// must hold the lock
+func (s *MemoryStorage) checkFolderOwner(userID uuid.UUID, folderID *uuid.UUID) error {
+	if folderID == nil {
+		return nil
+	}
+	folder, ok := s.folders[*folderID]
+	if !ok || folder.UserID != userID {
+		return ErrFolderNotFound
+	}
+	return nil
+}
+
+// recordRevision appends the current state of a snippet to its history; the
+// caller must hold the lock
+func (s *MemoryStorage) recordRevision(snippetID uuid.UUID) {
+	snip := s.withTags(s.snippets[snippetID])
+	s.revisions[snippetID] = append(s.revisions[snippetID], models.Revision{
+		SnippetID:   snip.ID,
+		Number:      len(s.revisions[snippetID]) + 1,
+		Title:       snip.Title,
+		Description: snip.Description,
+		Language:    snip.Language,
+		Code:        snip.Code,
+		Tags:        revisionTags(snip.Tags),
+		CreatedAt:   snip.UpdatedAt,
+	})
+}
+
+// recordTagChange mirrors the SQL helper of the same name for one snippet;
+// the caller must hold the lock
+func (s *MemoryStorage) recordTagChange(snippetID uuid.UUID) {
+	snip := s.snippets[snippetID]
+	snip.UpdatedAt = time.Now()
+	s.snippets[snippetID] = snip
+	s.recordRevision(snippetID)
+}
+
+// upsertTag returns the ID of the user's tag of that name, creating it if
+// necessary
+func (s *MemoryStorage) upsertTag(userID uuid.UUID, name string) uuid.UUID {
+	key := tagKey{userID, name}
+	if id, ok := s.tags[key]; ok {
+		return id
+	}
+	id := uuid.New()
+	s.tags[key] = id
+	s.tagNames[id] = name
+	return id
+}
+
+// attachTag puts a tag on a snippet and reports whether it was not there
+// already
+func (s *MemoryStorage) attachTag(snippetID, tagID uuid.UUID) bool {
+	for _, id := range s.snippetTags[snippetID] {
+		if id == tagID {
+			return false
+		}
+	}
+	s.snippetTags[snippetID] = append(s.snippetTags[snippetID], tagID)
+	return true
+}
+
+// detachTag takes a tag off a snippet and reports whether it was there
+func (s *MemoryStorage) detachTag(snippetID, tagID uuid.UUID) bool {
+	ids := s.snippetTags[snippetID]
+	for i, id := range ids {
+		if id == tagID {
+			s.snippetTags[snippetID] = append(ids[:i:i], ids[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// deleteOrphanTags mirrors the SQL helper of the same name; the caller must
+// hold the lock
+func (s *MemoryStorage) deleteOrphanTags(userID uuid.UUID) {
+	used := make(map[uuid.UUID]bool)
+	for _, ids := range s.snippetTags {
+		for _, id := range ids {
+			used[id] = true
+		}
+	}
+	for key, id := range s.tags {
+		if key.userID == userID && !used[id] {
+			delete(s.tags, key)
+			delete(s.tagNames, id)
+		}
+	}
+}
+
+// setTags replaces the tags of a snippet
+func (s *MemoryStorage) setTags(userID, snippetID uuid.UUID, tags []string) {
+	delete(s.snippetTags, snippetID)
+	for _, tag := range tags {
+		s.attachTag(snippetID, s.upsertTag(userID, tag))
+	}
+}
+
+// withTags returns a copy of the snippet with its tag names filled in
+func (s *MemoryStorage) withTags(snip models.Snippet) models.Snippet {
+	snip.FolderID = copyID(snip.FolderID)
+	snip.Tags = nil
+	for _, id := range s.snippetTags[snip.ID] {
+		snip.Tags = append(snip.Tags, s.tagNames[id])
+	}
+	return snip
+}
+
+func hasAllTags(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAnyTag(tags, want []string) bool {
+	for _, w := range want {
+		for _, tag := range tags {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func withoutTags(snip models.Snippet) models.Snippet {
+	snip.Tags = nil
+	return snip
+}
+
+func copyRevision(rev models.Revision) models.Revision {
+	rev.Tags = append([]string{}, rev.Tags...)
+	return rev
+}
+
+func copyID(id *uuid.UUID) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+	c := *id
+	return &c
+}
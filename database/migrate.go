@@ -0,0 +1,341 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change. Files are named
+// NNNN_name.up.sql and NNNN_name.down.sql under migrations/<dialect>/.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// dialect holds the per-database differences of the migrator
+type dialect struct {
+	name string
+	// createTable creates the schema_migrations bookkeeping table
+	createTable string
+	// lock serialises concurrent migrators inside each migration transaction
+	lock string
+	// toggleForeignKeys disables foreign key enforcement around each
+	// migration. SQLite needs this to rebuild tables that other tables
+	// reference, and the pragma cannot be changed inside a transaction.
+	toggleForeignKeys bool
+}
+
+var (
+	postgresDialect = dialect{
+		name: "postgres",
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMP WITH TIME ZONE NOT NULL
+        )`,
+		lock: "LOCK TABLE schema_migrations IN EXCLUSIVE MODE",
+	}
+	sqliteDialect = dialect{
+		name: "sqlite",
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMP NOT NULL
+        )`,
+		toggleForeignKeys: true,
+	}
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migrator applies and rolls back the embedded migrations of one dialect
+type Migrator struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func (s *PostgresStorage) Migrator() *Migrator {
+	return &Migrator{db: s.db, dialect: postgresDialect}
+}
+
+func (s *SQLiteStorage) Migrator() *Migrator {
+	return &Migrator{db: s.db, dialect: sqliteDialect}
+}
+
+// Migrations returns the embedded migrations ordered by version
+func (m *Migrator) Migrations() ([]Migration, error) {
+	dir := path.Join("migrations", m.dialect.name)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Status lists every known migration along with when it was applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, mig := range migrations {
+		st := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			st.AppliedAt = &at
+		}
+		status = append(status, st)
+	}
+	return status, nil
+}
+
+// Pending returns the migrations that have not been applied yet
+func (m *Migrator) Pending() ([]Migration, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations in order, each in its own transaction,
+// and returns the ones it applied
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range pending {
+		ran, err := m.run(mig, true)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if ran {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Down rolls back the most recently applied n migrations and returns them
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < n; i-- {
+		mig := migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return done, fmt.Errorf("migration %d_%s cannot be rolled back", mig.Version, mig.Name)
+		}
+		ran, err := m.run(mig, false)
+		if err != nil {
+			return done, fmt.Errorf("rollback %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if ran {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// applied returns the application time of every recorded migration
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if _, err := m.db.Exec(m.dialect.createTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// run applies or rolls back a single migration. It reports false when
+// another migrator got there first.
+func (m *Migrator) run(mig Migration, up bool) (bool, error) {
+	ctx := context.Background()
+
+	// Pin one connection so that the foreign key pragma applies to the
+	// transaction below
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if m.dialect.toggleForeignKeys {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return false, err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if m.dialect.lock != "" {
+		if _, err := tx.Exec(m.dialect.lock); err != nil {
+			return false, err
+		}
+	}
+
+	var recorded bool
+	err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)",
+		mig.Version,
+	).Scan(&recorded)
+	if err != nil {
+		return false, err
+	}
+	if recorded == up {
+		// Another migrator applied or rolled back this migration already
+		return false, nil
+	}
+	if _, err := tx.Exec(mig.script(up)); err != nil {
+		return false, err
+	}
+
+	if up {
+		_, err = tx.Exec(
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+			mig.Version,
+			mig.Name,
+			time.Now(),
+		)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if m.dialect.toggleForeignKeys {
+		if err := checkForeignKeys(tx); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func (mig Migration) script(up bool) string {
+	if up {
+		return mig.Up
+	}
+	return mig.Down
+}
+
+// checkForeignKeys fails if a SQLite migration left dangling references
+// behind while enforcement was disabled
+func checkForeignKeys(tx *sql.Tx) error {
+	rows, err := tx.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		return errors.New("migration violates foreign key constraints")
+	}
+	return rows.Err()
+}
@@ -0,0 +1,11 @@
+-- Full-text index over title, description and code. The 'simple'
+-- configuration is used because code and identifiers should not be stemmed
+-- or stripped of English stop words. Weights rank title matches above
+-- description matches above code matches.
+ALTER TABLE snippets ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(code, '')), 'C')
+) STORED;
+
+CREATE INDEX snippets_search_idx ON snippets USING GIN (search_vector);
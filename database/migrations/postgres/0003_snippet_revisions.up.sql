@@ -0,0 +1,25 @@
+-- Every saved state of a snippet. Revisions are never updated; restoring an
+-- old one records a new revision.
+
+CREATE TABLE snippet_revisions (
+    snippet_id UUID NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    language TEXT NOT NULL,
+    code TEXT NOT NULL,
+    tags JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (snippet_id, revision)
+);
+
+-- Existing snippets start their history at their current state
+INSERT INTO snippet_revisions (snippet_id, revision, title, description, language, code, tags, created_at)
+SELECT s.id, 1, s.title, COALESCE(s.description, ''), s.language, s.code,
+    COALESCE((
+        SELECT jsonb_agg(t.name ORDER BY t.name)
+        FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+        WHERE st.snippet_id = s.id
+    ), '[]'),
+    COALESCE(s.updated_at, CURRENT_TIMESTAMP)
+FROM snippets s;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS snippets_folder_id_idx;
+ALTER TABLE snippets DROP CONSTRAINT IF EXISTS snippets_folder_id_fkey;
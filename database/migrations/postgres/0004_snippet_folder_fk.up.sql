@@ -0,0 +1,12 @@
+-- Snippets used to keep the ID of a deleted folder. Such snippets move to
+-- the root, and from now on deleting a folder deletes the snippets in it
+-- unless they are moved out first.
+
+UPDATE snippets SET folder_id = NULL
+WHERE folder_id IS NOT NULL AND folder_id NOT IN (SELECT id FROM folders);
+
+ALTER TABLE snippets
+    ADD CONSTRAINT snippets_folder_id_fkey
+    FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE;
+
+CREATE INDEX snippets_folder_id_idx ON snippets (folder_id);
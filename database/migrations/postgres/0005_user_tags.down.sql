@@ -0,0 +1,14 @@
+-- Tags become shared again: the copies of a name are merged into one tag.
+-- A snippet has at most one tag of each name, so no duplicates arise.
+
+ALTER TABLE tags DROP CONSTRAINT tags_user_id_name_key;
+
+UPDATE snippet_tags st
+SET tag_id = keep.id
+FROM tags t, (SELECT name, min(id::text)::uuid AS id FROM tags GROUP BY name) keep
+WHERE t.id = st.tag_id AND keep.name = t.name AND st.tag_id <> keep.id;
+
+DELETE FROM tags WHERE id NOT IN (SELECT min(id::text)::uuid FROM tags GROUP BY name);
+
+ALTER TABLE tags DROP COLUMN user_id;
+ALTER TABLE tags ADD CONSTRAINT tags_name_key UNIQUE (name);
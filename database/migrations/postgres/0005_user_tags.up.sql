@@ -0,0 +1,23 @@
+-- Tags used to be shared by every user. Each user now gets a copy of the
+-- tags on their own snippets, and tags on no snippet are dropped. A copy's
+-- ID is derived from the old tag and the user so that snippet_tags can be
+-- pointed at it.
+
+ALTER TABLE tags ADD COLUMN user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE tags DROP CONSTRAINT tags_name_key;
+
+INSERT INTO tags (id, user_id, name)
+SELECT DISTINCT md5(t.id::text || s.user_id::text)::uuid, s.user_id, t.name
+FROM tags t
+JOIN snippet_tags st ON st.tag_id = t.id
+JOIN snippets s ON s.id = st.snippet_id;
+
+UPDATE snippet_tags st
+SET tag_id = md5(st.tag_id::text || s.user_id::text)::uuid
+FROM snippets s
+WHERE s.id = st.snippet_id;
+
+DELETE FROM tags WHERE user_id IS NULL;
+
+ALTER TABLE tags ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE tags ADD CONSTRAINT tags_user_id_name_key UNIQUE (user_id, name);
@@ -0,0 +1,24 @@
+-- Store every language under its canonical name; see the languages package
+UPDATE snippets SET language = CASE
+    WHEN lower(trim(language)) IN ('sh', 'shell', 'zsh') THEN 'bash'
+    WHEN lower(trim(language)) IN ('c++', 'cplusplus') THEN 'cpp'
+    WHEN lower(trim(language)) IN ('c#', 'cs') THEN 'csharp'
+    WHEN lower(trim(language)) IN ('docker') THEN 'dockerfile'
+    WHEN lower(trim(language)) IN ('ex', 'exs') THEN 'elixir'
+    WHEN lower(trim(language)) IN ('golang') THEN 'go'
+    WHEN lower(trim(language)) IN ('hs') THEN 'haskell'
+    WHEN lower(trim(language)) IN ('htm', 'xhtml') THEN 'html'
+    WHEN lower(trim(language)) IN ('js', 'node', 'nodejs') THEN 'javascript'
+    WHEN lower(trim(language)) IN ('kt') THEN 'kotlin'
+    WHEN lower(trim(language)) IN ('make') THEN 'makefile'
+    WHEN lower(trim(language)) IN ('md') THEN 'markdown'
+    WHEN lower(trim(language)) IN ('pl') THEN 'perl'
+    WHEN lower(trim(language)) IN ('ps1', 'pwsh', 'posh') THEN 'powershell'
+    WHEN lower(trim(language)) IN ('py', 'python3', 'py3') THEN 'python'
+    WHEN lower(trim(language)) IN ('rb') THEN 'ruby'
+    WHEN lower(trim(language)) IN ('rs') THEN 'rust'
+    WHEN lower(trim(language)) IN ('plaintext', 'plain', 'txt') THEN 'text'
+    WHEN lower(trim(language)) IN ('ts') THEN 'typescript'
+    WHEN lower(trim(language)) IN ('yml') THEN 'yaml'
+    ELSE lower(trim(language))
+END;
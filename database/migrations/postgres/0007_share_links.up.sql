@@ -0,0 +1,18 @@
+-- Links that give anonymous access to a snippet or a folder. Only a hash of
+-- the token is kept, so a leaked database does not leak working links.
+
+CREATE TABLE share_links (
+    id UUID PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    snippet_id UUID REFERENCES snippets(id) ON DELETE CASCADE,
+    folder_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    password_hash TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    max_views INTEGER,
+    views INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CHECK ((snippet_id IS NULL) <> (folder_id IS NULL))
+);
+
+CREATE INDEX share_links_user_id_idx ON share_links (user_id);
@@ -0,0 +1,4 @@
+-- Deleting the accounts of the workspaces deletes their libraries too
+DELETE FROM users WHERE id IN (SELECT id FROM workspaces);
+DROP TABLE workspace_members;
+DROP TABLE workspaces;
@@ -0,0 +1 @@
+DROP TABLE folder_shares;
@@ -0,0 +1,12 @@
+-- Grants of access to a folder, and to everything below it, to users other
+-- than its owner
+
+CREATE TABLE folder_shares (
+    folder_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission TEXT NOT NULL CHECK (permission IN ('read', 'write')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (folder_id, user_id)
+);
+
+CREATE INDEX folder_shares_user_id_idx ON folder_shares (user_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS snippet_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS snippets;
+DROP TABLE IF EXISTS folders;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,30 @@
+-- Full-text index over title, description and code. FTS4 is used because
+-- it is compiled into the driver by default. Rows are keyed by snippet_id
+-- rather than rowid since SQLite may renumber the rowids of snippets on
+-- VACUUM.
+CREATE VIRTUAL TABLE snippets_fts USING fts4(
+    snippet_id,
+    title,
+    description,
+    code,
+    notindexed=snippet_id,
+    tokenize=unicode61
+);
+
+INSERT INTO snippets_fts (snippet_id, title, description, code)
+SELECT id, title, coalesce(description, ''), code FROM snippets;
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (snippet_id, title, description, code)
+    VALUES (new.id, new.title, coalesce(new.description, ''), new.code);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, description, code ON snippets BEGIN
+    UPDATE snippets_fts
+    SET title = new.title, description = coalesce(new.description, ''), code = new.code
+    WHERE snippet_id = old.id;
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE snippet_id = old.id;
+END;
@@ -0,0 +1,27 @@
+-- Every saved state of a snippet. Revisions are never updated; restoring an
+-- old one records a new revision.
+
+CREATE TABLE snippet_revisions (
+    snippet_id TEXT NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    language TEXT NOT NULL,
+    code TEXT NOT NULL,
+    -- JSON array of tag names
+    tags TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (snippet_id, revision)
+);
+
+-- Existing snippets start their history at their current state
+INSERT INTO snippet_revisions (snippet_id, revision, title, description, language, code, tags, created_at)
+SELECT s.id, 1, s.title, COALESCE(s.description, ''), s.language, s.code,
+    (
+        SELECT json_group_array(name) FROM (
+            SELECT t.name FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+            WHERE st.snippet_id = s.id ORDER BY t.name
+        )
+    ),
+    COALESCE(s.updated_at, CURRENT_TIMESTAMP)
+FROM snippets s;
@@ -0,0 +1,35 @@
+-- Rebuild the table without the foreign key; see the up migration
+
+CREATE TABLE snippets_new (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT,
+    language TEXT NOT NULL,
+    code TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO snippets_new (id, title, description, language, code, user_id, folder_id, created_at, updated_at)
+SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at
+FROM snippets;
+
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (snippet_id, title, description, code)
+    VALUES (new.id, new.title, coalesce(new.description, ''), new.code);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, description, code ON snippets BEGIN
+    UPDATE snippets_fts
+    SET title = new.title, description = coalesce(new.description, ''), code = new.code
+    WHERE snippet_id = old.id;
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE snippet_id = old.id;
+END;
@@ -0,0 +1,47 @@
+-- Snippets used to keep the ID of a deleted folder. Such snippets move to
+-- the root, and from now on deleting a folder deletes the snippets in it
+-- unless they are moved out first.
+--
+-- SQLite cannot add a foreign key to an existing table, so the table is
+-- rebuilt. The migrator turns foreign keys off around this script, which
+-- keeps the tags and revisions referencing snippets intact, and checks them
+-- afterwards. Dropping the table drops its full-text triggers, which are
+-- created again.
+
+CREATE TABLE snippets_new (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT,
+    language TEXT NOT NULL,
+    code TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO snippets_new (id, title, description, language, code, user_id, folder_id, created_at, updated_at)
+SELECT id, title, description, language, code, user_id,
+    CASE WHEN folder_id IN (SELECT id FROM folders) THEN folder_id END,
+    created_at, updated_at
+FROM snippets;
+
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+
+CREATE INDEX snippets_folder_id_idx ON snippets (folder_id);
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (snippet_id, title, description, code)
+    VALUES (new.id, new.title, coalesce(new.description, ''), new.code);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, description, code ON snippets BEGIN
+    UPDATE snippets_fts
+    SET title = new.title, description = coalesce(new.description, ''), code = new.code
+    WHERE snippet_id = old.id;
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE snippet_id = old.id;
+END;
@@ -0,0 +1,20 @@
+-- Tags become shared again: the copies of a name are merged into one tag.
+-- A snippet has at most one tag of each name, so no duplicates arise.
+
+CREATE TABLE tags_new (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+INSERT INTO tags_new (id, name)
+SELECT min(id), name FROM tags GROUP BY name;
+
+UPDATE snippet_tags SET tag_id = (
+    SELECT n.id
+    FROM tags_new n
+    JOIN tags t ON t.name = n.name
+    WHERE t.id = snippet_tags.tag_id
+);
+
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
@@ -0,0 +1,44 @@
+-- Tags used to be shared by every user. Each user now gets a copy of the
+-- tags on their own snippets, and tags on no snippet are dropped.
+--
+-- The tags table is rebuilt to change its unique constraint, with foreign
+-- keys turned off by the migrator. tag_copies maps each old tag and user to
+-- the ID of the copy, a random UUID.
+
+CREATE TABLE tag_copies (
+    old_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    id TEXT
+);
+
+INSERT INTO tag_copies (old_id, user_id)
+SELECT DISTINCT st.tag_id, s.user_id
+FROM snippet_tags st
+JOIN snippets s ON s.id = st.snippet_id;
+
+UPDATE tag_copies SET id = lower(hex(randomblob(16)));
+UPDATE tag_copies SET id = substr(id, 1, 8) || '-' || substr(id, 9, 4) || '-' || substr(id, 13, 4) || '-' ||
+    substr(id, 17, 4) || '-' || substr(id, 21);
+
+CREATE TABLE tags_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+INSERT INTO tags_new (id, user_id, name)
+SELECT c.id, c.user_id, t.name
+FROM tag_copies c
+JOIN tags t ON t.id = c.old_id;
+
+UPDATE snippet_tags SET tag_id = (
+    SELECT c.id
+    FROM tag_copies c
+    JOIN snippets s ON s.user_id = c.user_id
+    WHERE c.old_id = snippet_tags.tag_id AND s.id = snippet_tags.snippet_id
+);
+
+DROP TABLE tag_copies;
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
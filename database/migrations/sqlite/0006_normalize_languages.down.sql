@@ -0,0 +1,2 @@
+-- The original spelling of each language is not kept, so there is nothing
+-- to undo
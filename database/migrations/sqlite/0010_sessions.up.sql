@@ -0,0 +1,22 @@
+-- Logins, each holding a refresh token, and the access tokens revoked
+-- before their expiry. Only a hash of the refresh token is kept.
+
+CREATE TABLE sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_hash TEXT NOT NULL UNIQUE,
+    token_id TEXT NOT NULL,
+    token_expires_at TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+CREATE TABLE revoked_tokens (
+    token_id TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,32 @@
+DROP TRIGGER snippets_fts_delete;
+DROP TRIGGER snippets_fts_update;
+DROP TRIGGER snippets_fts_insert;
+DROP TABLE snippets_fts;
+DROP TABLE snippets_fts_ids;
+
+CREATE VIRTUAL TABLE snippets_fts USING fts4(
+    snippet_id,
+    title,
+    description,
+    code,
+    notindexed=snippet_id,
+    tokenize=unicode61
+);
+
+INSERT INTO snippets_fts (snippet_id, title, description, code)
+SELECT id, title, coalesce(description, ''), code FROM snippets;
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (snippet_id, title, description, code)
+    VALUES (new.id, new.title, coalesce(new.description, ''), new.code);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, description, code ON snippets BEGIN
+    UPDATE snippets_fts
+    SET title = new.title, description = coalesce(new.description, ''), code = new.code
+    WHERE snippet_id = old.id;
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE snippet_id = old.id;
+END;
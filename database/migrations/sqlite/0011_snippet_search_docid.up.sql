@@ -0,0 +1,46 @@
+-- Keys the full-text index on its docid instead of the unindexed
+-- snippet_id column, which made every trigger scan the whole index.
+-- snippets_fts_ids maps docids to snippets; the rowids of snippets
+-- themselves are not stable across table rebuilds or VACUUM.
+DROP TRIGGER snippets_fts_delete;
+DROP TRIGGER snippets_fts_update;
+DROP TRIGGER snippets_fts_insert;
+DROP TABLE snippets_fts;
+
+CREATE TABLE snippets_fts_ids (
+    docid INTEGER PRIMARY KEY,
+    snippet_id TEXT NOT NULL UNIQUE
+);
+
+CREATE VIRTUAL TABLE snippets_fts USING fts4(
+    title,
+    description,
+    code,
+    tokenize=unicode61
+);
+
+INSERT INTO snippets_fts_ids (snippet_id) SELECT id FROM snippets;
+
+INSERT INTO snippets_fts (docid, title, description, code)
+SELECT m.docid, s.title, coalesce(s.description, ''), s.code
+FROM snippets s JOIN snippets_fts_ids m ON m.snippet_id = s.id;
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts_ids (snippet_id) VALUES (new.id);
+    INSERT INTO snippets_fts (docid, title, description, code)
+    VALUES (
+        (SELECT docid FROM snippets_fts_ids WHERE snippet_id = new.id),
+        new.title, coalesce(new.description, ''), new.code
+    );
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, description, code ON snippets BEGIN
+    UPDATE snippets_fts
+    SET title = new.title, description = coalesce(new.description, ''), code = new.code
+    WHERE docid = (SELECT docid FROM snippets_fts_ids WHERE snippet_id = old.id);
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE docid = (SELECT docid FROM snippets_fts_ids WHERE snippet_id = old.id);
+    DELETE FROM snippets_fts_ids WHERE snippet_id = old.id;
+END;
@@ -0,0 +1 @@
+DROP INDEX revoked_tokens_expires_at_idx;
@@ -0,0 +1,3 @@
+-- Indexes revoked tokens by expiry so that purging the expired ones does
+-- not scan the whole table.
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
@@ -2,13 +2,13 @@ package database
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 
 	"snippet-manager-go/models"
@@ -18,9 +18,9 @@ type PostgresStorage struct {
 	db *sql.DB
 }
 
-func NewPostgresStorage(host, port, user, password, dbname string) (*PostgresStorage, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+// NewPostgresStorage connects to the database described by connStr, either
+// a postgres:// URL or a "host=... dbname=..." keyword string
+func NewPostgresStorage(connStr string) (*PostgresStorage, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, err
@@ -31,51 +31,6 @@ func NewPostgresStorage(host, port, user, password, dbname string) (*PostgresSto
 	return &PostgresStorage{db: db}, nil
 }
 
-func (s *PostgresStorage) Init() error {
-	_, err := s.db.Exec(`
-    CREATE TABLE IF NOT EXISTS users (
-            id UUID PRIMARY KEY,
-            username TEXT NOT NULL UNIQUE,
-            email TEXT NOT NULL UNIQUE,
-            password TEXT NOT NULL,
-            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-        );
-    CREATE TABLE IF NOT EXISTS snippets (
-        id UUID PRIMARY KEY,
-        title TEXT NOT NULL,
-        description TEXT,
-        language TEXT NOT NULL,
-        code TEXT NOT NULL,
-        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-        folder_id UUID,
-        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-    );
-
-    CREATE TABLE IF NOT EXISTS tags (
-        id UUID PRIMARY KEY,
-        name TEXT NOT NULL UNIQUE
-    );
-
-    CREATE TABLE IF NOT EXISTS snippet_tags (
-        snippet_id UUID REFERENCES snippets(id) ON DELETE CASCADE,
-        tag_id UUID REFERENCES tags(id) ON DELETE CASCADE,
-        PRIMARY KEY (snippet_id, tag_id)
-    );
-
-    CREATE TABLE IF NOT EXISTS folders (
-        id UUID PRIMARY KEY,
-        name TEXT NOT NULL,
-        parent_id UUID REFERENCES folders(id) ON DELETE CASCADE,
-        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-    );
-    `)
-	return err
-}
-
 func (s *PostgresStorage) CreateUser(user *models.User) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -106,7 +61,20 @@ func (s *PostgresStorage) GetUserByUsername(username string) (*models.User, erro
 	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *PostgresStorage) GetUserByEmail(email string) (*models.User, error) {
+	user := &models.User{}
+	err := s.db.QueryRow(
+		"SELECT id, username, email, password, created_at, updated_at FROM users WHERE email = $1",
+		email,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
 	}
 	return user, err
 }
@@ -119,7 +87,7 @@ func (s *PostgresStorage) GetUserByID(id uuid.UUID) (*models.User, error) {
 	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return user, err
 }
@@ -131,6 +99,10 @@ func (s *PostgresStorage) Create(snippet models.Snippet) error {
 	}
 	defer tx.Rollback()
 
+	if err := checkFolderOwner(tx, snippet.UserID, snippet.FolderID); err != nil {
+		return err
+	}
+
 	snippet.CreatedAt = time.Now()
 	snippet.UpdatedAt = time.Now()
 
@@ -151,23 +123,13 @@ func (s *PostgresStorage) Create(snippet models.Snippet) error {
 		return err
 	}
 
-	// Then, add tags one by one
-	for _, tag := range snippet.Tags {
-		var tagID uuid.UUID
-		err = tx.QueryRow("INSERT INTO tags (id, name) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id", uuid.New(), tag).
-			Scan(&tagID)
-		if err != nil {
-			return err
-		}
+	// Then, add tags
+	if err := attachTags(tx, snippet.UserID, snippet.ID, snippet.Tags); err != nil {
+		return err
+	}
 
-		_, err = tx.Exec(
-			"INSERT INTO snippet_tags (snippet_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-			snippet.ID,
-			tagID,
-		)
-		if err != nil {
-			return err
-		}
+	if err := recordRevision(tx, snippet); err != nil {
+		return err
 	}
 
 	return tx.Commit()
@@ -181,9 +143,13 @@ func (s *PostgresStorage) Update(snippet models.Snippet) error {
 	}
 	defer tx.Rollback()
 
+	if err := checkFolderOwner(tx, snippet.UserID, snippet.FolderID); err != nil {
+		return err
+	}
+
 	snippet.UpdatedAt = time.Now()
-	_, err = tx.Exec(
-		"UPDATE snippets SET title = $2, description = $3, language = $4, code = $5, folder_id = $6, updated_at = $7 WHERE id = $1",
+	result, err := tx.Exec(
+		"UPDATE snippets SET title = $2, description = $3, language = $4, code = $5, folder_id = $6, updated_at = $7 WHERE id = $1 AND user_id = $8",
 		snippet.ID,
 		snippet.Title,
 		snippet.Description,
@@ -191,11 +157,15 @@ func (s *PostgresStorage) Update(snippet models.Snippet) error {
 		snippet.Code,
 		snippet.FolderID,
 		snippet.UpdatedAt,
+		snippet.UserID,
 	)
 	if err != nil {
 		log.Printf("Error updating snippet: %v", err)
 		return err
 	}
+	if err := expectAffected(result, ErrSnippetNotFound); err != nil {
+		return err
+	}
 
 	log.Println("Snippet updated successfully")
 
@@ -209,34 +179,18 @@ func (s *PostgresStorage) Update(snippet models.Snippet) error {
 	log.Println("Snippet tags deleted successfully")
 
 	// Add new tags
-	for _, tag := range snippet.Tags {
-		var tagID uuid.UUID
-		err := tx.QueryRow("SELECT id FROM tags WHERE name = $1", tag).Scan(&tagID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				// Tag does not exist, create it
-				tagID = uuid.New()
-				_, err := tx.Exec("INSERT INTO tags (id, name) VALUES ($1, $2)", tagID, tag)
-				if err != nil {
-					log.Printf("Error creating tag %s: %v", tag, err)
-					return err
-				}
-			} else {
-				log.Printf("Error querying tag %s: %v", tag, err)
-				return err
-			}
-		}
+	if err := attachTags(tx, snippet.UserID, snippet.ID, snippet.Tags); err != nil {
+		log.Printf("Error adding tags to snippet %v: %v", snippet.ID, err)
+		return err
+	}
+	if err := deleteOrphanTags(tx, snippet.UserID); err != nil {
+		log.Printf("Error deleting unused tags: %v", err)
+		return err
+	}
 
-		_, err = tx.Exec(
-			"INSERT INTO snippet_tags (snippet_id, tag_id) VALUES ($1, $2)",
-			snippet.ID,
-			tagID,
-		)
-		if err != nil {
-			log.Printf("Error adding tag %s to snippet %v: %v", tag, snippet.ID, err)
-			return err
-		}
-		log.Printf("Tag %s added successfully", tag)
+	if err := recordRevision(tx, snippet); err != nil {
+		log.Printf("Error recording revision of snippet %v: %v", snippet.ID, err)
+		return err
 	}
 
 	err = tx.Commit()
@@ -249,37 +203,58 @@ func (s *PostgresStorage) Update(snippet models.Snippet) error {
 	return nil
 }
 
-func (s *PostgresStorage) GetAll() ([]models.Snippet, error) {
+func (s *PostgresStorage) GetAll(userID uuid.UUID) ([]models.Snippet, error) {
 	rows, err := s.db.Query(
-		"SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets",
+		"SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE user_id = $1",
+		userID,
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var snippets []models.Snippet
-	for rows.Next() {
-		var snip models.Snippet
-		if err := rows.Scan(&snip.ID, &snip.Title, &snip.Description, &snip.Language, &snip.Code, &snip.UserID, &snip.FolderID, &snip.CreatedAt, &snip.UpdatedAt); err != nil {
-			return nil, err
-		}
-		tags, err := s.GetSnippetTags(snip.ID)
-		if err != nil {
-			return nil, err
-		}
-		snip.Tags = tags
-		snippets = append(snippets, snip)
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadTags(snippets); err != nil {
+		return nil, err
 	}
 	return snippets, nil
 }
 
-func (s *PostgresStorage) Get(id uuid.UUID) (models.Snippet, error) {
+func (s *PostgresStorage) CountLanguages(userID uuid.UUID) (map[string]int, error) {
+	return countLanguages(s.db, userID)
+}
+
+func (s *PostgresStorage) List(userID uuid.UUID, opts ListOptions) (models.SnippetPage, error) {
+	after, err := opts.decodeCursor()
+	if err != nil {
+		return models.SnippetPage{}, err
+	}
+	query, args := listQuery(userID, opts, after)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return models.SnippetPage{}, err
+	}
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return models.SnippetPage{}, err
+	}
+	page := opts.page(snippets)
+	if err := s.loadTags(page.Snippets); err != nil {
+		return models.SnippetPage{}, err
+	}
+	return page, nil
+}
+
+func (s *PostgresStorage) Get(userID, id uuid.UUID) (models.Snippet, error) {
 	var snip models.Snippet
-	err := s.db.QueryRow("SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE id = $1", id).
+	err := s.db.QueryRow("SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE id = $1 AND user_id = $2", id, userID).
 		Scan(&snip.ID, &snip.Title, &snip.Description, &snip.Language, &snip.Code, &snip.UserID, &snip.FolderID, &snip.CreatedAt, &snip.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return snip, ErrSnippetNotFound
+	}
 	if err != nil {
-		return snip, fmt.Errorf("snippet not found")
+		return snip, err
 	}
 	tags, err := s.GetSnippetTags(id)
 	if err != nil {
@@ -289,43 +264,174 @@ func (s *PostgresStorage) Get(id uuid.UUID) (models.Snippet, error) {
 	return snip, nil
 }
 
-func (s *PostgresStorage) Delete(id uuid.UUID) error {
-	_, err := s.db.Exec("DELETE FROM snippets where id = $1", id)
-	return err
+func (s *PostgresStorage) Delete(userID, id uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM snippets where id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result, ErrSnippetNotFound); err != nil {
+		return err
+	}
+	if err := deleteOrphanTags(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) ListRevisions(userID, snippetID uuid.UUID) ([]models.Revision, error) {
+	return listRevisions(s.db, userID, snippetID)
+}
+
+func (s *PostgresStorage) GetRevision(userID, snippetID uuid.UUID, number int) (models.Revision, error) {
+	return getRevision(s.db, userID, snippetID, number)
 }
 
-func (s *PostgresStorage) AddTag(snippetID uuid.UUID, tagName string) error {
+func (s *PostgresStorage) RestoreRevision(userID, snippetID uuid.UUID, number int) (models.Snippet, error) {
+	if err := restoreRevision(s.db, userID, snippetID, number); err != nil {
+		return models.Snippet{}, err
+	}
+	return s.Get(userID, snippetID)
+}
+
+func (s *PostgresStorage) Search(userID uuid.UUID, opts SearchOptions) ([]models.SearchResult, error) {
+	if parseSearchQuery(opts.Query).empty() {
+		return nil, nil
+	}
+
+	args := []any{userID, opts.Query}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where := []string{"s.user_id = $1", "s.search_vector @@ q"}
+	if opts.Language != "" {
+		where = append(where, "lower(s.language) = lower("+arg(opts.Language)+")")
+	}
+	if opts.FolderID != nil {
+		where = append(where, "s.folder_id = "+arg(*opts.FolderID))
+	}
+	for _, tag := range opts.Tags {
+		where = append(where, `EXISTS (
+            SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+            WHERE st.snippet_id = s.id AND t.name = `+arg(tag)+`)`)
+	}
+
+	query := `
+        SELECT s.id, s.title, s.description, s.language, s.code, s.user_id, s.folder_id, s.created_at, s.updated_at,
+            ts_rank(s.search_vector, q) AS rank,
+            ts_headline('simple', s.title, q, 'HighlightAll=true, StartSel=` + sentinelStart + `, StopSel=` + sentinelStop + `'),
+            ts_headline('simple', coalesce(s.description, ''), q, 'MaxFragments=2, StartSel=` + sentinelStart + `, StopSel=` + sentinelStop + `'),
+            ts_headline('simple', s.code, q, 'MaxFragments=3, FragmentDelimiter=" … ", StartSel=` + sentinelStart + `, StopSel=` + sentinelStop + `')
+        FROM snippets s, websearch_to_tsquery('simple', $2) q
+        WHERE ` + strings.Join(where, " AND ") + `
+        ORDER BY rank DESC, s.updated_at DESC`
+	if opts.Limit > 0 {
+		query += " LIMIT " + arg(opts.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var res models.SearchResult
+		snip := &res.Snippet
+		h := &res.Highlights
+		if err := rows.Scan(&snip.ID, &snip.Title, &snip.Description, &snip.Language, &snip.Code, &snip.UserID, &snip.FolderID, &snip.CreatedAt, &snip.UpdatedAt,
+			&res.Rank, &h.Title, &h.Description, &h.Code); err != nil {
+			return nil, err
+		}
+		res.Highlights = markHighlights(res.Highlights)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadResultTags(results, s.loadTags); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *PostgresStorage) AddTag(userID, snippetID uuid.UUID, tagName string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	var tagID uuid.UUID
-	err = tx.QueryRow("INSERT INTO tags (id, name) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id", uuid.New(), tagName).
-		Scan(&tagID)
+	if err := addTag(tx, userID, snippetID, tagName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) RemoveTag(userID, snippetID uuid.UUID, tagName string) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = tx.Exec(
-		"INSERT INTO snippet_tags (snippet_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-		snippetID,
-		tagID,
-	)
+	if err := removeTag(tx, userID, snippetID, tagName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) ListTags(userID uuid.UUID) ([]models.Tag, error) {
+	return listTags(s.db, userID)
+}
+
+func (s *PostgresStorage) RenameTag(userID uuid.UUID, name, newName string) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
+	if err := renameTag(tx, userID, name, newName); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
-func (s *PostgresStorage) RemoveTag(snippetID uuid.UUID, tagName string) error {
-	_, err := s.db.Exec(`
-        DELETE FROM snippet_tags
-        WHERE snippet_id = $1 AND tag_id = (SELECT id FROM tags WHERE name = $2)
-    `, snippetID, tagName)
-	return err
+func (s *PostgresStorage) MergeTags(userID uuid.UUID, source, target string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := mergeTags(tx, userID, source, target); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) DeleteTag(userID uuid.UUID, name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteTag(tx, userID, name); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *PostgresStorage) GetSnippetTags(snippetID uuid.UUID) ([]string, error) {
@@ -352,6 +458,10 @@ func (s *PostgresStorage) GetSnippetTags(snippetID uuid.UUID) ([]string, error)
 }
 
 func (s *PostgresStorage) CreateFolder(folder models.Folder) error {
+	if err := checkFolderOwner(s.db, folder.UserID, folder.ParentID); err != nil {
+		return err
+	}
+
 	folder.CreatedAt = time.Now()
 	folder.UpdatedAt = time.Now()
 	_, err := s.db.Exec(
@@ -366,6 +476,10 @@ func (s *PostgresStorage) CreateFolder(folder models.Folder) error {
 	return err
 }
 
+func (s *PostgresStorage) GetFolder(userID, folderID uuid.UUID) (models.Folder, error) {
+	return getFolder(s.db, userID, folderID)
+}
+
 func (s *PostgresStorage) GetFoldersByUser(userID uuid.UUID) ([]models.Folder, error) {
 	rows, err := s.db.Query(
 		"SELECT id, name, user_id, parent_id, created_at, updated_at FROM folders WHERE user_id = $1",
@@ -393,34 +507,32 @@ func (s *PostgresStorage) GetFoldersByUser(userID uuid.UUID) ([]models.Folder, e
 }
 
 func (s *PostgresStorage) GetFolderContents(
-	folderID uuid.UUID,
+	userID, folderID uuid.UUID,
 ) ([]models.Snippet, []models.Folder, error) {
+	if err := checkFolderOwner(s.db, userID, &folderID); err != nil {
+		return nil, nil, err
+	}
+
 	snippets, err := s.db.Query(
-		"SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE folder_id = $1",
+		"SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE folder_id = $1 AND user_id = $2",
 		folderID,
+		userID,
 	)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer snippets.Close()
-
-	var snippetList []models.Snippet
-	for snippets.Next() {
-		var snip models.Snippet
-		if err := snippets.Scan(&snip.ID, &snip.Title, &snip.Description, &snip.Language, &snip.Code, &snip.UserID, &snip.FolderID, &snip.CreatedAt, &snip.UpdatedAt); err != nil {
-			return nil, nil, err
-		}
-		tags, err := s.GetSnippetTags(snip.ID)
-		if err != nil {
-			return nil, nil, err
-		}
-		snip.Tags = tags
-		snippetList = append(snippetList, snip)
+	snippetList, err := scanSnippets(snippets)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.loadTags(snippetList); err != nil {
+		return nil, nil, err
 	}
 
 	folders, err := s.db.Query(
-		"SELECT id, name, parent_id, user_id, created_at, updated_at FROM folders WHERE parent_id = $1",
+		"SELECT id, name, parent_id, user_id, created_at, updated_at FROM folders WHERE parent_id = $1 AND user_id = $2",
 		folderID,
+		userID,
 	)
 	if err != nil {
 		return nil, nil, err
@@ -439,6 +551,240 @@ func (s *PostgresStorage) GetFolderContents(
 	return snippetList, folderList, nil
 }
 
+// loadTags fills in the tags of each snippet with a single query
+func (s *PostgresStorage) loadTags(snippets []models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+	ids := make([]string, len(snippets))
+	for i, snip := range snippets {
+		ids[i] = snip.ID.String()
+	}
+
+	rows, err := s.db.Query(`
+        SELECT st.snippet_id, t.name
+        FROM snippet_tags st
+        JOIN tags t ON t.id = st.tag_id
+        WHERE st.snippet_id = ANY($1::uuid[])
+    `, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	return assignTags(rows, snippets)
+}
+
+func (s *PostgresStorage) GetFolderTree(userID uuid.UUID, opts FolderTreeOptions) ([]models.FolderNode, error) {
+	return getFolderTree(s.db, userID, opts)
+}
+
+func (s *PostgresStorage) GetFolderPath(userID, folderID uuid.UUID) ([]models.Folder, error) {
+	return getFolderPath(s.db, userID, folderID)
+}
+
+func (s *PostgresStorage) UpdateFolder(folder models.Folder) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateFolder(tx, folder, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) DeleteFolder(userID, folderID uuid.UUID, mode FolderDeleteMode) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteFolder(tx, userID, folderID, mode); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) Import(userID uuid.UUID, archive models.Archive, opts ImportOptions) (models.ImportResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := importArchive(tx, userID, archive, opts)
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+	return result, tx.Commit()
+}
+
+func (s *PostgresStorage) CreateShareLink(link *models.ShareLink) error {
+	return createShareLink(s.db, link)
+}
+
+func (s *PostgresStorage) GetShareLink(token string) (models.ShareLink, error) {
+	return getShareLink(s.db, token)
+}
+
+func (s *PostgresStorage) RecordShareView(id uuid.UUID) error {
+	return recordShareView(s.db, id)
+}
+
+func (s *PostgresStorage) ListShareLinks(userID uuid.UUID) ([]models.ShareLink, error) {
+	return listShareLinks(s.db, userID)
+}
+
+func (s *PostgresStorage) DeleteShareLink(userID, id uuid.UUID) error {
+	return deleteShareLink(s.db, userID, id)
+}
+
+func (s *PostgresStorage) ShareFolder(ownerID uuid.UUID, share *models.FolderShare) error {
+	return shareFolder(s.db, ownerID, share)
+}
+
+func (s *PostgresStorage) ListFolderShares(ownerID, folderID uuid.UUID) ([]models.FolderShare, error) {
+	return listFolderShares(s.db, ownerID, folderID)
+}
+
+func (s *PostgresStorage) UnshareFolder(ownerID, folderID, userID uuid.UUID) error {
+	return unshareFolder(s.db, ownerID, folderID, userID)
+}
+
+func (s *PostgresStorage) ListIncomingShares(userID uuid.UUID) ([]models.IncomingShare, error) {
+	return listIncomingShares(s.db, userID)
+}
+
+func (s *PostgresStorage) FolderAccess(userID, folderID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	return folderAccess(s.db, userID, folderID)
+}
+
+func (s *PostgresStorage) SnippetAccess(userID, snippetID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	return snippetAccess(s.db, userID, snippetID)
+}
+
+func (s *PostgresStorage) CreateWorkspace(workspace *models.Workspace, ownerID uuid.UUID) error {
+	return createWorkspace(s.db, workspace, ownerID)
+}
+
+func (s *PostgresStorage) GetWorkspace(userID, id uuid.UUID) (models.Workspace, error) {
+	return getWorkspace(s.db, userID, id)
+}
+
+func (s *PostgresStorage) ListWorkspaces(userID uuid.UUID) ([]models.Workspace, error) {
+	return listWorkspaces(s.db, userID)
+}
+
+func (s *PostgresStorage) DeleteWorkspace(id uuid.UUID) error {
+	return deleteWorkspace(s.db, id)
+}
+
+func (s *PostgresStorage) ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error) {
+	return listMembers(s.db, workspaceID)
+}
+
+func (s *PostgresStorage) AddMember(workspaceID uuid.UUID, member *models.WorkspaceMember) error {
+	return addMember(s.db, workspaceID, member)
+}
+
+func (s *PostgresStorage) SetMemberRole(workspaceID, userID uuid.UUID, role models.Role) error {
+	return setMemberRole(s.db, workspaceID, userID, role)
+}
+
+func (s *PostgresStorage) RemoveMember(workspaceID, userID uuid.UUID) error {
+	return removeMember(s.db, workspaceID, userID)
+}
+
+func (s *PostgresStorage) CreateSession(session *models.Session) error {
+	return createSession(s.db, session)
+}
+
+func (s *PostgresStorage) GetSession(refreshToken string) (models.Session, error) {
+	return getSession(s.db, refreshToken)
+}
+
+func (s *PostgresStorage) RotateSession(refreshToken string, session *models.Session) error {
+	return rotateSession(s.db, refreshToken, session)
+}
+
+func (s *PostgresStorage) ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	return listSessions(s.db, userID)
+}
+
+func (s *PostgresStorage) DeleteSession(userID, id uuid.UUID) error {
+	return deleteSession(s.db, userID, id)
+}
+
+func (s *PostgresStorage) DeleteSessions(userID uuid.UUID) error {
+	return deleteSessions(s.db, userID)
+}
+
+func (s *PostgresStorage) IsTokenRevoked(tokenID uuid.UUID) (bool, error) {
+	return isTokenRevoked(s.db, tokenID)
+}
+
 func (s *PostgresStorage) Close() error {
 	return s.db.Close()
 }
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so ownership checks can
+// run inside or outside a transaction. The checks below are shared with
+// SQLiteStorage; SQLite binds $1, $2 by order of appearance, so their
+// placeholders must stay in ascending order.
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// checkSnippetOwner returns ErrSnippetNotFound unless the snippet exists and
+// belongs to the given user
+func checkSnippetOwner(q queryer, userID, snippetID uuid.UUID) error {
+	var exists bool
+	err := q.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM snippets WHERE id = $1 AND user_id = $2)",
+		snippetID,
+		userID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrSnippetNotFound
+	}
+	return nil
+}
+
+// checkFolderOwner returns ErrFolderNotFound unless the folder exists and
+// belongs to the given user. A nil folder ID refers to the root and is
+// always accepted.
+func checkFolderOwner(q queryer, userID uuid.UUID, folderID *uuid.UUID) error {
+	if folderID == nil {
+		return nil
+	}
+	var exists bool
+	err := q.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM folders WHERE id = $1 AND user_id = $2)",
+		*folderID,
+		userID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrFolderNotFound
+	}
+	return nil
+}
+
+// expectAffected returns notFound when a statement did not touch any rows
+func expectAffected(result sql.Result, notFound error) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
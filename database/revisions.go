@@ -0,0 +1,186 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// recordRevision appends the saved state of a snippet to its history. It is
+// shared by the SQL backends and runs in the transaction of the save, after
+// the snippet row has been written and locked.
+func recordRevision(tx *sql.Tx, snippet models.Snippet) error {
+	tags, err := json.Marshal(revisionTags(snippet.Tags))
+	if err != nil {
+		return err
+	}
+
+	var number int
+	err = tx.QueryRow(
+		"SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = $1",
+		snippet.ID,
+	).Scan(&number)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO snippet_revisions (snippet_id, revision, title, description, language, code, tags, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		snippet.ID,
+		number,
+		snippet.Title,
+		snippet.Description,
+		snippet.Language,
+		snippet.Code,
+		string(tags),
+		snippet.UpdatedAt,
+	)
+	return err
+}
+
+// recordTagChange saves snippets whose tags changed the way an edit would:
+// it bumps their updated_at and records their new state as a revision, so
+// that restoring an older revision can bring the old tags back
+func recordTagChange(tx *sql.Tx, snippetIDs []uuid.UUID) error {
+	now := time.Now().UTC()
+	for _, id := range snippetIDs {
+		snippet := models.Snippet{ID: id, UpdatedAt: now}
+		err := tx.QueryRow(
+			"UPDATE snippets SET updated_at = $1 WHERE id = $2 RETURNING title, description, language, code",
+			now,
+			id,
+		).Scan(&snippet.Title, &snippet.Description, &snippet.Language, &snippet.Code)
+		if err != nil {
+			return err
+		}
+		if snippet.Tags, err = snippetTags(tx, id); err != nil {
+			return err
+		}
+		if err := recordRevision(tx, snippet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listRevisions returns the history of a snippet newest first
+func listRevisions(db *sql.DB, userID, snippetID uuid.UUID) ([]models.Revision, error) {
+	if err := checkSnippetOwner(db, userID, snippetID); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(
+		"SELECT snippet_id, revision, title, description, language, code, tags, created_at FROM snippet_revisions WHERE snippet_id = $1 ORDER BY revision DESC",
+		snippetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.Revision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func getRevision(q queryer, userID, snippetID uuid.UUID, number int) (models.Revision, error) {
+	if err := checkSnippetOwner(q, userID, snippetID); err != nil {
+		return models.Revision{}, err
+	}
+	rev, err := scanRevision(q.QueryRow(
+		"SELECT snippet_id, revision, title, description, language, code, tags, created_at FROM snippet_revisions WHERE snippet_id = $1 AND revision = $2",
+		snippetID,
+		number,
+	))
+	if err == sql.ErrNoRows {
+		return rev, ErrRevisionNotFound
+	}
+	return rev, err
+}
+
+func scanRevision(row interface{ Scan(...any) error }) (models.Revision, error) {
+	var rev models.Revision
+	var tags []byte
+	if err := row.Scan(&rev.SnippetID, &rev.Number, &rev.Title, &rev.Description, &rev.Language, &rev.Code, &tags, &rev.CreatedAt); err != nil {
+		return rev, err
+	}
+	return rev, json.Unmarshal(tags, &rev.Tags)
+}
+
+// restoreRevision saves an old revision over the current state of a snippet
+// and records it as a new revision, all in one transaction. The folder is
+// not part of the history and is left alone.
+func restoreRevision(db *sql.DB, userID, snippetID uuid.UUID, number int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rev, err := getRevision(tx, userID, snippetID, number)
+	if err != nil {
+		return err
+	}
+
+	// Writing the row first locks it, so a concurrent save cannot slip in
+	// before the new revision is numbered
+	snippet := models.Snippet{
+		ID:          snippetID,
+		Title:       rev.Title,
+		Description: rev.Description,
+		Language:    rev.Language,
+		Code:        rev.Code,
+		Tags:        rev.Tags,
+		UserID:      userID,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	result, err := tx.Exec(
+		"UPDATE snippets SET title = $1, description = $2, language = $3, code = $4, updated_at = $5 WHERE id = $6 AND user_id = $7",
+		snippet.Title,
+		snippet.Description,
+		snippet.Language,
+		snippet.Code,
+		snippet.UpdatedAt,
+		snippet.ID,
+		snippet.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result, ErrSnippetNotFound); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = $1", snippetID); err != nil {
+		return err
+	}
+	if err := attachTags(tx, userID, snippetID, snippet.Tags); err != nil {
+		return err
+	}
+	if err := deleteOrphanTags(tx, userID); err != nil {
+		return err
+	}
+	if err := recordRevision(tx, snippet); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revisionTags returns the distinct tags of a snippet, never nil so that it
+// is stored as an empty JSON array
+func revisionTags(tags []string) []string {
+	unique := uniqueStrings(tags)
+	if unique == nil {
+		return []string{}
+	}
+	return unique
+}
@@ -0,0 +1,273 @@
+package database
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// Markers wrapped around matches in search highlights. Highlights are HTML:
+// the text of the snippet is escaped and only the markers are tags.
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// Sentinels Postgres and SQLite wrap around matches in place of the markers,
+// so that the text can be escaped before the markers go in. They are
+// private use characters, which do not turn up in code.
+const (
+	sentinelStart = "\uE000"
+	sentinelStop  = "\uE001"
+)
+
+// SearchOptions describes a full-text search over the caller's snippets.
+// Query uses web search syntax: words must all match, "quoted text" is
+// matched as a phrase, "or" between words matches either and -word excludes
+// snippets containing the word.
+type SearchOptions struct {
+	Query    string
+	Language string
+	// Tags restricts results to snippets carrying all of the given tags
+	Tags     []string
+	FolderID *uuid.UUID
+	Limit    int
+}
+
+// searchQuery is a parsed SearchOptions.Query: every group must match at
+// least one of its terms and no excluded term may match
+type searchQuery struct {
+	groups   [][]string
+	excluded []string
+}
+
+// parseSearchQuery parses web search syntax the way Postgres'
+// websearch_to_tsquery does, for the backends that do not have it built in
+func parseSearchQuery(q string) searchQuery {
+	var parsed searchQuery
+	var tokens []string
+	var quoted []bool
+
+	for q != "" {
+		q = strings.TrimLeft(q, " \t\r\n")
+		if q == "" {
+			break
+		}
+		negate := false
+		if q[0] == '-' {
+			negate = true
+			q = q[1:]
+		}
+		var term string
+		isQuoted := false
+		if strings.HasPrefix(q, `"`) {
+			end := strings.Index(q[1:], `"`)
+			if end < 0 {
+				term, q = q[1:], ""
+			} else {
+				term, q = q[1:end+1], q[end+2:]
+			}
+			isQuoted = true
+		} else {
+			end := strings.IndexAny(q, " \t\r\n")
+			if end < 0 {
+				term, q = q, ""
+			} else {
+				term, q = q[:end], q[end:]
+			}
+		}
+		term = strings.TrimSpace(strings.ReplaceAll(term, `"`, ""))
+		if term == "" {
+			continue
+		}
+		if negate {
+			parsed.excluded = append(parsed.excluded, term)
+			continue
+		}
+		tokens = append(tokens, term)
+		quoted = append(quoted, isQuoted)
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		if strings.EqualFold(tokens[i], "or") && !quoted[i] && len(parsed.groups) > 0 && i+1 < len(tokens) {
+			last := len(parsed.groups) - 1
+			parsed.groups[last] = append(parsed.groups[last], tokens[i+1])
+			i++
+			continue
+		}
+		parsed.groups = append(parsed.groups, []string{tokens[i]})
+	}
+	return parsed
+}
+
+// empty reports whether the query has nothing to match on
+func (q searchQuery) empty() bool {
+	return len(q.groups) == 0
+}
+
+// terms returns every positive term of the query
+func (q searchQuery) terms() []string {
+	var terms []string
+	for _, group := range q.groups {
+		terms = append(terms, group...)
+	}
+	return terms
+}
+
+// ftsMatch renders the query in SQLite FTS4 enhanced query syntax
+func (q searchQuery) ftsMatch() string {
+	quote := func(term string) string {
+		return `"` + strings.ReplaceAll(term, `"`, "") + `"`
+	}
+
+	var parts []string
+	for _, group := range q.groups {
+		quotedTerms := make([]string, len(group))
+		for i, term := range group {
+			quotedTerms[i] = quote(term)
+		}
+		if len(group) == 1 {
+			parts = append(parts, quotedTerms[0])
+		} else {
+			parts = append(parts, "("+strings.Join(quotedTerms, " OR ")+")")
+		}
+	}
+	expr := strings.Join(parts, " ")
+	for _, term := range q.excluded {
+		expr += " NOT " + quote(term)
+	}
+	return expr
+}
+
+// termPattern returns a case-insensitive pattern matching any of the terms
+func termPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// matchSnippet reports whether the snippet matches the query and how well.
+// It is a plain substring match used by MemoryStorage.
+func (q searchQuery) matchSnippet(snip models.Snippet) (float64, bool) {
+	// Field weights follow ts_rank's defaults for the A, B and C weights
+	// the Postgres index assigns to title, description and code
+	fields := []struct {
+		text   string
+		weight float64
+	}{
+		{snip.Title, 1.0},
+		{snip.Description, 0.4},
+		{snip.Code, 0.2},
+	}
+
+	if len(q.excluded) > 0 {
+		excluded := termPattern(q.excluded)
+		for _, f := range fields {
+			if excluded.MatchString(f.text) {
+				return 0, false
+			}
+		}
+	}
+
+	var rank float64
+	for _, group := range q.groups {
+		pattern := termPattern(group)
+		matched := false
+		for _, f := range fields {
+			if n := len(pattern.FindAllStringIndex(f.text, -1)); n > 0 {
+				matched = true
+				rank += f.weight * float64(n)
+			}
+		}
+		if !matched {
+			return 0, false
+		}
+	}
+	return rank, true
+}
+
+// highlight returns a fragment of text around the first match of pattern
+// with every match marked. A non-positive context returns the whole text.
+// It returns "" when nothing matches.
+func highlight(text string, pattern *regexp.Regexp, context int) string {
+	matches := pattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	start, end := 0, len(text)
+	if context > 0 {
+		start = max(matches[0][0]-context, 0)
+		end = min(matches[0][1]+context, len(text))
+		for start > 0 && !utf8.RuneStart(text[start]) {
+			start--
+		}
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end++
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < start || m[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m[0]]))
+		b.WriteString(highlightStart)
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString(highlightStop)
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// loadResultTags fills in the tags of the snippets of search results using a
+// backend's batched tag loader
+func loadResultTags(results []models.SearchResult, loadTags func([]models.Snippet) error) error {
+	snippets := make([]models.Snippet, len(results))
+	for i := range results {
+		snippets[i] = results[i].Snippet
+	}
+	if err := loadTags(snippets); err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Snippet.Tags = snippets[i].Tags
+	}
+	return nil
+}
+
+// markHighlights turns highlights returned by Postgres or SQLite, with
+// matches between sentinels, into escaped HTML with matches marked. Fields
+// that did not match are cleared, since both return the start of the field
+// when there is nothing to highlight.
+func markHighlights(h models.SearchHighlights) models.SearchHighlights {
+	keep := func(s string) string {
+		if !strings.Contains(s, sentinelStart) {
+			return ""
+		}
+		s = html.EscapeString(s)
+		s = strings.ReplaceAll(s, sentinelStart, highlightStart)
+		return strings.ReplaceAll(s, sentinelStop, highlightStop)
+	}
+	return models.SearchHighlights{
+		Title:       keep(h.Title),
+		Description: keep(h.Description),
+		Code:        keep(h.Code),
+	}
+}
@@ -0,0 +1,249 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// The queries below are shared by PostgresStorage and SQLiteStorage; see
+// queryer for the placeholder rules. As for share links, expiry is checked
+// in Go rather than in SQL.
+
+const sessionColumns = "id, user_id, token_id, token_expires_at, user_agent, ip, created_at, last_used_at, expires_at"
+
+func scanSession(row interface{ Scan(...any) error }) (models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.TokenID, &session.TokenExpiresAt,
+		&session.UserAgent, &session.IP, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt)
+	return session, err
+}
+
+// scanSessions reads every session of rows and closes it
+func scanSessions(rows *sql.Rows) ([]models.Session, error) {
+	defer rows.Close()
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func createSession(db *sql.DB, session *models.Session) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if err := deleteExpiredSessions(tx, session.UserID, now); err != nil {
+		return err
+	}
+	session.CreatedAt = now
+	session.LastUsedAt = now
+	_, err = tx.Exec(
+		"INSERT INTO sessions (id, user_id, refresh_hash, token_id, token_expires_at, user_agent, ip, created_at, last_used_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		session.ID,
+		session.UserID,
+		hashToken(session.RefreshToken),
+		session.TokenID,
+		session.TokenExpiresAt,
+		session.UserAgent,
+		session.IP,
+		session.CreatedAt,
+		session.LastUsedAt,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteExpiredSessions deletes the expired sessions of a user, along with
+// the revoked access tokens that have expired and so no longer need to be
+// denied. It runs on every login so neither table grows without bound. The
+// time is bound in UTC, the zone revokeToken stores, so that it compares
+// correctly as text in SQLite.
+func deleteExpiredSessions(tx *sql.Tx, userID uuid.UUID, now time.Time) error {
+	rows, err := tx.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	sessions, err := scanSessions(rows)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if now.Before(session.ExpiresAt) {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM sessions WHERE id = $1", session.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM revoked_tokens WHERE expires_at <= $1", now.UTC())
+	return err
+}
+
+// getSession returns the active session holding a refresh token
+func getSession(q queryer, refreshToken string) (models.Session, error) {
+	session, err := scanSession(q.QueryRow(
+		"SELECT "+sessionColumns+" FROM sessions WHERE refresh_hash = $1",
+		hashToken(refreshToken),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, ErrSessionNotFound
+	}
+	if err != nil {
+		return session, err
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return models.Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func rotateSession(db *sql.DB, refreshToken string, session *models.Session) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := getSession(tx, refreshToken)
+	if err != nil {
+		return err
+	}
+	if err := revokeToken(tx, current.TokenID, current.TokenExpiresAt); err != nil {
+		return err
+	}
+	session.ID = current.ID
+	session.UserID = current.UserID
+	session.CreatedAt = current.CreatedAt
+	session.LastUsedAt = time.Now()
+	// Matching the old hash again makes a concurrent rotation with the
+	// same token fail rather than overwrite this one
+	result, err := tx.Exec(`
+        UPDATE sessions
+        SET refresh_hash = $1, token_id = $2, token_expires_at = $3, user_agent = $4, ip = $5, last_used_at = $6, expires_at = $7
+        WHERE id = $8 AND refresh_hash = $9
+    `, hashToken(session.RefreshToken), session.TokenID, session.TokenExpiresAt, session.UserAgent, session.IP,
+		session.LastUsedAt, session.ExpiresAt, session.ID, hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result, ErrSessionNotFound); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func listSessions(db *sql.DB, userID uuid.UUID) ([]models.Session, error) {
+	rows, err := db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := scanSessions(rows)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []models.Session{}
+	now := time.Now()
+	for _, session := range all {
+		if now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+// sortSessions orders sessions by last use, most recent first
+func sortSessions(sessions []models.Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+}
+
+func deleteSession(db *sql.DB, userID, id uuid.UUID) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tokenID uuid.UUID
+	var tokenExpiresAt time.Time
+	err = tx.QueryRow(
+		"SELECT token_id, token_expires_at FROM sessions WHERE id = $1 AND user_id = $2",
+		id,
+		userID,
+	).Scan(&tokenID, &tokenExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := revokeToken(tx, tokenID, tokenExpiresAt); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteSessions(db *sql.DB, userID uuid.UUID) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	sessions, err := scanSessions(rows)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := revokeToken(tx, session.TokenID, session.TokenExpiresAt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeToken adds an access token to the denylist until it expires
+func revokeToken(tx *sql.Tx, tokenID uuid.UUID, expiresAt time.Time) error {
+	_, err := tx.Exec(
+		"INSERT INTO revoked_tokens (token_id, expires_at) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		tokenID,
+		expiresAt.UTC(),
+	)
+	return err
+}
+
+func isTokenRevoked(db *sql.DB, tokenID uuid.UUID) (bool, error) {
+	var revoked bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE token_id = $1)", tokenID).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,347 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"snippet-manager-go/models"
+)
+
+// The queries below are shared by PostgresStorage and SQLiteStorage; see
+// queryer for the placeholder rules. Expiry of share links is checked in Go
+// rather than in SQL, since SQLite compares timestamps as text.
+
+// hashToken returns the hash under which a share link or a session is
+// stored. Tokens are random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// prepareShareLink sets the creation time of a new link and replaces its
+// password with its hash, which it returns
+func prepareShareLink(link *models.ShareLink) (*string, error) {
+	link.CreatedAt = time.Now()
+	link.HasPassword = link.Password != ""
+	if !link.HasPassword {
+		return nil, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(link.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	link.Password = ""
+	hashed := string(hash)
+	return &hashed, nil
+}
+
+func createShareLink(db *sql.DB, link *models.ShareLink) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if link.SnippetID != nil {
+		err = checkSnippetOwner(tx, link.UserID, *link.SnippetID)
+	} else {
+		err = checkFolderOwner(tx, link.UserID, link.FolderID)
+	}
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := prepareShareLink(link)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO share_links (id, token_hash, user_id, snippet_id, folder_id, password_hash, expires_at, max_views, views, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, $9)",
+		link.ID,
+		hashToken(link.Token),
+		link.UserID,
+		link.SnippetID,
+		link.FolderID,
+		passwordHash,
+		link.ExpiresAt,
+		link.MaxViews,
+		link.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const shareLinkColumns = "id, user_id, snippet_id, folder_id, password_hash, expires_at, max_views, views, created_at"
+
+func scanShareLink(row interface{ Scan(...any) error }) (models.ShareLink, error) {
+	var link models.ShareLink
+	var passwordHash sql.NullString
+	err := row.Scan(&link.ID, &link.UserID, &link.SnippetID, &link.FolderID, &passwordHash,
+		&link.ExpiresAt, &link.MaxViews, &link.Views, &link.CreatedAt)
+	if err != nil {
+		return link, err
+	}
+	link.Password = passwordHash.String
+	link.HasPassword = passwordHash.Valid
+	return link, nil
+}
+
+func getShareLink(db *sql.DB, token string) (models.ShareLink, error) {
+	link, err := scanShareLink(db.QueryRow(
+		"SELECT "+shareLinkColumns+" FROM share_links WHERE token_hash = $1",
+		hashToken(token),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return link, ErrShareLinkNotFound
+	}
+	if err != nil {
+		return link, err
+	}
+	if !link.Active(time.Now()) {
+		return models.ShareLink{}, ErrShareLinkNotFound
+	}
+	return link, nil
+}
+
+func recordShareView(db *sql.DB, id uuid.UUID) error {
+	result, err := db.Exec(
+		"UPDATE share_links SET views = views + 1 WHERE id = $1 AND (max_views IS NULL OR views < max_views)",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result, ErrShareLinkNotFound)
+}
+
+func listShareLinks(db *sql.DB, userID uuid.UUID) ([]models.ShareLink, error) {
+	rows, err := db.Query("SELECT "+shareLinkColumns+" FROM share_links WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.ShareLink{}
+	now := time.Now()
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		if link.Active(now) {
+			link.Password = ""
+			links = append(links, link)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortShareLinks(links)
+	return links, nil
+}
+
+// sortShareLinks orders links newest first
+func sortShareLinks(links []models.ShareLink) {
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+}
+
+func deleteShareLink(db *sql.DB, userID, id uuid.UUID) error {
+	result, err := db.Exec("DELETE FROM share_links WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result, ErrShareLinkNotFound)
+}
+
+func shareFolder(db *sql.DB, ownerID uuid.UUID, share *models.FolderShare) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkFolderOwner(tx, ownerID, &share.FolderID); err != nil {
+		return err
+	}
+	isWorkspace, err := workspaceExists(tx, share.UserID)
+	if err != nil {
+		return err
+	}
+	if isWorkspace {
+		return ErrUserNotFound
+	}
+
+	share.CreatedAt = time.Now()
+	_, err = tx.Exec(`
+        INSERT INTO folder_shares (folder_id, user_id, permission, created_at) VALUES ($1, $2, $3, $4)
+        ON CONFLICT (folder_id, user_id) DO UPDATE SET permission = excluded.permission, created_at = excluded.created_at
+    `, share.FolderID, share.UserID, share.Permission, share.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func listFolderShares(db *sql.DB, ownerID, folderID uuid.UUID) ([]models.FolderShare, error) {
+	if err := checkFolderOwner(db, ownerID, &folderID); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`
+        SELECT s.folder_id, s.user_id, u.username, s.permission, s.created_at
+        FROM folder_shares s
+        JOIN users u ON u.id = s.user_id
+        WHERE s.folder_id = $1
+    `, folderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []models.FolderShare{}
+	for rows.Next() {
+		var share models.FolderShare
+		if err := rows.Scan(&share.FolderID, &share.UserID, &share.Username, &share.Permission, &share.CreatedAt); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortFolderShares(shares)
+	return shares, nil
+}
+
+// sortFolderShares orders grants by username, ignoring case
+func sortFolderShares(shares []models.FolderShare) {
+	sort.Slice(shares, func(i, j int) bool {
+		a, b := strings.ToLower(shares[i].Username), strings.ToLower(shares[j].Username)
+		if a != b {
+			return a < b
+		}
+		return shares[i].Username < shares[j].Username
+	})
+}
+
+func unshareFolder(db *sql.DB, ownerID, folderID, userID uuid.UUID) error {
+	result, err := db.Exec(
+		"DELETE FROM folder_shares WHERE folder_id = $1 AND user_id = $2 AND folder_id IN (SELECT id FROM folders WHERE user_id = $3)",
+		folderID,
+		userID,
+		ownerID,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result, ErrFolderShareNotFound)
+}
+
+func listIncomingShares(db *sql.DB, userID uuid.UUID) ([]models.IncomingShare, error) {
+	rows, err := db.Query(`
+        SELECT f.id, f.name, f.parent_id, f.user_id, f.created_at, f.updated_at, u.username, s.permission, s.created_at
+        FROM folder_shares s
+        JOIN folders f ON f.id = s.folder_id
+        JOIN users u ON u.id = f.user_id
+        WHERE s.user_id = $1
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []models.IncomingShare{}
+	for rows.Next() {
+		var share models.IncomingShare
+		f := &share.Folder
+		err := rows.Scan(&f.ID, &f.Name, &f.ParentID, &f.UserID, &f.CreatedAt, &f.UpdatedAt,
+			&share.Owner, &share.Permission, &share.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortIncomingShares(shares)
+	return shares, nil
+}
+
+// sortIncomingShares orders shared folders by name, ignoring case
+func sortIncomingShares(shares []models.IncomingShare) {
+	sort.Slice(shares, func(i, j int) bool {
+		a, b := strings.ToLower(shares[i].Folder.Name), strings.ToLower(shares[j].Folder.Name)
+		if a != b {
+			return a < b
+		}
+		return shares[i].Folder.ID.String() < shares[j].Folder.ID.String()
+	})
+}
+
+func folderAccess(q queryer, userID, folderID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	var ownerID uuid.UUID
+	err := q.QueryRow("SELECT user_id FROM folders WHERE id = $1", folderID).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, "", ErrFolderNotFound
+	}
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if ownerID == userID {
+		return ownerID, models.PermissionWrite, nil
+	}
+
+	// "write" sorts after "read", so MAX picks the strongest grant
+	var permission sql.NullString
+	err = q.QueryRow(`
+        WITH RECURSIVE ancestors (id, parent_id) AS (
+            SELECT id, parent_id FROM folders WHERE id = $1
+            UNION
+            SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+        )
+        SELECT MAX(s.permission) FROM folder_shares s JOIN ancestors a ON a.id = s.folder_id
+        WHERE s.user_id = $2
+    `, folderID, userID).Scan(&permission)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if !permission.Valid {
+		return uuid.Nil, "", ErrFolderNotFound
+	}
+	return ownerID, models.Permission(permission.String), nil
+}
+
+func snippetAccess(q queryer, userID, snippetID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	var ownerID uuid.UUID
+	var folderID *uuid.UUID
+	err := q.QueryRow("SELECT user_id, folder_id FROM snippets WHERE id = $1", snippetID).Scan(&ownerID, &folderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, "", ErrSnippetNotFound
+	}
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if ownerID == userID {
+		return ownerID, models.PermissionWrite, nil
+	}
+	if folderID == nil {
+		return uuid.Nil, "", ErrSnippetNotFound
+	}
+	ownerID, permission, err := folderAccess(q, userID, *folderID)
+	if errors.Is(err, ErrFolderNotFound) {
+		return uuid.Nil, "", ErrSnippetNotFound
+	}
+	return ownerID, permission, err
+}
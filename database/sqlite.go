@@ -0,0 +1,800 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+
+	"snippet-manager-go/models"
+)
+
+// SQLiteStorage is a Store backed by a single SQLite database file. It is
+// meant for personal installs where running Postgres is not worth it.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	// Foreign keys are off by default in SQLite and have to be enabled per
+	// connection. Immediate transactions take the write lock up front so
+	// concurrent writers wait on the busy timeout instead of failing.
+	connStr := fmt.Sprintf(
+		"file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		path,
+	)
+	db, err := sql.Open("sqlite3", connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+	return &SQLiteStorage{db: db}, nil
+}
+
+func (s *SQLiteStorage) CreateUser(user *models.User) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.ID = uuid.New()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+
+	_, err = s.db.Exec(
+		"INSERT INTO users (id, username, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		user.ID,
+		user.Username,
+		user.Email,
+		string(hashedPassword),
+		user.CreatedAt,
+		user.UpdatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetUserByUsername(username string) (*models.User, error) {
+	user := &models.User{}
+	err := s.db.QueryRow(
+		"SELECT id, username, email, password, created_at, updated_at FROM users WHERE username = ?",
+		username,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *SQLiteStorage) GetUserByEmail(email string) (*models.User, error) {
+	user := &models.User{}
+	err := s.db.QueryRow(
+		"SELECT id, username, email, password, created_at, updated_at FROM users WHERE email = ?",
+		email,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *SQLiteStorage) GetUserByID(id uuid.UUID) (*models.User, error) {
+	user := &models.User{}
+	err := s.db.QueryRow(
+		"SELECT id, username, email, created_at, updated_at FROM users WHERE id = ?",
+		id,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *SQLiteStorage) Create(snippet models.Snippet) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkFolderOwner(tx, snippet.UserID, snippet.FolderID); err != nil {
+		return err
+	}
+
+	// Timestamps are stored as text, so they are kept in one zone to sort
+	// correctly
+	snippet.CreatedAt = time.Now().UTC()
+	snippet.UpdatedAt = snippet.CreatedAt
+
+	_, err = tx.Exec(
+		"INSERT INTO snippets (id, title, description, language, code, user_id, folder_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		snippet.ID,
+		snippet.Title,
+		snippet.Description,
+		snippet.Language,
+		snippet.Code,
+		snippet.UserID,
+		snippet.FolderID,
+		snippet.CreatedAt,
+		snippet.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := attachTags(tx, snippet.UserID, snippet.ID, snippet.Tags); err != nil {
+		return err
+	}
+
+	if err := recordRevision(tx, snippet); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) Update(snippet models.Snippet) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkFolderOwner(tx, snippet.UserID, snippet.FolderID); err != nil {
+		return err
+	}
+
+	snippet.UpdatedAt = time.Now().UTC()
+	result, err := tx.Exec(
+		"UPDATE snippets SET title = ?, description = ?, language = ?, code = ?, folder_id = ?, updated_at = ? WHERE id = ? AND user_id = ?",
+		snippet.Title,
+		snippet.Description,
+		snippet.Language,
+		snippet.Code,
+		snippet.FolderID,
+		snippet.UpdatedAt,
+		snippet.ID,
+		snippet.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result, ErrSnippetNotFound); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = ?", snippet.ID); err != nil {
+		return err
+	}
+	if err := attachTags(tx, snippet.UserID, snippet.ID, snippet.Tags); err != nil {
+		return err
+	}
+	if err := deleteOrphanTags(tx, snippet.UserID); err != nil {
+		return err
+	}
+
+	if err := recordRevision(tx, snippet); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) GetAll(userID uuid.UUID) ([]models.Snippet, error) {
+	rows, err := s.db.Query(
+		"SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE user_id = ?",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadTags(snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+func (s *SQLiteStorage) CountLanguages(userID uuid.UUID) (map[string]int, error) {
+	return countLanguages(s.db, userID)
+}
+
+func (s *SQLiteStorage) List(userID uuid.UUID, opts ListOptions) (models.SnippetPage, error) {
+	after, err := opts.decodeCursor()
+	if err != nil {
+		return models.SnippetPage{}, err
+	}
+	query, args := listQuery(userID, opts, after)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return models.SnippetPage{}, err
+	}
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return models.SnippetPage{}, err
+	}
+	page := opts.page(snippets)
+	if err := s.loadTags(page.Snippets); err != nil {
+		return models.SnippetPage{}, err
+	}
+	return page, nil
+}
+
+func (s *SQLiteStorage) Get(userID, id uuid.UUID) (models.Snippet, error) {
+	var snip models.Snippet
+	err := s.db.QueryRow("SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE id = ? AND user_id = ?", id, userID).
+		Scan(&snip.ID, &snip.Title, &snip.Description, &snip.Language, &snip.Code, &snip.UserID, &snip.FolderID, &snip.CreatedAt, &snip.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return snip, ErrSnippetNotFound
+	}
+	if err != nil {
+		return snip, err
+	}
+	tags, err := s.GetSnippetTags(id)
+	if err != nil {
+		return snip, err
+	}
+	snip.Tags = tags
+	return snip, nil
+}
+
+func (s *SQLiteStorage) Delete(userID, id uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM snippets WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result, ErrSnippetNotFound); err != nil {
+		return err
+	}
+	if err := deleteOrphanTags(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ListRevisions(userID, snippetID uuid.UUID) ([]models.Revision, error) {
+	return listRevisions(s.db, userID, snippetID)
+}
+
+func (s *SQLiteStorage) GetRevision(userID, snippetID uuid.UUID, number int) (models.Revision, error) {
+	return getRevision(s.db, userID, snippetID, number)
+}
+
+func (s *SQLiteStorage) RestoreRevision(userID, snippetID uuid.UUID, number int) (models.Snippet, error) {
+	if err := restoreRevision(s.db, userID, snippetID, number); err != nil {
+		return models.Snippet{}, err
+	}
+	return s.Get(userID, snippetID)
+}
+
+func (s *SQLiteStorage) Search(userID uuid.UUID, opts SearchOptions) ([]models.SearchResult, error) {
+	query := parseSearchQuery(opts.Query)
+	if query.empty() {
+		return nil, nil
+	}
+
+	args := []any{query.ftsMatch(), userID}
+	where := []string{"snippets_fts MATCH ?", "s.user_id = ?"}
+	if opts.Language != "" {
+		where = append(where, "lower(s.language) = lower(?)")
+		args = append(args, opts.Language)
+	}
+	if opts.FolderID != nil {
+		where = append(where, "s.folder_id = ?")
+		args = append(args, *opts.FolderID)
+	}
+	for _, tag := range opts.Tags {
+		where = append(where, `EXISTS (
+            SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+            WHERE st.snippet_id = s.id AND t.name = ?)`)
+		args = append(args, tag)
+	}
+
+	rows, err := s.db.Query(`
+        SELECT s.id, s.title, s.description, s.language, s.code, s.user_id, s.folder_id, s.created_at, s.updated_at,
+            matchinfo(snippets_fts, 'pcx'),
+            snippet(snippets_fts, '`+sentinelStart+`', '`+sentinelStop+`', '…', 0, 64),
+            snippet(snippets_fts, '`+sentinelStart+`', '`+sentinelStop+`', '…', 1, 24),
+            snippet(snippets_fts, '`+sentinelStart+`', '`+sentinelStop+`', '…', 2, 24)
+        FROM snippets_fts
+        JOIN snippets_fts_ids m ON m.docid = snippets_fts.docid
+        JOIN snippets s ON s.id = m.snippet_id
+        WHERE `+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var res models.SearchResult
+		var matchinfo []byte
+		snip := &res.Snippet
+		h := &res.Highlights
+		if err := rows.Scan(&snip.ID, &snip.Title, &snip.Description, &snip.Language, &snip.Code, &snip.UserID, &snip.FolderID, &snip.CreatedAt, &snip.UpdatedAt,
+			&matchinfo, &h.Title, &h.Description, &h.Code); err != nil {
+			return nil, err
+		}
+		res.Rank = matchinfoRank(matchinfo)
+		res.Highlights = markHighlights(res.Highlights)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// FTS4 has no built-in ranking function, so results are ordered here
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Snippet.UpdatedAt.After(results[j].Snippet.UpdatedAt)
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	if err := loadResultTags(results, s.loadTags); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *SQLiteStorage) AddTag(userID, snippetID uuid.UUID, tagName string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := addTag(tx, userID, snippetID, tagName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) RemoveTag(userID, snippetID uuid.UUID, tagName string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := removeTag(tx, userID, snippetID, tagName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ListTags(userID uuid.UUID) ([]models.Tag, error) {
+	return listTags(s.db, userID)
+}
+
+func (s *SQLiteStorage) RenameTag(userID uuid.UUID, name, newName string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := renameTag(tx, userID, name, newName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) MergeTags(userID uuid.UUID, source, target string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := mergeTags(tx, userID, source, target); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) DeleteTag(userID uuid.UUID, name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteTag(tx, userID, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) GetSnippetTags(snippetID uuid.UUID) ([]string, error) {
+	rows, err := s.db.Query(`
+        SELECT t.name
+        FROM tags t
+        JOIN snippet_tags st ON t.id = st.tag_id
+        WHERE st.snippet_id = ?
+    `, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *SQLiteStorage) CreateFolder(folder models.Folder) error {
+	if err := checkFolderOwner(s.db, folder.UserID, folder.ParentID); err != nil {
+		return err
+	}
+
+	folder.CreatedAt = time.Now()
+	folder.UpdatedAt = time.Now()
+	_, err := s.db.Exec(
+		"INSERT INTO folders (id, name, parent_id, user_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		folder.ID,
+		folder.Name,
+		folder.ParentID,
+		folder.UserID,
+		folder.CreatedAt,
+		folder.UpdatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetFolder(userID, folderID uuid.UUID) (models.Folder, error) {
+	return getFolder(s.db, userID, folderID)
+}
+
+func (s *SQLiteStorage) GetFoldersByUser(userID uuid.UUID) ([]models.Folder, error) {
+	rows, err := s.db.Query(
+		"SELECT id, name, parent_id, user_id, created_at, updated_at FROM folders WHERE user_id = ?",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanFolders(rows)
+}
+
+func (s *SQLiteStorage) GetFolderContents(
+	userID, folderID uuid.UUID,
+) ([]models.Snippet, []models.Folder, error) {
+	if err := checkFolderOwner(s.db, userID, &folderID); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := s.db.Query(
+		"SELECT id, title, description, language, code, user_id, folder_id, created_at, updated_at FROM snippets WHERE folder_id = ? AND user_id = ?",
+		folderID,
+		userID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	snippetList, err := scanSnippets(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.loadTags(snippetList); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = s.db.Query(
+		"SELECT id, name, parent_id, user_id, created_at, updated_at FROM folders WHERE parent_id = ? AND user_id = ?",
+		folderID,
+		userID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	folderList, err := scanFolders(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snippetList, folderList, nil
+}
+
+func (s *SQLiteStorage) GetFolderTree(userID uuid.UUID, opts FolderTreeOptions) ([]models.FolderNode, error) {
+	return getFolderTree(s.db, userID, opts)
+}
+
+func (s *SQLiteStorage) GetFolderPath(userID, folderID uuid.UUID) ([]models.Folder, error) {
+	return getFolderPath(s.db, userID, folderID)
+}
+
+func (s *SQLiteStorage) UpdateFolder(folder models.Folder) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateFolder(tx, folder, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) DeleteFolder(userID, folderID uuid.UUID, mode FolderDeleteMode) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteFolder(tx, userID, folderID, mode); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) Import(userID uuid.UUID, archive models.Archive, opts ImportOptions) (models.ImportResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := importArchive(tx, userID, archive, opts)
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+	return result, tx.Commit()
+}
+
+func (s *SQLiteStorage) CreateShareLink(link *models.ShareLink) error {
+	return createShareLink(s.db, link)
+}
+
+func (s *SQLiteStorage) GetShareLink(token string) (models.ShareLink, error) {
+	return getShareLink(s.db, token)
+}
+
+func (s *SQLiteStorage) RecordShareView(id uuid.UUID) error {
+	return recordShareView(s.db, id)
+}
+
+func (s *SQLiteStorage) ListShareLinks(userID uuid.UUID) ([]models.ShareLink, error) {
+	return listShareLinks(s.db, userID)
+}
+
+func (s *SQLiteStorage) DeleteShareLink(userID, id uuid.UUID) error {
+	return deleteShareLink(s.db, userID, id)
+}
+
+func (s *SQLiteStorage) ShareFolder(ownerID uuid.UUID, share *models.FolderShare) error {
+	return shareFolder(s.db, ownerID, share)
+}
+
+func (s *SQLiteStorage) ListFolderShares(ownerID, folderID uuid.UUID) ([]models.FolderShare, error) {
+	return listFolderShares(s.db, ownerID, folderID)
+}
+
+func (s *SQLiteStorage) UnshareFolder(ownerID, folderID, userID uuid.UUID) error {
+	return unshareFolder(s.db, ownerID, folderID, userID)
+}
+
+func (s *SQLiteStorage) ListIncomingShares(userID uuid.UUID) ([]models.IncomingShare, error) {
+	return listIncomingShares(s.db, userID)
+}
+
+func (s *SQLiteStorage) FolderAccess(userID, folderID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	return folderAccess(s.db, userID, folderID)
+}
+
+func (s *SQLiteStorage) SnippetAccess(userID, snippetID uuid.UUID) (uuid.UUID, models.Permission, error) {
+	return snippetAccess(s.db, userID, snippetID)
+}
+
+func (s *SQLiteStorage) CreateWorkspace(workspace *models.Workspace, ownerID uuid.UUID) error {
+	return createWorkspace(s.db, workspace, ownerID)
+}
+
+func (s *SQLiteStorage) GetWorkspace(userID, id uuid.UUID) (models.Workspace, error) {
+	return getWorkspace(s.db, userID, id)
+}
+
+func (s *SQLiteStorage) ListWorkspaces(userID uuid.UUID) ([]models.Workspace, error) {
+	return listWorkspaces(s.db, userID)
+}
+
+func (s *SQLiteStorage) DeleteWorkspace(id uuid.UUID) error {
+	return deleteWorkspace(s.db, id)
+}
+
+func (s *SQLiteStorage) ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error) {
+	return listMembers(s.db, workspaceID)
+}
+
+func (s *SQLiteStorage) AddMember(workspaceID uuid.UUID, member *models.WorkspaceMember) error {
+	return addMember(s.db, workspaceID, member)
+}
+
+func (s *SQLiteStorage) SetMemberRole(workspaceID, userID uuid.UUID, role models.Role) error {
+	return setMemberRole(s.db, workspaceID, userID, role)
+}
+
+func (s *SQLiteStorage) RemoveMember(workspaceID, userID uuid.UUID) error {
+	return removeMember(s.db, workspaceID, userID)
+}
+
+func (s *SQLiteStorage) CreateSession(session *models.Session) error {
+	return createSession(s.db, session)
+}
+
+func (s *SQLiteStorage) GetSession(refreshToken string) (models.Session, error) {
+	return getSession(s.db, refreshToken)
+}
+
+func (s *SQLiteStorage) RotateSession(refreshToken string, session *models.Session) error {
+	return rotateSession(s.db, refreshToken, session)
+}
+
+func (s *SQLiteStorage) ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	return listSessions(s.db, userID)
+}
+
+func (s *SQLiteStorage) DeleteSession(userID, id uuid.UUID) error {
+	return deleteSession(s.db, userID, id)
+}
+
+func (s *SQLiteStorage) DeleteSessions(userID uuid.UUID) error {
+	return deleteSessions(s.db, userID)
+}
+
+func (s *SQLiteStorage) IsTokenRevoked(tokenID uuid.UUID) (bool, error) {
+	return isTokenRevoked(s.db, tokenID)
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// sqliteTagBatch bounds the number of snippet IDs bound to one tag query,
+// well below SQLite's limit on host parameters
+const sqliteTagBatch = 500
+
+// loadTags fills in the tags of each snippet with one query per
+// sqliteTagBatch snippets. It runs after the snippet rows have been closed
+// so it never needs a second connection.
+func (s *SQLiteStorage) loadTags(snippets []models.Snippet) error {
+	for start := 0; start < len(snippets); start += sqliteTagBatch {
+		batch := snippets[start:min(start+sqliteTagBatch, len(snippets))]
+		args := make([]any, len(batch))
+		for i, snip := range batch {
+			args[i] = snip.ID
+		}
+
+		rows, err := s.db.Query(`
+            SELECT st.snippet_id, t.name
+            FROM snippet_tags st
+            JOIN tags t ON t.id = st.tag_id
+            WHERE st.snippet_id IN (`+strings.Repeat("?, ", len(batch)-1)+`?)
+        `, args...)
+		if err != nil {
+			return err
+		}
+		if err := assignTags(rows, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchinfoRank scores an FTS4 matchinfo 'pcx' blob. Each phrase scores the
+// share of its total hits that fall in this row, weighted by column like the
+// Postgres index weights title, description and code.
+func matchinfoRank(info []byte) float64 {
+	columnWeights := []float64{1.0, 0.4, 0.2}
+
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(values) < 2 {
+		return 0
+	}
+	phrases, columns := int(values[0]), int(values[1])
+
+	var rank float64
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns && c < len(columnWeights); c++ {
+			base := 2 + 3*(p*columns+c)
+			if base+1 >= len(values) {
+				return rank
+			}
+			hitsThisRow, hitsAllRows := values[base], values[base+1]
+			if hitsAllRows > 0 {
+				rank += columnWeights[c] * float64(hitsThisRow) / float64(hitsAllRows)
+			}
+		}
+	}
+	return rank
+}
+
+// assignTags reads and closes a result set of (snippet_id, tag name) rows
+// and sets the tags of the matching snippets
+func assignTags(rows *sql.Rows, snippets []models.Snippet) error {
+	defer rows.Close()
+
+	index := make(map[uuid.UUID]int, len(snippets))
+	for i := range snippets {
+		snippets[i].Tags = nil
+		index[snippets[i].ID] = i
+	}
+	for rows.Next() {
+		var snippetID uuid.UUID
+		var name string
+		if err := rows.Scan(&snippetID, &name); err != nil {
+			return err
+		}
+		if i, ok := index[snippetID]; ok {
+			snippets[i].Tags = append(snippets[i].Tags, name)
+		}
+	}
+	return rows.Err()
+}
+
+// scanSnippets reads and closes a result set of snippet rows selected in
+// the column order used throughout this package
+func scanSnippets(rows *sql.Rows) ([]models.Snippet, error) {
+	defer rows.Close()
+
+	var snippets []models.Snippet
+	for rows.Next() {
+		var snip models.Snippet
+		if err := rows.Scan(&snip.ID, &snip.Title, &snip.Description, &snip.Language, &snip.Code, &snip.UserID, &snip.FolderID, &snip.CreatedAt, &snip.UpdatedAt); err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, snip)
+	}
+	return snippets, rows.Err()
+}
+
+// scanFolders reads and closes a result set of folder rows selected as
+// id, name, parent_id, user_id, created_at, updated_at
+func scanFolders(rows *sql.Rows) ([]models.Folder, error) {
+	defer rows.Close()
+
+	var folders []models.Folder
+	for rows.Next() {
+		var folder models.Folder
+		if err := rows.Scan(&folder.ID, &folder.Name, &folder.ParentID, &folder.UserID, &folder.CreatedAt, &folder.UpdatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	return folders, rows.Err()
+}
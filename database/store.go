@@ -0,0 +1,202 @@
+package database
+
+import (
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// UserStore persists user accounts. Passwords are hashed by the store.
+type UserStore interface {
+	CreateUser(user *models.User) error
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	GetUserByID(id uuid.UUID) (*models.User, error)
+}
+
+// SnippetStore persists snippets. Every method is scoped to the owning user
+// and reports ErrSnippetNotFound for snippets owned by someone else.
+type SnippetStore interface {
+	Create(snippet models.Snippet) error
+	Update(snippet models.Snippet) error
+	GetAll(userID uuid.UUID) ([]models.Snippet, error)
+	List(userID uuid.UUID, opts ListOptions) (models.SnippetPage, error)
+	Get(userID, id uuid.UUID) (models.Snippet, error)
+	Delete(userID, id uuid.UUID) error
+	// CountLanguages returns the number of snippets of the user in each
+	// language
+	CountLanguages(userID uuid.UUID) (map[string]int, error)
+}
+
+// RevisionStore reads the history of a snippet. Revisions are listed newest
+// first.
+type RevisionStore interface {
+	ListRevisions(userID, snippetID uuid.UUID) ([]models.Revision, error)
+	GetRevision(userID, snippetID uuid.UUID, number int) (models.Revision, error)
+	// RestoreRevision saves the content of an old revision as the current
+	// state of the snippet, which records it as a new revision
+	RestoreRevision(userID, snippetID uuid.UUID, number int) (models.Snippet, error)
+}
+
+// SearchStore runs full-text searches over a user's snippets. Results are
+// ordered by decreasing relevance.
+type SearchStore interface {
+	Search(userID uuid.UUID, opts SearchOptions) ([]models.SearchResult, error)
+}
+
+// TagStore manages the tags of each user. Tags are created on first use and
+// deleted once no snippet carries them. Every snippet whose tags change is
+// saved as by an update: its updated_at moves and a revision is recorded.
+type TagStore interface {
+	AddTag(userID, snippetID uuid.UUID, tagName string) error
+	RemoveTag(userID, snippetID uuid.UUID, tagName string) error
+	// ListTags returns the user's tags ordered by name
+	ListTags(userID uuid.UUID) ([]models.Tag, error)
+	// RenameTag renames a tag on every snippet. Renaming it to the name of
+	// another tag returns ErrTagExists; see MergeTags.
+	RenameTag(userID uuid.UUID, name, newName string) error
+	// MergeTags replaces the source tag with the target tag on every
+	// snippet and deletes the source tag
+	MergeTags(userID uuid.UUID, source, target string) error
+	DeleteTag(userID uuid.UUID, name string) error
+}
+
+// FolderStore persists the folder hierarchy of each user.
+type FolderStore interface {
+	CreateFolder(folder models.Folder) error
+	GetFolder(userID, folderID uuid.UUID) (models.Folder, error)
+	GetFoldersByUser(userID uuid.UUID) ([]models.Folder, error)
+	GetFolderContents(userID, folderID uuid.UUID) ([]models.Snippet, []models.Folder, error)
+	// GetFolderTree returns the user's top-level folders with their
+	// subfolders nested below them
+	GetFolderTree(userID uuid.UUID, opts FolderTreeOptions) ([]models.FolderNode, error)
+	// GetFolderPath returns the folders from the top level down to and
+	// including the given folder
+	GetFolderPath(userID, folderID uuid.UUID) ([]models.Folder, error)
+	// UpdateFolder sets the name and parent of a folder. Moving a folder
+	// below itself returns ErrFolderCycle.
+	UpdateFolder(folder models.Folder) error
+	DeleteFolder(userID, folderID uuid.UUID, mode FolderDeleteMode) error
+}
+
+// ArchiveStore restores exported libraries. An import either succeeds as a
+// whole or changes nothing.
+type ArchiveStore interface {
+	Import(userID uuid.UUID, archive models.Archive, opts ImportOptions) (models.ImportResult, error)
+}
+
+// ShareStore persists share links. The store hashes tokens and passwords,
+// so a link can only be found with the token it was created with.
+type ShareStore interface {
+	// CreateShareLink stores a link to a snippet or folder of the user,
+	// setting its creation time and replacing its password with a flag. It
+	// returns ErrSnippetNotFound or ErrFolderNotFound if the user does not
+	// own the target.
+	CreateShareLink(link *models.ShareLink) error
+	// GetShareLink finds an active link by its token, with Password set to
+	// the hash of its password
+	GetShareLink(token string) (models.ShareLink, error)
+	// RecordShareView counts a view of a link. It returns
+	// ErrShareLinkNotFound if the link has used up its views.
+	RecordShareView(id uuid.UUID) error
+	// ListShareLinks returns the user's active links, newest first
+	ListShareLinks(userID uuid.UUID) ([]models.ShareLink, error)
+	DeleteShareLink(userID, id uuid.UUID) error
+}
+
+// FolderShareStore persists grants of access to folders of other users. A
+// grant covers the folder and everything below it.
+type FolderShareStore interface {
+	// ShareFolder grants a user access to a folder of ownerID, replacing
+	// any earlier grant to that user, and sets the creation time. It returns
+	// ErrFolderNotFound if ownerID does not own the folder and
+	// ErrUserNotFound for accounts of workspaces.
+	ShareFolder(ownerID uuid.UUID, share *models.FolderShare) error
+	// ListFolderShares returns the grants on a folder of ownerID ordered by
+	// username. Grants on its ancestors are not included.
+	ListFolderShares(ownerID, folderID uuid.UUID) ([]models.FolderShare, error)
+	UnshareFolder(ownerID, folderID, userID uuid.UUID) error
+	// ListIncomingShares returns the folders shared with the user by name
+	ListIncomingShares(userID uuid.UUID) ([]models.IncomingShare, error)
+	// FolderAccess returns the owner of a folder and the permission of the
+	// user on it, granted on the folder or one of its ancestors. Owners have
+	// PermissionWrite. It returns ErrFolderNotFound if the user has no
+	// access.
+	FolderAccess(userID, folderID uuid.UUID) (uuid.UUID, models.Permission, error)
+	// SnippetAccess does the same for a snippet through its folder,
+	// returning ErrSnippetNotFound if the user has no access
+	SnippetAccess(userID, snippetID uuid.UUID) (uuid.UUID, models.Permission, error)
+}
+
+// WorkspaceStore persists workspaces and their members. A workspace has an
+// account of its own, with the ID of the workspace, that owns its folders
+// and snippets, so the other stores handle them as any user's.
+type WorkspaceStore interface {
+	// CreateWorkspace creates a workspace and its account, with ownerID as
+	// its only member. It sets the ID, role and creation time.
+	CreateWorkspace(workspace *models.Workspace, ownerID uuid.UUID) error
+	// GetWorkspace returns a workspace with the role of the user in it. It
+	// returns ErrWorkspaceNotFound if the user is not a member.
+	GetWorkspace(userID, id uuid.UUID) (models.Workspace, error)
+	// ListWorkspaces returns the workspaces the user is a member of, by name
+	ListWorkspaces(userID uuid.UUID) ([]models.Workspace, error)
+	// DeleteWorkspace deletes a workspace with its folders and snippets
+	DeleteWorkspace(id uuid.UUID) error
+	// ListMembers returns the members of a workspace ordered by username
+	ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error)
+	// AddMember adds a user to a workspace, setting the creation time. It
+	// returns ErrMemberExists if the user is a member already and
+	// ErrUserNotFound for accounts of workspaces.
+	AddMember(workspaceID uuid.UUID, member *models.WorkspaceMember) error
+	// SetMemberRole and RemoveMember return ErrLastOwner rather than leave
+	// a workspace without an owner
+	SetMemberRole(workspaceID, userID uuid.UUID, role models.Role) error
+	RemoveMember(workspaceID, userID uuid.UUID) error
+}
+
+// SessionStore persists login sessions and the IDs of the access tokens
+// revoked before their expiry. The store hashes refresh tokens, so a session
+// can only be found with the last token it was given.
+type SessionStore interface {
+	// CreateSession stores a new session, setting its creation and last use
+	// times. It also deletes the expired sessions of the user.
+	CreateSession(session *models.Session) error
+	// GetSession returns the active session holding a refresh token
+	GetSession(refreshToken string) (models.Session, error)
+	// RotateSession replaces the refresh token of the session holding
+	// refreshToken with session.RefreshToken, along with its access token,
+	// expiry, IP and user agent, and revokes its previous access token. It
+	// fills in the rest of session and returns ErrSessionNotFound if no
+	// active session holds refreshToken.
+	RotateSession(refreshToken string, session *models.Session) error
+	// ListSessions returns the active sessions of the user, most recently
+	// used first
+	ListSessions(userID uuid.UUID) ([]models.Session, error)
+	// DeleteSession ends a session of the user and revokes its access token
+	DeleteSession(userID, id uuid.UUID) error
+	// DeleteSessions does the same for every session of the user
+	DeleteSessions(userID uuid.UUID) error
+	IsTokenRevoked(tokenID uuid.UUID) (bool, error)
+}
+
+// Store is the storage backend used by the HTTP handlers
+type Store interface {
+	UserStore
+	SnippetStore
+	RevisionStore
+	SearchStore
+	TagStore
+	FolderStore
+	ArchiveStore
+	ShareStore
+	FolderShareStore
+	WorkspaceStore
+	SessionStore
+	Close() error
+}
+
+var (
+	_ Store = (*PostgresStorage)(nil)
+	_ Store = (*SQLiteStorage)(nil)
+	_ Store = (*MemoryStorage)(nil)
+)
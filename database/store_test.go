@@ -0,0 +1,60 @@
+package database_test
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	database "snippet-manager-go/database"
+	"snippet-manager-go/database/storetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storetest.Run(t, func(t testing.TB) database.Store { return database.NewMemoryStorage() })
+}
+
+// TestPostgresStorage runs against the database named by
+// SNIPPET_TEST_POSTGRES_DSN, which it empties before every subtest
+func TestPostgresStorage(t *testing.T) {
+	dsn := os.Getenv("SNIPPET_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("SNIPPET_TEST_POSTGRES_DSN is not set")
+	}
+	storetest.Run(t, func(t testing.TB) database.Store {
+		s, err := database.NewPostgresStorage(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Migrator().Down(math.MaxInt); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Migrator().Up(); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	storetest.Run(t, newSQLiteStorage)
+}
+
+func BenchmarkMemoryStorage(b *testing.B) {
+	storetest.Benchmark(b, func(t testing.TB) database.Store { return database.NewMemoryStorage() })
+}
+
+func BenchmarkSQLiteStorage(b *testing.B) {
+	storetest.Benchmark(b, newSQLiteStorage)
+}
+
+// newSQLiteStorage returns a migrated store in a temporary directory
+func newSQLiteStorage(t testing.TB) database.Store {
+	s, err := database.NewSQLiteStorage(t.TempDir() + "/snippets.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Migrator().Up(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
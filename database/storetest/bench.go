@@ -0,0 +1,99 @@
+package storetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+
+	database "snippet-manager-go/database"
+	"snippet-manager-go/models"
+)
+
+// benchSnippets is the size of the benchmark fixture
+const benchSnippets = 1000
+
+// Benchmark measures the snippet listing paths of a store against a fixture
+// of benchSnippets tagged snippets in one folder:
+//
+//	func BenchmarkSQLiteStorage(b *testing.B) {
+//		storetest.Benchmark(b, newSQLiteStore)
+//	}
+//
+// PerSnippetGet loads the same snippets one query at a time and serves as
+// the baseline the batched listings are compared with.
+func Benchmark(b *testing.B, newStore Factory) {
+	s := newStore(b)
+	b.Cleanup(func() { s.Close() })
+	user, folder := benchFixture(b, s)
+
+	b.Run("GetAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			snippets, err := s.GetAll(user)
+			if err != nil {
+				b.Fatal(err)
+			}
+			assertFixture(b, snippets, benchSnippets)
+		}
+	})
+	b.Run("List", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			page, err := s.List(user, database.ListOptions{Limit: 100})
+			if err != nil {
+				b.Fatal(err)
+			}
+			assertFixture(b, page.Snippets, 100)
+		}
+	})
+	b.Run("GetFolderContents", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			snippets, _, err := s.GetFolderContents(user, folder)
+			if err != nil {
+				b.Fatal(err)
+			}
+			assertFixture(b, snippets, benchSnippets)
+		}
+	})
+	b.Run("PerSnippetGet", func(b *testing.B) {
+		snippets, err := s.GetAll(user)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, snip := range snippets {
+				if _, err := s.Get(user, snip.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func benchFixture(b *testing.B, s database.Store) (uuid.UUID, uuid.UUID) {
+	b.Helper()
+	user := createUser(b, s, "bench")
+	folder := models.Folder{ID: uuid.New(), Name: "bench", UserID: user}
+	if err := s.CreateFolder(folder); err != nil {
+		b.Fatalf("CreateFolder: %v", err)
+	}
+	for i := 0; i < benchSnippets; i++ {
+		snip := newSnippet(user, fmt.Sprintf("snippet %d", i), "go", fmt.Sprintf("group-%d", i%10), fmt.Sprintf("tag-%d", i))
+		snip.FolderID = &folder.ID
+		if err := s.Create(snip); err != nil {
+			b.Fatalf("Create: %v", err)
+		}
+	}
+	return user, folder.ID
+}
+
+func assertFixture(b *testing.B, snippets []models.Snippet, want int) {
+	if len(snippets) != want {
+		b.Fatalf("got %d snippets, want %d", len(snippets), want)
+	}
+	for _, snip := range snippets {
+		if len(snip.Tags) != 3 {
+			b.Fatalf("snippet %s has tags %v, want 3", snip.ID, snip.Tags)
+		}
+	}
+}
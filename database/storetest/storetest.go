@@ -0,0 +1,1821 @@
+// Package storetest implements a conformance suite for database.Store
+// implementations. Every backend is expected to pass it:
+//
+//	func TestMemoryStorage(t *testing.T) {
+//		storetest.Run(t, func(t testing.TB) database.Store {
+//			return database.NewMemoryStorage()
+//		})
+//	}
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	database "snippet-manager-go/database"
+	"snippet-manager-go/models"
+)
+
+// Factory returns an empty store. It is called once per subtest or
+// benchmark and should register any cleanup with t.Cleanup.
+type Factory func(t testing.TB) database.Store
+
+// Run runs the conformance suite against stores returned by newStore
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s database.Store)
+	}{
+		{"Users", testUsers},
+		{"SnippetCRUD", testSnippetCRUD},
+		{"SnippetOwnership", testSnippetOwnership},
+		{"CountLanguages", testCountLanguages},
+		{"List", testList},
+		{"Revisions", testRevisions},
+		{"TagRevisions", testTagRevisions},
+		{"Search", testSearch},
+		{"SearchHighlightsEscaped", testSearchHighlightsEscaped},
+		{"Tags", testTags},
+		{"TagManagement", testTagManagement},
+		{"Folders", testFolders},
+		{"FolderOwnership", testFolderOwnership},
+		{"FolderUpdate", testFolderUpdate},
+		{"FolderDelete", testFolderDelete},
+		{"FolderTree", testFolderTree},
+		{"Import", testImport},
+		{"ShareLinks", testShareLinks},
+		{"FolderShares", testFolderShares},
+		{"Workspaces", testWorkspaces},
+		{"Sessions", testSessions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			t.Cleanup(func() { s.Close() })
+			tt.fn(t, s)
+		})
+	}
+}
+
+func testUsers(t *testing.T, s database.Store) {
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "secret"}
+	if err := s.CreateUser(&user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.ID == uuid.Nil {
+		t.Fatal("CreateUser did not assign an ID")
+	}
+
+	byName, err := s.GetUserByUsername("alice")
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if byName.ID != user.ID || byName.Email != user.Email {
+		t.Errorf("GetUserByUsername = %+v, want user %v", byName, user.ID)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(byName.Password), []byte("secret")) != nil {
+		t.Error("GetUserByUsername did not return a bcrypt hash of the password")
+	}
+
+	byID, err := s.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if byID.Username != "alice" {
+		t.Errorf("GetUserByID username = %q, want alice", byID.Username)
+	}
+	if byID.Password != "" {
+		t.Error("GetUserByID returned the password hash")
+	}
+
+	dup := models.User{Username: "alice", Email: "other@example.com", Password: "x"}
+	if err := s.CreateUser(&dup); err == nil {
+		t.Error("CreateUser accepted a duplicate username")
+	}
+	dup = models.User{Username: "other", Email: "alice@example.com", Password: "x"}
+	if err := s.CreateUser(&dup); err == nil {
+		t.Error("CreateUser accepted a duplicate email")
+	}
+
+	if _, err := s.GetUserByUsername("nobody"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUserByUsername(unknown) error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := s.GetUserByID(uuid.New()); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUserByID(unknown) error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func testSnippetCRUD(t *testing.T, s database.Store) {
+	user := createUser(t, s, "alice")
+
+	snip := newSnippet(user, "hello", "go", "fmt")
+	if err := s.Create(snip); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := s.Get(user, snip.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Title != snip.Title || got.Code != snip.Code || got.Language != snip.Language ||
+		got.Description != snip.Description || got.UserID != user {
+		t.Errorf("Get = %+v, want %+v", got, snip)
+	}
+	assertTags(t, got.Tags, "fmt", "go")
+	if got.CreatedAt.IsZero() || got.UpdatedAt.IsZero() {
+		t.Error("Create did not set timestamps")
+	}
+
+	snip.Title = "hello, world"
+	snip.Code = "fmt.Println(\"hello, world\")"
+	snip.Tags = []string{"fmt", "stdout", "stdout"}
+	if err := s.Update(snip); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err = s.Get(user, snip.ID)
+	if err != nil {
+		t.Fatalf("Get after Update: %v", err)
+	}
+	if got.Title != snip.Title || got.Code != snip.Code {
+		t.Errorf("Get after Update = %+v, want %+v", got, snip)
+	}
+	assertTags(t, got.Tags, "fmt", "stdout")
+
+	other := newSnippet(user, "other", "python")
+	if err := s.Create(other); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	all, err := s.GetAll(user)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertSnippetIDs(t, all, snip.ID, other.ID)
+
+	if err := s.Delete(user, snip.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(user, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrSnippetNotFound", err)
+	}
+	if err := s.Delete(user, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("second Delete error = %v, want ErrSnippetNotFound", err)
+	}
+	if err := s.Update(snip); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("Update after Delete error = %v, want ErrSnippetNotFound", err)
+	}
+}
+
+func testSnippetOwnership(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	snip := newSnippet(alice, "private", "go")
+	if err := s.Create(snip); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := s.Get(bob, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("Get by other user error = %v, want ErrSnippetNotFound", err)
+	}
+
+	all, err := s.GetAll(bob)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("GetAll for other user returned %d snippets, want 0", len(all))
+	}
+
+	stolen := snip
+	stolen.UserID = bob
+	stolen.Title = "stolen"
+	if err := s.Update(stolen); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("Update by other user error = %v, want ErrSnippetNotFound", err)
+	}
+	if err := s.Delete(bob, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("Delete by other user error = %v, want ErrSnippetNotFound", err)
+	}
+	if err := s.AddTag(bob, snip.ID, "mine"); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("AddTag by other user error = %v, want ErrSnippetNotFound", err)
+	}
+	if err := s.RemoveTag(bob, snip.ID, "go"); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("RemoveTag by other user error = %v, want ErrSnippetNotFound", err)
+	}
+
+	got, err := s.Get(alice, snip.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Title != snip.Title {
+		t.Errorf("title = %q after foreign update, want %q", got.Title, snip.Title)
+	}
+	assertTags(t, got.Tags, "go")
+}
+
+func testList(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	folder := models.Folder{ID: uuid.New(), Name: "scripts", UserID: alice}
+	if err := s.CreateFolder(folder); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+
+	// Created in this order, so the default listing is the reverse
+	var created []models.Snippet
+	for i, title := range []string{"echo", "delta", "charlie", "bravo", "alpha"} {
+		lang := "go"
+		if i%2 == 1 {
+			lang = "bash"
+		}
+		snip := newSnippet(alice, title, lang)
+		if i < 2 {
+			snip.Tags = append(snip.Tags, "old")
+		}
+		if i%2 == 0 {
+			snip.Tags = append(snip.Tags, "even")
+		}
+		if i == 3 {
+			snip.FolderID = &folder.ID
+		}
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		created = append(created, snip)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := s.Create(newSnippet(bob, "foxtrot", "go")); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	echo, delta, charlie, bravo, alpha := created[0], created[1], created[2], created[3], created[4]
+
+	// all pages through a listing and returns the snippets in order
+	all := func(opts database.ListOptions) []models.Snippet {
+		t.Helper()
+		var snippets []models.Snippet
+		for pages := 0; ; pages++ {
+			if pages > len(created) {
+				t.Fatalf("List(%+v) did not terminate", opts)
+			}
+			page, err := s.List(alice, opts)
+			if err != nil {
+				t.Fatalf("List(%+v): %v", opts, err)
+			}
+			if opts.Limit > 0 && len(page.Snippets) > opts.Limit {
+				t.Fatalf("List returned %d snippets, want at most %d", len(page.Snippets), opts.Limit)
+			}
+			snippets = append(snippets, page.Snippets...)
+			if page.NextCursor == "" {
+				return snippets
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+	assertOrder := func(got []models.Snippet, want ...models.Snippet) {
+		t.Helper()
+		var gotTitles, wantTitles []string
+		for _, snip := range got {
+			gotTitles = append(gotTitles, snip.Title)
+		}
+		for _, snip := range want {
+			wantTitles = append(wantTitles, snip.Title)
+		}
+		if !slices.Equal(gotTitles, wantTitles) {
+			t.Errorf("order = %v, want %v", gotTitles, wantTitles)
+		}
+	}
+
+	assertOrder(all(database.ListOptions{}), alpha, bravo, charlie, delta, echo)
+	assertOrder(all(database.ListOptions{Limit: 2}), alpha, bravo, charlie, delta, echo)
+	assertOrder(all(database.ListOptions{Limit: 2, Ascending: true}), echo, delta, charlie, bravo, alpha)
+	assertOrder(all(database.ListOptions{Limit: 3, Sort: database.SortTitle, Ascending: true}), alpha, bravo, charlie, delta, echo)
+	assertOrder(all(database.ListOptions{Limit: 1, Sort: database.SortTitle}), echo, delta, charlie, bravo, alpha)
+
+	page, err := s.List(alice, database.ListOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if page.NextCursor != "" {
+		t.Error("List returned a cursor for a page that holds every snippet")
+	}
+	if len(page.Snippets) == 5 {
+		assertTags(t, page.Snippets[0].Tags, "go", "even")
+	}
+
+	charlie.Code = "// edited"
+	if err := s.Update(charlie); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	assertOrder(all(database.ListOptions{Limit: 2, Sort: database.SortUpdatedAt}), charlie, alpha, bravo, delta, echo)
+
+	assertOrder(all(database.ListOptions{Limit: 1, Language: "BASH"}), bravo, delta)
+	assertOrder(all(database.ListOptions{Tags: []string{"old", "even"}}), echo)
+	assertOrder(all(database.ListOptions{Tags: []string{"old", "even"}, AnyTag: true}), alpha, charlie, delta, echo)
+	assertOrder(all(database.ListOptions{Tags: []string{"even", "even"}}), alpha, charlie, echo)
+	assertOrder(all(database.ListOptions{FolderID: &folder.ID}), bravo)
+
+	stored, err := s.Get(alice, charlie.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertOrder(all(database.ListOptions{CreatedAfter: stored.CreatedAt}), alpha, bravo)
+	assertOrder(all(database.ListOptions{CreatedBefore: stored.CreatedAt}), delta, echo)
+	assertOrder(all(database.ListOptions{CreatedAfter: stored.CreatedAt.Add(-time.Hour), CreatedBefore: stored.CreatedAt.Add(time.Millisecond)}),
+		charlie, delta, echo)
+
+	// Snippets sharing a sort key are neither skipped nor repeated
+	twin := newSnippet(alice, "alpha", "go")
+	if err := s.Create(twin); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got := all(database.ListOptions{Limit: 1, Sort: database.SortTitle, Ascending: true})
+	if len(got) != 6 || got[0].Title != "alpha" || got[1].Title != "alpha" || got[0].ID == got[1].ID {
+		t.Errorf("paging over equal titles returned %d snippets starting with %v", len(got), got)
+	}
+
+	if _, err := s.List(alice, database.ListOptions{Cursor: "not a cursor"}); !errors.Is(err, database.ErrInvalidCursor) {
+		t.Errorf("List with a malformed cursor error = %v, want ErrInvalidCursor", err)
+	}
+	page, err = s.List(alice, database.ListOptions{Limit: 1, Sort: database.SortTitle})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	_, err = s.List(alice, database.ListOptions{Limit: 1, Cursor: page.NextCursor})
+	if !errors.Is(err, database.ErrInvalidCursor) {
+		t.Errorf("List with a cursor of another sort order error = %v, want ErrInvalidCursor", err)
+	}
+
+	page, err = s.List(bob, database.ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Snippets) != 1 || page.Snippets[0].Title != "foxtrot" {
+		t.Errorf("List for bob = %v, want only his snippet", page.Snippets)
+	}
+}
+
+func testRevisions(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	folder := models.Folder{ID: uuid.New(), Name: "go", UserID: alice}
+	if err := s.CreateFolder(folder); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+
+	snip := newSnippet(alice, "hello", "go", "fmt")
+	snip.Code = "v1"
+	if err := s.Create(snip); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	snip.Code = "v2"
+	snip.Tags = []string{"go", "stdout"}
+	if err := s.Update(snip); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	snip.Title = "hello, world"
+	snip.Code = "v3"
+	snip.FolderID = &folder.ID
+	if err := s.Update(snip); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	revisions, err := s.ListRevisions(alice, snip.ID)
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	var codes []string
+	for i, rev := range revisions {
+		codes = append(codes, rev.Code)
+		if rev.Number != len(revisions)-i || rev.SnippetID != snip.ID {
+			t.Errorf("revision %d = %+v, want number %d of %v", i, rev, len(revisions)-i, snip.ID)
+		}
+	}
+	if !slices.Equal(codes, []string{"v3", "v2", "v1"}) {
+		t.Errorf("revision codes = %v, want newest first [v3 v2 v1]", codes)
+	}
+
+	first, err := s.GetRevision(alice, snip.ID, 1)
+	if err != nil {
+		t.Fatalf("GetRevision: %v", err)
+	}
+	if first.Code != "v1" || first.Title != "hello" || first.CreatedAt.IsZero() {
+		t.Errorf("GetRevision(1) = %+v, want the created state", first)
+	}
+	assertTags(t, first.Tags, "go", "fmt")
+	for _, number := range []int{0, 4} {
+		if _, err := s.GetRevision(alice, snip.ID, number); !errors.Is(err, database.ErrRevisionNotFound) {
+			t.Errorf("GetRevision(%d) error = %v, want ErrRevisionNotFound", number, err)
+		}
+	}
+
+	restored, err := s.RestoreRevision(alice, snip.ID, 1)
+	if err != nil {
+		t.Fatalf("RestoreRevision: %v", err)
+	}
+	if restored.Code != "v1" || restored.Title != "hello" {
+		t.Errorf("RestoreRevision = %+v, want the content of revision 1", restored)
+	}
+	if restored.FolderID == nil || *restored.FolderID != folder.ID {
+		t.Errorf("RestoreRevision moved the snippet to folder %v", restored.FolderID)
+	}
+	assertTags(t, restored.Tags, "go", "fmt")
+	revisions, err = s.ListRevisions(alice, snip.ID)
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if len(revisions) != 4 || revisions[0].Number != 4 || revisions[0].Code != "v1" || revisions[1].Code != "v3" {
+		t.Errorf("history after restore = %+v, want v1 recorded as revision 4", revisions)
+	}
+	if _, err := s.RestoreRevision(alice, snip.ID, 9); !errors.Is(err, database.ErrRevisionNotFound) {
+		t.Errorf("RestoreRevision(9) error = %v, want ErrRevisionNotFound", err)
+	}
+
+	if _, err := s.ListRevisions(bob, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("ListRevisions by other user error = %v, want ErrSnippetNotFound", err)
+	}
+	if _, err := s.GetRevision(bob, snip.ID, 1); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("GetRevision by other user error = %v, want ErrSnippetNotFound", err)
+	}
+	if _, err := s.RestoreRevision(bob, snip.ID, 1); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("RestoreRevision by other user error = %v, want ErrSnippetNotFound", err)
+	}
+
+	if err := s.Delete(alice, snip.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.ListRevisions(alice, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("ListRevisions after Delete error = %v, want ErrSnippetNotFound", err)
+	}
+}
+
+func testTagRevisions(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	snip := newSnippet(alice, "hello", "go")
+	other := newSnippet(alice, "other", "python")
+	for _, sn := range []models.Snippet{snip, other} {
+		if err := s.Create(sn); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	created, err := s.Get(alice, snip.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// assertHistory checks the number of revisions of the snippet and the
+	// tags of the newest one
+	assertHistory := func(step string, id uuid.UUID, count int, tags ...string) {
+		t.Helper()
+		revisions, err := s.ListRevisions(alice, id)
+		if err != nil {
+			t.Fatalf("%s: ListRevisions: %v", step, err)
+		}
+		if len(revisions) != count {
+			t.Fatalf("%s: %d revisions, want %d", step, len(revisions), count)
+		}
+		assertTags(t, revisions[0].Tags, tags...)
+	}
+
+	steps := []struct {
+		name   string
+		change func() error
+		count  int
+		tags   []string
+	}{
+		{"add carried tag", func() error { return s.AddTag(alice, snip.ID, "go") }, 1, []string{"go"}},
+		{"add tag", func() error { return s.AddTag(alice, snip.ID, "web") }, 2, []string{"go", "web"}},
+		{"remove missing tag", func() error { return s.RemoveTag(alice, snip.ID, "cli") }, 2, []string{"go", "web"}},
+		{"remove tag", func() error { return s.RemoveTag(alice, snip.ID, "web") }, 3, []string{"go"}},
+		{"rename tag", func() error { return s.RenameTag(alice, "go", "golang") }, 4, []string{"golang"}},
+		{"merge tags", func() error { return s.MergeTags(alice, "golang", "python") }, 5, []string{"python"}},
+		{"delete tag", func() error { return s.DeleteTag(alice, "python") }, 6, []string{}},
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		assertHistory(step.name, snip.ID, step.count, step.tags...)
+	}
+
+	// The other snippet only lost the deleted tag
+	assertHistory("other snippet", other.ID, 2)
+	got, err := s.Get(alice, snip.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !got.UpdatedAt.After(created.UpdatedAt) {
+		t.Errorf("updated_at = %v, want later than %v after tag changes", got.UpdatedAt, created.UpdatedAt)
+	}
+
+	restored, err := s.RestoreRevision(alice, snip.ID, 2)
+	if err != nil {
+		t.Fatalf("RestoreRevision: %v", err)
+	}
+	assertTags(t, restored.Tags, "go", "web")
+}
+
+func testSearch(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	folder := models.Folder{ID: uuid.New(), Name: "web", UserID: alice}
+	if err := s.CreateFolder(folder); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+
+	server := newSnippet(alice, "HTTP server", "go", "web")
+	server.Description = "minimal server"
+	server.Code = "log.Fatal(ListenAndServe(addr, nil))"
+	server.FolderID = &folder.ID
+	decode := newSnippet(alice, "Parse JSON", "go", "json")
+	decode.Description = "decode an http response body"
+	decode.Code = "err := Unmarshal(body, &v)"
+	script := newSnippet(alice, "Shell loop", "bash")
+	script.Code = "for f in *; do echo $f; done"
+	foreign := newSnippet(bob, "HTTP client", "go")
+	for _, snip := range []models.Snippet{server, decode, script, foreign} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	search := func(opts database.SearchOptions) []models.SearchResult {
+		t.Helper()
+		results, err := s.Search(alice, opts)
+		if err != nil {
+			t.Fatalf("Search(%+v): %v", opts, err)
+		}
+		return results
+	}
+	ids := func(results []models.SearchResult) []models.Snippet {
+		var snippets []models.Snippet
+		for _, res := range results {
+			snippets = append(snippets, res.Snippet)
+		}
+		return snippets
+	}
+
+	results := search(database.SearchOptions{Query: "http"})
+	assertSnippetIDs(t, ids(results), server.ID, decode.ID)
+	if len(results) == 2 {
+		if results[0].Snippet.ID != server.ID {
+			t.Errorf("title match ranked below description match")
+		}
+		if results[0].Rank <= 0 || results[0].Rank < results[1].Rank {
+			t.Errorf("ranks = %v, %v, want positive and decreasing", results[0].Rank, results[1].Rank)
+		}
+		if !strings.Contains(results[0].Highlights.Title, "<mark>") {
+			t.Errorf("title highlight = %q, want a marked match", results[0].Highlights.Title)
+		}
+		if results[0].Highlights.Code != "" {
+			t.Errorf("code highlight = %q, want empty for a field without matches", results[0].Highlights.Code)
+		}
+		if !strings.Contains(results[1].Highlights.Description, "<mark>") {
+			t.Errorf("description highlight = %q, want a marked match", results[1].Highlights.Description)
+		}
+		assertTags(t, results[0].Snippet.Tags, "go", "web")
+	}
+
+	results = search(database.SearchOptions{Query: "unmarshal"})
+	assertSnippetIDs(t, ids(results), decode.ID)
+	if len(results) == 1 && !strings.Contains(results[0].Highlights.Code, "<mark>") {
+		t.Errorf("code highlight = %q, want a marked match", results[0].Highlights.Code)
+	}
+
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "http -json"})), server.ID)
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: `"parse json"`})), decode.ID)
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "listenandserve or echo"})), server.ID, script.ID)
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "http", Tags: []string{"json"}})), decode.ID)
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "http", FolderID: &folder.ID})), server.ID)
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "http", Language: "bash"})))
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "client"})))
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "   "})))
+	if n := len(search(database.SearchOptions{Query: "http", Limit: 1})); n != 1 {
+		t.Errorf("Search with limit 1 returned %d results", n)
+	}
+
+	decode.Title = "Decode payload"
+	decode.Description = ""
+	if err := s.Update(decode); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "http"})), server.ID)
+	if err := s.Delete(alice, server.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	assertSnippetIDs(t, ids(search(database.SearchOptions{Query: "http"})))
+}
+
+func testSearchHighlightsEscaped(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	snip := newSnippet(alice, "Render <b>widget</b>", "html")
+	snip.Description = `a <mark>widget</mark> & "friends"`
+	snip.Code = "<script>alert(1)</script>\n<b>widget</b>"
+	if err := s.Create(snip); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	results, err := s.Search(alice, database.SearchOptions{Query: "widget"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Search returned %d results, want 1", len(results))
+	}
+	h := results[0].Highlights
+	for field, text := range map[string]string{"title": h.Title, "description": h.Description, "code": h.Code} {
+		if !strings.Contains(text, "<mark>widget</mark>") {
+			t.Errorf("%s highlight = %q, want widget marked", field, text)
+		}
+		if !strings.Contains(text, "&lt;") {
+			t.Errorf("%s highlight = %q, want the tags of the text escaped", field, text)
+		}
+		if tags := strings.NewReplacer("<mark>", "", "</mark>", "").Replace(text); strings.ContainsAny(tags, "<>") {
+			t.Errorf("%s highlight = %q, want no tags other than the markers", field, text)
+		}
+	}
+}
+
+func testTags(t *testing.T, s database.Store) {
+	user := createUser(t, s, "alice")
+
+	first := newSnippet(user, "first", "go")
+	second := newSnippet(user, "second", "go")
+	for _, snip := range []models.Snippet{first, second} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	if err := s.AddTag(user, first.ID, "http"); err != nil {
+		t.Fatalf("AddTag: %v", err)
+	}
+	if err := s.AddTag(user, first.ID, "http"); err != nil {
+		t.Fatalf("AddTag twice: %v", err)
+	}
+	if err := s.AddTag(user, second.ID, "http"); err != nil {
+		t.Fatalf("AddTag on second snippet: %v", err)
+	}
+
+	got, err := s.Get(user, first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertTags(t, got.Tags, "go", "http")
+
+	if err := s.RemoveTag(user, first.ID, "http"); err != nil {
+		t.Fatalf("RemoveTag: %v", err)
+	}
+	if err := s.RemoveTag(user, first.ID, "missing"); err != nil {
+		t.Fatalf("RemoveTag of unknown tag: %v", err)
+	}
+	got, err = s.Get(user, first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertTags(t, got.Tags, "go")
+
+	got, err = s.Get(user, second.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertTags(t, got.Tags, "go", "http")
+
+	if err := s.AddTag(user, uuid.New(), "http"); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("AddTag on unknown snippet error = %v, want ErrSnippetNotFound", err)
+	}
+}
+
+func testCountLanguages(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	for _, snip := range []models.Snippet{
+		newSnippet(alice, "one", "go"),
+		newSnippet(alice, "two", "go"),
+		newSnippet(alice, "three", "python"),
+		newSnippet(bob, "other", "rust"),
+	} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	counts, err := s.CountLanguages(alice)
+	if err != nil {
+		t.Fatalf("CountLanguages: %v", err)
+	}
+	if want := map[string]int{"go": 2, "python": 1}; !maps.Equal(counts, want) {
+		t.Errorf("CountLanguages = %v, want %v", counts, want)
+	}
+
+	counts, err = s.CountLanguages(createUser(t, s, "carol"))
+	if err != nil {
+		t.Fatalf("CountLanguages without snippets: %v", err)
+	}
+	if counts == nil || len(counts) != 0 {
+		t.Errorf("CountLanguages without snippets = %#v, want an empty map", counts)
+	}
+}
+
+func testTagManagement(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	first := newSnippet(alice, "first", "go", "http", "json")
+	second := newSnippet(alice, "second", "go", "http", "web")
+	other := newSnippet(bob, "other", "go", "http", "private")
+	for _, snip := range []models.Snippet{first, second, other} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	list := func(userID uuid.UUID) []string {
+		t.Helper()
+		tags, err := s.ListTags(userID)
+		if err != nil {
+			t.Fatalf("ListTags: %v", err)
+		}
+		if tags == nil {
+			t.Fatal("ListTags returned nil")
+		}
+		var out []string
+		for _, tag := range tags {
+			out = append(out, fmt.Sprintf("%s:%d", tag.Name, tag.SnippetCount))
+		}
+		return out
+	}
+	assertList := func(userID uuid.UUID, want ...string) {
+		t.Helper()
+		if got := list(userID); !slices.Equal(got, want) {
+			t.Errorf("ListTags = %v, want %v", got, want)
+		}
+	}
+
+	assertList(alice, "go:2", "http:2", "json:1", "web:1")
+	assertList(bob, "go:1", "http:1", "private:1")
+
+	// Orphans are deleted when the last snippet drops a tag
+	if err := s.RemoveTag(alice, first.ID, "json"); err != nil {
+		t.Fatalf("RemoveTag: %v", err)
+	}
+	second.Tags = []string{"http"}
+	if err := s.Update(second); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	assertList(alice, "go:1", "http:2")
+
+	if err := s.AddTag(alice, first.ID, "net"); err != nil {
+		t.Fatalf("AddTag: %v", err)
+	}
+	if err := s.RenameTag(alice, "http", "net"); !errors.Is(err, database.ErrTagExists) {
+		t.Errorf("RenameTag to existing name error = %v, want ErrTagExists", err)
+	}
+	if err := s.RenameTag(alice, "missing", "other"); !errors.Is(err, database.ErrTagNotFound) {
+		t.Errorf("RenameTag of unknown tag error = %v, want ErrTagNotFound", err)
+	}
+	if err := s.RenameTag(alice, "http", "HTTP"); err != nil {
+		t.Fatalf("RenameTag: %v", err)
+	}
+	assertList(alice, "go:1", "HTTP:2", "net:1")
+	assertList(bob, "go:1", "http:1", "private:1")
+	got, err := s.Get(alice, second.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertTags(t, got.Tags, "HTTP")
+
+	// The renamed tag is found again by name when tagging
+	if err := s.AddTag(alice, second.ID, "HTTP"); err != nil {
+		t.Fatalf("AddTag: %v", err)
+	}
+	assertList(alice, "go:1", "HTTP:2", "net:1")
+
+	if err := s.MergeTags(alice, "HTTP", "private"); !errors.Is(err, database.ErrTagNotFound) {
+		t.Errorf("MergeTags into another user's tag error = %v, want ErrTagNotFound", err)
+	}
+	if err := s.MergeTags(alice, "HTTP", "net"); err != nil {
+		t.Fatalf("MergeTags: %v", err)
+	}
+	assertList(alice, "go:1", "net:2")
+	got, err = s.Get(alice, first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertTags(t, got.Tags, "go", "net")
+	got, err = s.Get(alice, second.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertTags(t, got.Tags, "net")
+
+	if err := s.DeleteTag(alice, "private"); !errors.Is(err, database.ErrTagNotFound) {
+		t.Errorf("DeleteTag of another user's tag error = %v, want ErrTagNotFound", err)
+	}
+	if err := s.DeleteTag(alice, "net"); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	assertList(alice, "go:1")
+	got, err = s.Get(alice, first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertTags(t, got.Tags, "go")
+
+	if err := s.Delete(bob, other.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	assertList(bob)
+}
+
+func testFolders(t *testing.T, s database.Store) {
+	user := createUser(t, s, "alice")
+
+	root := models.Folder{ID: uuid.New(), Name: "root", UserID: user}
+	if err := s.CreateFolder(root); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	child := models.Folder{ID: uuid.New(), Name: "child", UserID: user, ParentID: &root.ID}
+	if err := s.CreateFolder(child); err != nil {
+		t.Fatalf("CreateFolder child: %v", err)
+	}
+	grandchild := models.Folder{ID: uuid.New(), Name: "grandchild", UserID: user, ParentID: &child.ID}
+	if err := s.CreateFolder(grandchild); err != nil {
+		t.Fatalf("CreateFolder grandchild: %v", err)
+	}
+
+	inRoot := newSnippet(user, "in root", "go")
+	inRoot.FolderID = &root.ID
+	inChild := newSnippet(user, "in child", "go")
+	inChild.FolderID = &child.ID
+	for _, snip := range []models.Snippet{inRoot, inChild, newSnippet(user, "loose", "go")} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	folders, err := s.GetFoldersByUser(user)
+	if err != nil {
+		t.Fatalf("GetFoldersByUser: %v", err)
+	}
+	if len(folders) != 3 {
+		t.Errorf("GetFoldersByUser returned %d folders, want 3", len(folders))
+	}
+
+	snippets, subfolders, err := s.GetFolderContents(user, root.ID)
+	if err != nil {
+		t.Fatalf("GetFolderContents: %v", err)
+	}
+	assertSnippetIDs(t, snippets, inRoot.ID)
+	if len(subfolders) != 1 || subfolders[0].ID != child.ID {
+		t.Errorf("GetFolderContents subfolders = %+v, want only %v", subfolders, child.ID)
+	}
+	if subfolders[0].ParentID == nil || *subfolders[0].ParentID != root.ID {
+		t.Errorf("subfolder parent = %v, want %v", subfolders[0].ParentID, root.ID)
+	}
+
+	snippets, subfolders, err = s.GetFolderContents(user, grandchild.ID)
+	if err != nil {
+		t.Fatalf("GetFolderContents of empty folder: %v", err)
+	}
+	if len(snippets) != 0 || len(subfolders) != 0 {
+		t.Errorf("empty folder returned %d snippets and %d folders", len(snippets), len(subfolders))
+	}
+
+	if _, _, err := s.GetFolderContents(user, uuid.New()); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("GetFolderContents(unknown) error = %v, want ErrFolderNotFound", err)
+	}
+}
+
+func testFolderOwnership(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	folder := models.Folder{ID: uuid.New(), Name: "private", UserID: alice}
+	if err := s.CreateFolder(folder); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+
+	intruder := models.Folder{ID: uuid.New(), Name: "intruder", UserID: bob, ParentID: &folder.ID}
+	if err := s.CreateFolder(intruder); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("CreateFolder under other user's folder error = %v, want ErrFolderNotFound", err)
+	}
+
+	if _, _, err := s.GetFolderContents(bob, folder.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("GetFolderContents by other user error = %v, want ErrFolderNotFound", err)
+	}
+
+	folders, err := s.GetFoldersByUser(bob)
+	if err != nil {
+		t.Fatalf("GetFoldersByUser: %v", err)
+	}
+	if len(folders) != 0 {
+		t.Errorf("GetFoldersByUser for other user returned %d folders, want 0", len(folders))
+	}
+
+	snip := newSnippet(bob, "sneaky", "go")
+	snip.FolderID = &folder.ID
+	if err := s.Create(snip); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("Create in other user's folder error = %v, want ErrFolderNotFound", err)
+	}
+
+	snip.FolderID = nil
+	if err := s.Create(snip); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	snip.FolderID = &folder.ID
+	if err := s.Update(snip); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("Update into other user's folder error = %v, want ErrFolderNotFound", err)
+	}
+}
+
+func testFolderUpdate(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	root := createFolder(t, s, alice, "root", nil)
+	child := createFolder(t, s, alice, "child", &root.ID)
+	grandchild := createFolder(t, s, alice, "grandchild", &child.ID)
+	other := createFolder(t, s, alice, "other", nil)
+	bobs := createFolder(t, s, bob, "bobs", nil)
+
+	child.Name = "renamed"
+	child.ParentID = &other.ID
+	if err := s.UpdateFolder(child); err != nil {
+		t.Fatalf("UpdateFolder: %v", err)
+	}
+	got, err := s.GetFolder(alice, child.ID)
+	if err != nil {
+		t.Fatalf("GetFolder: %v", err)
+	}
+	if got.Name != "renamed" || got.ParentID == nil || *got.ParentID != other.ID {
+		t.Errorf("GetFolder = %q under %v, want %q under %v", got.Name, got.ParentID, "renamed", other.ID)
+	}
+
+	child.ParentID = nil
+	if err := s.UpdateFolder(child); err != nil {
+		t.Fatalf("UpdateFolder to root: %v", err)
+	}
+	if got, _ := s.GetFolder(alice, child.ID); got.ParentID != nil {
+		t.Errorf("parent after move to root = %v, want nil", got.ParentID)
+	}
+
+	for _, parent := range []uuid.UUID{child.ID, grandchild.ID} {
+		child.ParentID = &parent
+		if err := s.UpdateFolder(child); !errors.Is(err, database.ErrFolderCycle) {
+			t.Errorf("UpdateFolder below itself error = %v, want ErrFolderCycle", err)
+		}
+	}
+
+	child.ParentID = &bobs.ID
+	if err := s.UpdateFolder(child); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("UpdateFolder into other user's folder error = %v, want ErrFolderNotFound", err)
+	}
+	bobs.ParentID = &root.ID
+	bobs.UserID = alice
+	if err := s.UpdateFolder(bobs); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("UpdateFolder of other user's folder error = %v, want ErrFolderNotFound", err)
+	}
+	if _, err := s.GetFolder(alice, bobs.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("GetFolder of other user's folder error = %v, want ErrFolderNotFound", err)
+	}
+}
+
+func testFolderDelete(t *testing.T, s database.Store) {
+	user := createUser(t, s, "alice")
+
+	parent := createFolder(t, s, user, "parent", nil)
+	doomed := createFolder(t, s, user, "doomed", &parent.ID)
+	below := createFolder(t, s, user, "below", &doomed.ID)
+	inDoomed := newSnippet(user, "in doomed", "go")
+	inDoomed.FolderID = &doomed.ID
+	inBelow := newSnippet(user, "in below", "go")
+	inBelow.FolderID = &below.ID
+	for _, snip := range []models.Snippet{inDoomed, inBelow} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	if err := s.DeleteFolder(user, doomed.ID, database.MoveContentsToParent); err != nil {
+		t.Fatalf("DeleteFolder(move): %v", err)
+	}
+	if _, err := s.GetFolder(user, doomed.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("GetFolder after delete error = %v, want ErrFolderNotFound", err)
+	}
+	snippets, folders, err := s.GetFolderContents(user, parent.ID)
+	if err != nil {
+		t.Fatalf("GetFolderContents: %v", err)
+	}
+	assertSnippetIDs(t, snippets, inDoomed.ID)
+	if len(folders) != 1 || folders[0].ID != below.ID {
+		t.Errorf("subfolders after move = %+v, want only %v", folders, below.ID)
+	}
+
+	if err := s.DeleteFolder(user, parent.ID, database.DeleteContents); err != nil {
+		t.Fatalf("DeleteFolder(delete): %v", err)
+	}
+	for _, id := range []uuid.UUID{inDoomed.ID, inBelow.ID} {
+		if _, err := s.Get(user, id); !errors.Is(err, database.ErrSnippetNotFound) {
+			t.Errorf("Get of snippet in deleted folder error = %v, want ErrSnippetNotFound", err)
+		}
+	}
+	if _, err := s.GetFolder(user, below.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("GetFolder of deleted subfolder error = %v, want ErrFolderNotFound", err)
+	}
+
+	if err := s.DeleteFolder(user, uuid.New(), database.DeleteContents); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("DeleteFolder(unknown) error = %v, want ErrFolderNotFound", err)
+	}
+	other := createUser(t, s, "bob")
+	kept := createFolder(t, s, user, "kept", nil)
+	if err := s.DeleteFolder(other, kept.ID, database.DeleteContents); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("DeleteFolder by other user error = %v, want ErrFolderNotFound", err)
+	}
+}
+
+func testFolderTree(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	work := createFolder(t, s, alice, "work", nil)
+	home := createFolder(t, s, alice, "Home", nil)
+	api := createFolder(t, s, alice, "api", &work.ID)
+	createFolder(t, s, alice, "cli", &work.ID)
+	v2 := createFolder(t, s, alice, "v2", &api.ID)
+	createFolder(t, s, bob, "bobs", nil)
+
+	for i, folderID := range []*uuid.UUID{&api.ID, &api.ID, &v2.ID, &home.ID, nil} {
+		snip := newSnippet(alice, "snippet "+string(rune('a'+i)), "go")
+		snip.FolderID = folderID
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	tree, err := s.GetFolderTree(alice, database.FolderTreeOptions{})
+	if err != nil {
+		t.Fatalf("GetFolderTree: %v", err)
+	}
+	var names []string
+	var walk func(nodes []models.FolderNode)
+	walk = func(nodes []models.FolderNode) {
+		for _, node := range nodes {
+			names = append(names, fmt.Sprintf("%s@%d:%d", node.Name, node.Depth, node.SnippetCount))
+			if node.Snippets != nil {
+				t.Errorf("folder %s has snippets without WithSnippets", node.Name)
+			}
+			walk(node.Children)
+		}
+	}
+	walk(tree)
+	want := []string{"Home@0:1", "work@0:0", "api@1:2", "v2@2:1", "cli@1:0"}
+	if !slices.Equal(names, want) {
+		t.Errorf("GetFolderTree = %v, want %v", names, want)
+	}
+
+	tree, err = s.GetFolderTree(alice, database.FolderTreeOptions{MaxDepth: 2, WithSnippets: true})
+	if err != nil {
+		t.Fatalf("GetFolderTree with options: %v", err)
+	}
+	names = nil
+	walk = func(nodes []models.FolderNode) {
+		for _, node := range nodes {
+			names = append(names, node.Name)
+			if len(node.Snippets) != node.SnippetCount {
+				t.Errorf("folder %s has %d snippets, want %d", node.Name, len(node.Snippets), node.SnippetCount)
+			}
+			walk(node.Children)
+		}
+	}
+	walk(tree)
+	if want := []string{"Home", "work", "api", "cli"}; !slices.Equal(names, want) {
+		t.Errorf("GetFolderTree(MaxDepth: 2) = %v, want %v", names, want)
+	}
+
+	path, err := s.GetFolderPath(alice, v2.ID)
+	if err != nil {
+		t.Fatalf("GetFolderPath: %v", err)
+	}
+	names = nil
+	for _, folder := range path {
+		names = append(names, folder.Name)
+	}
+	if want := []string{"work", "api", "v2"}; !slices.Equal(names, want) {
+		t.Errorf("GetFolderPath = %v, want %v", names, want)
+	}
+	if _, err := s.GetFolderPath(bob, v2.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("GetFolderPath by other user error = %v, want ErrFolderNotFound", err)
+	}
+
+	tree, err = s.GetFolderTree(bob, database.FolderTreeOptions{})
+	if err != nil {
+		t.Fatalf("GetFolderTree: %v", err)
+	}
+	if len(tree) != 1 || tree[0].Name != "bobs" {
+		t.Errorf("GetFolderTree for other user = %+v, want only bobs", tree)
+	}
+}
+
+func testImport(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	work := createFolder(t, s, alice, "work", nil)
+	api := createFolder(t, s, alice, "api", &work.ID)
+	nested := newSnippet(alice, "nested", "go", "http")
+	nested.FolderID = &api.ID
+	top := newSnippet(alice, "top", "python")
+	for _, snip := range []models.Snippet{nested, top} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	archive, err := database.Export(s, alice)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(archive.Folders) != 2 || len(archive.Snippets) != 2 {
+		t.Fatalf("Export has %d folders and %d snippets, want 2 and 2", len(archive.Folders), len(archive.Snippets))
+	}
+
+	counts := func(result models.ImportResult) string {
+		f, sn := result.Folders, result.Snippets
+		return fmt.Sprintf("folders %d/%d/%d snippets %d/%d/%d",
+			f.Created, f.Updated, f.Skipped, sn.Created, sn.Updated, sn.Skipped)
+	}
+	importAs := func(userID uuid.UUID, archive models.Archive, opts database.ImportOptions, want string) models.ImportResult {
+		t.Helper()
+		result, err := s.Import(userID, archive, opts)
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if got := counts(result); got != want {
+			t.Errorf("Import created/updated/skipped %s, want %s", got, want)
+		}
+		return result
+	}
+
+	// The IDs belong to alice, so bob gets copies with new IDs and the
+	// hierarchy is rebuilt from the remapped IDs
+	result := importAs(bob, archive, database.ImportOptions{}, "folders 2/0/0 snippets 2/0/0")
+	if len(result.IDs) != 4 {
+		t.Errorf("Import remapped %d IDs, want 4", len(result.IDs))
+	}
+	got, err := s.Get(bob, result.IDs[nested.ID])
+	if err != nil {
+		t.Fatalf("Get imported snippet: %v", err)
+	}
+	assertTags(t, got.Tags, "go", "http")
+	if got.FolderID == nil || *got.FolderID != result.IDs[api.ID] {
+		t.Errorf("imported snippet folder = %v, want %v", got.FolderID, result.IDs[api.ID])
+	}
+	for _, archived := range archive.Snippets {
+		if archived.ID == nested.ID && got.CreatedAt.Sub(archived.CreatedAt).Abs() > time.Millisecond {
+			t.Errorf("imported snippet created at %v, want %v", got.CreatedAt, archived.CreatedAt)
+		}
+	}
+	path, err := s.GetFolderPath(bob, result.IDs[api.ID])
+	if err != nil {
+		t.Fatalf("GetFolderPath: %v", err)
+	}
+	if len(path) != 2 || path[0].ID != result.IDs[work.ID] {
+		t.Errorf("imported folder path = %v, want work/api", path)
+	}
+
+	// Re-importing into the same account conflicts with every object
+	changed := top
+	changed.Title = "changed"
+	changed.Tags = []string{"local"}
+	if err := s.Update(changed); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	importAs(alice, archive, database.ImportOptions{OnConflict: database.ImportSkip}, "folders 0/0/2 snippets 0/0/2")
+	if got, _ := s.Get(alice, top.ID); got.Title != "changed" {
+		t.Errorf("skipped snippet title = %q, want it kept", got.Title)
+	}
+
+	importAs(alice, archive, database.ImportOptions{OnConflict: database.ImportOverwrite}, "folders 0/2/0 snippets 0/2/0")
+	got, err = s.Get(alice, top.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Title != "top" {
+		t.Errorf("overwritten snippet title = %q, want top", got.Title)
+	}
+	assertTags(t, got.Tags, "python")
+	tags, err := s.ListTags(alice)
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	for _, tag := range tags {
+		if tag.Name == "local" {
+			t.Error("tag dropped by the import was not deleted")
+		}
+	}
+
+	importAs(alice, archive, database.ImportOptions{OnConflict: database.ImportDuplicate}, "folders 2/0/0 snippets 2/0/0")
+	importAs(alice, archive, database.ImportOptions{NewIDs: true}, "folders 2/0/0 snippets 2/0/0")
+	all, err := s.GetAll(alice)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != 6 {
+		t.Errorf("alice has %d snippets after duplicating twice, want 6", len(all))
+	}
+
+	// Imports are all or nothing
+	broken := archive
+	broken.Snippets = append(append([]models.ArchiveSnippet{}, archive.Snippets...), models.ArchiveSnippet{
+		Title: "orphan", Language: "go", Code: "x", FolderID: ptr(uuid.New()),
+	})
+	before, err := s.GetAll(bob)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if _, err := s.Import(bob, broken, database.ImportOptions{NewIDs: true}); !errors.Is(err, database.ErrInvalidArchive) {
+		t.Errorf("Import of snippet in unknown folder error = %v, want ErrInvalidArchive", err)
+	}
+	cyclic := models.Archive{Version: models.ArchiveVersion, Folders: []models.ArchiveFolder{
+		{ID: work.ID, Name: "work", ParentID: &api.ID},
+		{ID: api.ID, Name: "api", ParentID: &work.ID},
+	}}
+	if _, err := s.Import(bob, cyclic, database.ImportOptions{}); !errors.Is(err, database.ErrInvalidArchive) {
+		t.Errorf("Import of cyclic folders error = %v, want ErrInvalidArchive", err)
+	}
+	if _, err := s.Import(bob, models.Archive{Version: 99}, database.ImportOptions{}); !errors.Is(err, database.ErrInvalidArchive) {
+		t.Errorf("Import of unknown version error = %v, want ErrInvalidArchive", err)
+	}
+	after, err := s.GetAll(bob)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("failed imports changed bob's snippets from %d to %d", len(before), len(after))
+	}
+}
+
+func testShareLinks(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	snip := newSnippet(alice, "shared", "go")
+	if err := s.Create(snip); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	folder := createFolder(t, s, alice, "shared folder", nil)
+
+	create := func(link models.ShareLink) models.ShareLink {
+		t.Helper()
+		link.ID = uuid.New()
+		link.Token = link.ID.String()
+		if link.UserID == uuid.Nil {
+			link.UserID = alice
+		}
+		if err := s.CreateShareLink(&link); err != nil {
+			t.Fatalf("CreateShareLink: %v", err)
+		}
+		if link.CreatedAt.IsZero() {
+			t.Error("CreateShareLink did not set the creation time")
+		}
+		return link
+	}
+
+	plain := create(models.ShareLink{SnippetID: &snip.ID})
+	got, err := s.GetShareLink(plain.Token)
+	if err != nil {
+		t.Fatalf("GetShareLink: %v", err)
+	}
+	if got.ID != plain.ID || got.UserID != alice || got.SnippetID == nil || *got.SnippetID != snip.ID || got.HasPassword {
+		t.Errorf("GetShareLink = %+v, want the snippet link without a password", got)
+	}
+	if _, err := s.GetShareLink("wrong"); !errors.Is(err, database.ErrShareLinkNotFound) {
+		t.Errorf("GetShareLink with an unknown token: err = %v, want ErrShareLinkNotFound", err)
+	}
+
+	protected := create(models.ShareLink{FolderID: &folder.ID, Password: "open sesame"})
+	if protected.Password != "" || !protected.HasPassword {
+		t.Errorf("CreateShareLink left password %q, has_password %v", protected.Password, protected.HasPassword)
+	}
+	got, err = s.GetShareLink(protected.Token)
+	if err != nil {
+		t.Fatalf("GetShareLink of protected link: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(got.Password), []byte("open sesame")) != nil {
+		t.Error("GetShareLink did not return the hash of the password")
+	}
+
+	limited := create(models.ShareLink{SnippetID: &snip.ID, MaxViews: ptr(2)})
+	for i := 0; i < 2; i++ {
+		if err := s.RecordShareView(limited.ID); err != nil {
+			t.Fatalf("RecordShareView %d: %v", i+1, err)
+		}
+	}
+	if err := s.RecordShareView(limited.ID); !errors.Is(err, database.ErrShareLinkNotFound) {
+		t.Errorf("RecordShareView past the limit: err = %v, want ErrShareLinkNotFound", err)
+	}
+	if _, err := s.GetShareLink(limited.Token); !errors.Is(err, database.ErrShareLinkNotFound) {
+		t.Errorf("GetShareLink of used up link: err = %v, want ErrShareLinkNotFound", err)
+	}
+
+	expired := create(models.ShareLink{SnippetID: &snip.ID, ExpiresAt: ptr(time.Now().Add(-time.Minute))})
+	if _, err := s.GetShareLink(expired.Token); !errors.Is(err, database.ErrShareLinkNotFound) {
+		t.Errorf("GetShareLink of expired link: err = %v, want ErrShareLinkNotFound", err)
+	}
+	future := create(models.ShareLink{SnippetID: &snip.ID, ExpiresAt: ptr(time.Now().Add(time.Hour))})
+
+	other := models.ShareLink{ID: uuid.New(), Token: "other", UserID: bob, SnippetID: &snip.ID}
+	if err := s.CreateShareLink(&other); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("CreateShareLink for another user's snippet: err = %v, want ErrSnippetNotFound", err)
+	}
+	other.SnippetID, other.FolderID = nil, &folder.ID
+	if err := s.CreateShareLink(&other); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("CreateShareLink for another user's folder: err = %v, want ErrFolderNotFound", err)
+	}
+
+	links, err := s.ListShareLinks(alice)
+	if err != nil {
+		t.Fatalf("ListShareLinks: %v", err)
+	}
+	var ids []uuid.UUID
+	for _, link := range links {
+		ids = append(ids, link.ID)
+		if link.Password != "" {
+			t.Errorf("ListShareLinks returned the password hash of %s", link.ID)
+		}
+	}
+	if want := []uuid.UUID{future.ID, protected.ID, plain.ID}; !slices.Equal(ids, want) {
+		t.Errorf("ListShareLinks = %v, want the active links newest first %v", ids, want)
+	}
+	if links, err := s.ListShareLinks(bob); err != nil || links == nil || len(links) != 0 {
+		t.Errorf("ListShareLinks of another user = %v, %v; want an empty list", links, err)
+	}
+
+	if err := s.DeleteShareLink(bob, plain.ID); !errors.Is(err, database.ErrShareLinkNotFound) {
+		t.Errorf("DeleteShareLink by another user: err = %v, want ErrShareLinkNotFound", err)
+	}
+	if err := s.DeleteShareLink(alice, plain.ID); err != nil {
+		t.Fatalf("DeleteShareLink: %v", err)
+	}
+	if _, err := s.GetShareLink(plain.Token); !errors.Is(err, database.ErrShareLinkNotFound) {
+		t.Errorf("GetShareLink of deleted link: err = %v, want ErrShareLinkNotFound", err)
+	}
+
+	// Links go away with what they share
+	if err := s.Delete(alice, snip.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.GetShareLink(future.Token); !errors.Is(err, database.ErrShareLinkNotFound) {
+		t.Errorf("GetShareLink after deleting the snippet: err = %v, want ErrShareLinkNotFound", err)
+	}
+	if err := s.DeleteFolder(alice, folder.ID, database.DeleteContents); err != nil {
+		t.Fatalf("DeleteFolder: %v", err)
+	}
+	if links, _ := s.ListShareLinks(alice); len(links) != 0 {
+		t.Errorf("ListShareLinks after deleting the targets = %+v, want none", links)
+	}
+}
+
+func testFolderShares(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+
+	top := createFolder(t, s, alice, "top", nil)
+	mid := createFolder(t, s, alice, "mid", &top.ID)
+	leaf := createFolder(t, s, alice, "leaf", &mid.ID)
+	private := createFolder(t, s, alice, "private", nil)
+	inLeaf := newSnippet(alice, "in leaf", "go")
+	inLeaf.FolderID = &leaf.ID
+	loose := newSnippet(alice, "loose", "go")
+	for _, snip := range []models.Snippet{inLeaf, loose} {
+		if err := s.Create(snip); err != nil {
+			t.Fatalf("Create(%s): %v", snip.Title, err)
+		}
+	}
+
+	share := func(folderID, userID uuid.UUID, permission models.Permission) {
+		t.Helper()
+		grant := models.FolderShare{FolderID: folderID, UserID: userID, Permission: permission}
+		if err := s.ShareFolder(alice, &grant); err != nil {
+			t.Fatalf("ShareFolder: %v", err)
+		}
+		if grant.CreatedAt.IsZero() {
+			t.Error("ShareFolder did not set the creation time")
+		}
+	}
+	share(top.ID, bob, models.PermissionRead)
+	share(mid.ID, bob, models.PermissionWrite)
+	share(leaf.ID, carol, models.PermissionRead)
+
+	if err := s.ShareFolder(bob, &models.FolderShare{FolderID: top.ID, UserID: carol, Permission: models.PermissionRead}); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("ShareFolder by a non-owner: err = %v, want ErrFolderNotFound", err)
+	}
+
+	access := []struct {
+		userID, folderID uuid.UUID
+		want             models.Permission
+	}{
+		{alice, private.ID, models.PermissionWrite},
+		{bob, top.ID, models.PermissionRead},
+		{bob, mid.ID, models.PermissionWrite},
+		{bob, leaf.ID, models.PermissionWrite},
+		{carol, leaf.ID, models.PermissionRead},
+		{carol, mid.ID, ""},
+		{bob, private.ID, ""},
+	}
+	for _, tt := range access {
+		owner, got, err := s.FolderAccess(tt.userID, tt.folderID)
+		if tt.want == "" {
+			if !errors.Is(err, database.ErrFolderNotFound) {
+				t.Errorf("FolderAccess without a grant: err = %v, want ErrFolderNotFound", err)
+			}
+			continue
+		}
+		if err != nil || owner != alice || got != tt.want {
+			t.Errorf("FolderAccess = %v, %q, %v, want alice, %q", owner, got, err, tt.want)
+		}
+	}
+
+	if owner, got, err := s.SnippetAccess(carol, inLeaf.ID); err != nil || owner != alice || got != models.PermissionRead {
+		t.Errorf("SnippetAccess through the folder = %v, %q, %v, want alice, read", owner, got, err)
+	}
+	if _, _, err := s.SnippetAccess(bob, loose.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("SnippetAccess outside any folder: err = %v, want ErrSnippetNotFound", err)
+	}
+	if _, got, err := s.SnippetAccess(alice, loose.ID); err != nil || got != models.PermissionWrite {
+		t.Errorf("SnippetAccess by the owner = %q, %v, want write", got, err)
+	}
+
+	shares, err := s.ListFolderShares(alice, top.ID)
+	if err != nil {
+		t.Fatalf("ListFolderShares: %v", err)
+	}
+	if len(shares) != 1 || shares[0].UserID != bob || shares[0].Username != "bob" || shares[0].Permission != models.PermissionRead {
+		t.Errorf("ListFolderShares = %+v, want bob reading", shares)
+	}
+	if _, err := s.ListFolderShares(bob, top.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("ListFolderShares by a non-owner: err = %v, want ErrFolderNotFound", err)
+	}
+
+	// Sharing again replaces the permission
+	share(top.ID, bob, models.PermissionWrite)
+	incoming, err := s.ListIncomingShares(bob)
+	if err != nil {
+		t.Fatalf("ListIncomingShares: %v", err)
+	}
+	if len(incoming) != 2 || incoming[0].Folder.ID != mid.ID || incoming[1].Folder.ID != top.ID ||
+		incoming[1].Permission != models.PermissionWrite || incoming[1].Owner != "alice" {
+		t.Errorf("ListIncomingShares = %+v, want mid then top, both writable, from alice", incoming)
+	}
+
+	if err := s.UnshareFolder(bob, top.ID, bob); !errors.Is(err, database.ErrFolderShareNotFound) {
+		t.Errorf("UnshareFolder by a non-owner: err = %v, want ErrFolderShareNotFound", err)
+	}
+	if err := s.UnshareFolder(alice, leaf.ID, carol); err != nil {
+		t.Fatalf("UnshareFolder: %v", err)
+	}
+	if _, _, err := s.FolderAccess(carol, leaf.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("FolderAccess after UnshareFolder: err = %v, want ErrFolderNotFound", err)
+	}
+	if err := s.UnshareFolder(alice, leaf.ID, carol); !errors.Is(err, database.ErrFolderShareNotFound) {
+		t.Errorf("UnshareFolder twice: err = %v, want ErrFolderShareNotFound", err)
+	}
+
+	if err := s.DeleteFolder(alice, top.ID, database.DeleteContents); err != nil {
+		t.Fatalf("DeleteFolder: %v", err)
+	}
+	if incoming, err := s.ListIncomingShares(bob); err != nil || len(incoming) != 0 {
+		t.Errorf("ListIncomingShares after DeleteFolder = %+v, %v, want none", incoming, err)
+	}
+}
+
+func testWorkspaces(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+
+	team := models.Workspace{Name: "Team"}
+	if err := s.CreateWorkspace(&team, alice); err != nil {
+		t.Fatalf("CreateWorkspace: %v", err)
+	}
+	if team.ID == uuid.Nil || team.Role != models.RoleOwner || team.CreatedAt.IsZero() {
+		t.Errorf("CreateWorkspace = %+v, want an ID, the owner role and a creation time", team)
+	}
+	other := models.Workspace{Name: "another"}
+	if err := s.CreateWorkspace(&other, alice); err != nil {
+		t.Fatalf("CreateWorkspace: %v", err)
+	}
+
+	// The workspace owns its library like a user
+	snip := newSnippet(team.ID, "team snippet", "go")
+	if err := s.Create(snip); err != nil {
+		t.Fatalf("Create in workspace: %v", err)
+	}
+	folder := createFolder(t, s, team.ID, "team folder", nil)
+	if _, err := s.Get(alice, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("Get as the owner's own library: err = %v, want ErrSnippetNotFound", err)
+	}
+
+	// No one can log in to the account of a workspace
+	account, err := s.GetUserByID(team.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID of workspace: %v", err)
+	}
+	if account, err = s.GetUserByUsername(account.Username); err != nil {
+		t.Fatalf("GetUserByUsername of workspace: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.Password), []byte("")) == nil {
+		t.Error("the account of a workspace accepts an empty password")
+	}
+	if byEmail, err := s.GetUserByEmail("bob@example.com"); err != nil || byEmail.ID != bob {
+		t.Errorf("GetUserByEmail = %v, %v, want bob", byEmail, err)
+	}
+
+	list, err := s.ListWorkspaces(alice)
+	if err != nil {
+		t.Fatalf("ListWorkspaces: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != other.ID || list[1].ID != team.ID || list[1].Role != models.RoleOwner {
+		t.Errorf("ListWorkspaces = %+v, want another then Team, owned by alice", list)
+	}
+	if _, err := s.GetWorkspace(bob, team.ID); !errors.Is(err, database.ErrWorkspaceNotFound) {
+		t.Errorf("GetWorkspace by a non-member: err = %v, want ErrWorkspaceNotFound", err)
+	}
+
+	member := models.WorkspaceMember{UserID: bob, Role: models.RoleViewer}
+	if err := s.AddMember(team.ID, &member); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if member.CreatedAt.IsZero() {
+		t.Error("AddMember did not set the creation time")
+	}
+	if err := s.AddMember(team.ID, &models.WorkspaceMember{UserID: bob, Role: models.RoleEditor}); !errors.Is(err, database.ErrMemberExists) {
+		t.Errorf("AddMember twice: err = %v, want ErrMemberExists", err)
+	}
+	if err := s.AddMember(team.ID, &models.WorkspaceMember{UserID: other.ID, Role: models.RoleEditor}); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("AddMember of a workspace: err = %v, want ErrUserNotFound", err)
+	}
+	if err := s.AddMember(uuid.New(), &models.WorkspaceMember{UserID: carol, Role: models.RoleEditor}); !errors.Is(err, database.ErrWorkspaceNotFound) {
+		t.Errorf("AddMember to a missing workspace: err = %v, want ErrWorkspaceNotFound", err)
+	}
+	got, err := s.GetWorkspace(bob, team.ID)
+	if err != nil || got.Role != models.RoleViewer || got.Name != "Team" {
+		t.Errorf("GetWorkspace by bob = %+v, %v, want Team as viewer", got, err)
+	}
+
+	members, err := s.ListMembers(team.ID)
+	if err != nil {
+		t.Fatalf("ListMembers: %v", err)
+	}
+	if len(members) != 2 || members[0].Username != "alice" || members[1].Username != "bob" || members[1].Role != models.RoleViewer {
+		t.Errorf("ListMembers = %+v, want alice and bob as viewer", members)
+	}
+
+	// The last owner can neither leave nor step down
+	if err := s.SetMemberRole(team.ID, alice, models.RoleEditor); !errors.Is(err, database.ErrLastOwner) {
+		t.Errorf("SetMemberRole of the last owner: err = %v, want ErrLastOwner", err)
+	}
+	if err := s.RemoveMember(team.ID, alice); !errors.Is(err, database.ErrLastOwner) {
+		t.Errorf("RemoveMember of the last owner: err = %v, want ErrLastOwner", err)
+	}
+	if err := s.SetMemberRole(team.ID, carol, models.RoleEditor); !errors.Is(err, database.ErrMemberNotFound) {
+		t.Errorf("SetMemberRole of a non-member: err = %v, want ErrMemberNotFound", err)
+	}
+	if err := s.SetMemberRole(team.ID, bob, models.RoleOwner); err != nil {
+		t.Fatalf("SetMemberRole: %v", err)
+	}
+	if err := s.RemoveMember(team.ID, alice); err != nil {
+		t.Fatalf("RemoveMember of one of two owners: %v", err)
+	}
+	if err := s.RemoveMember(team.ID, alice); !errors.Is(err, database.ErrMemberNotFound) {
+		t.Errorf("RemoveMember twice: err = %v, want ErrMemberNotFound", err)
+	}
+	if _, err := s.GetWorkspace(alice, team.ID); !errors.Is(err, database.ErrWorkspaceNotFound) {
+		t.Errorf("GetWorkspace after leaving: err = %v, want ErrWorkspaceNotFound", err)
+	}
+
+	if err := s.DeleteWorkspace(team.ID); err != nil {
+		t.Fatalf("DeleteWorkspace: %v", err)
+	}
+	if _, err := s.Get(team.ID, snip.ID); !errors.Is(err, database.ErrSnippetNotFound) {
+		t.Errorf("Get after DeleteWorkspace: err = %v, want ErrSnippetNotFound", err)
+	}
+	if _, err := s.GetFolder(team.ID, folder.ID); !errors.Is(err, database.ErrFolderNotFound) {
+		t.Errorf("GetFolder after DeleteWorkspace: err = %v, want ErrFolderNotFound", err)
+	}
+	if list, err := s.ListWorkspaces(bob); err != nil || len(list) != 0 {
+		t.Errorf("ListWorkspaces after DeleteWorkspace = %+v, %v, want none", list, err)
+	}
+	if err := s.DeleteWorkspace(team.ID); !errors.Is(err, database.ErrWorkspaceNotFound) {
+		t.Errorf("DeleteWorkspace twice: err = %v, want ErrWorkspaceNotFound", err)
+	}
+	if err := s.DeleteWorkspace(alice); !errors.Is(err, database.ErrWorkspaceNotFound) {
+		t.Errorf("DeleteWorkspace of a user: err = %v, want ErrWorkspaceNotFound", err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testSessions(t *testing.T, s database.Store) {
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	now := time.Now()
+	create := func(userID uuid.UUID, refreshToken string, expiresAt time.Time) models.Session {
+		t.Helper()
+		session := models.Session{
+			ID:             uuid.New(),
+			UserID:         userID,
+			RefreshToken:   refreshToken,
+			TokenID:        uuid.New(),
+			TokenExpiresAt: now.Add(15 * time.Minute),
+			UserAgent:      "test",
+			IP:             "192.0.2.1",
+			ExpiresAt:      expiresAt,
+		}
+		if err := s.CreateSession(&session); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		if session.CreatedAt.IsZero() || session.LastUsedAt.IsZero() {
+			t.Error("CreateSession did not set the creation and last use times")
+		}
+		return session
+	}
+	laptop := create(alice, "laptop-1", now.Add(time.Hour))
+	create(alice, "expired", now.Add(-time.Minute))
+	phone := create(alice, "phone", now.Add(time.Hour))
+	create(bob, "bob", now.Add(time.Hour))
+
+	got, err := s.GetSession("laptop-1")
+	if err != nil || got.ID != laptop.ID || got.UserID != alice || got.TokenID != laptop.TokenID || got.IP != "192.0.2.1" {
+		t.Errorf("GetSession = %+v, %v, want the laptop session", got, err)
+	}
+	for _, token := range []string{"unknown", "expired"} {
+		if _, err := s.GetSession(token); !errors.Is(err, database.ErrSessionNotFound) {
+			t.Errorf("GetSession(%s): err = %v, want ErrSessionNotFound", token, err)
+		}
+	}
+
+	rotated := models.Session{
+		RefreshToken:   "laptop-2",
+		TokenID:        uuid.New(),
+		TokenExpiresAt: now.Add(15 * time.Minute),
+		UserAgent:      "test 2",
+		IP:             "192.0.2.2",
+		ExpiresAt:      now.Add(2 * time.Hour),
+	}
+	if err := s.RotateSession("laptop-1", &rotated); err != nil {
+		t.Fatalf("RotateSession: %v", err)
+	}
+	if rotated.ID != laptop.ID || rotated.UserID != alice || !rotated.LastUsedAt.After(laptop.LastUsedAt) {
+		t.Errorf("RotateSession = %+v, want the laptop session used again", rotated)
+	}
+	if revoked, err := s.IsTokenRevoked(laptop.TokenID); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked(previous token) = %v, %v, want true", revoked, err)
+	}
+	if revoked, err := s.IsTokenRevoked(rotated.TokenID); err != nil || revoked {
+		t.Errorf("IsTokenRevoked(new token) = %v, %v, want false", revoked, err)
+	}
+	again := models.Session{RefreshToken: "laptop-3", TokenID: uuid.New(), ExpiresAt: now.Add(time.Hour)}
+	if err := s.RotateSession("laptop-1", &again); !errors.Is(err, database.ErrSessionNotFound) {
+		t.Errorf("RotateSession with a rotated token: err = %v, want ErrSessionNotFound", err)
+	}
+	if got, err := s.GetSession("laptop-2"); err != nil || got.ID != laptop.ID || got.UserAgent != "test 2" {
+		t.Errorf("GetSession after RotateSession = %+v, %v, want the laptop session", got, err)
+	}
+
+	sessions, err := s.ListSessions(alice)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != laptop.ID || sessions[1].ID != phone.ID {
+		t.Errorf("ListSessions = %+v, want laptop then phone", sessions)
+	}
+
+	if err := s.DeleteSession(bob, laptop.ID); !errors.Is(err, database.ErrSessionNotFound) {
+		t.Errorf("DeleteSession by another user: err = %v, want ErrSessionNotFound", err)
+	}
+	if err := s.DeleteSession(alice, laptop.ID); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if revoked, err := s.IsTokenRevoked(rotated.TokenID); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked after DeleteSession = %v, %v, want true", revoked, err)
+	}
+	if _, err := s.GetSession("laptop-2"); !errors.Is(err, database.ErrSessionNotFound) {
+		t.Errorf("GetSession after DeleteSession: err = %v, want ErrSessionNotFound", err)
+	}
+
+	if err := s.DeleteSessions(alice); err != nil {
+		t.Fatalf("DeleteSessions: %v", err)
+	}
+	if sessions, err := s.ListSessions(alice); err != nil || len(sessions) != 0 {
+		t.Errorf("ListSessions after DeleteSessions = %+v, %v, want none", sessions, err)
+	}
+	if revoked, err := s.IsTokenRevoked(phone.TokenID); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked after DeleteSessions = %v, %v, want true", revoked, err)
+	}
+	if sessions, err := s.ListSessions(bob); err != nil || len(sessions) != 1 {
+		t.Errorf("ListSessions of another user = %+v, %v, want one", sessions, err)
+	}
+
+	// Revoked tokens are forgotten at the next login once they expire. The
+	// expiries are given in zones far from the one the store compares in.
+	var tokenIDs []uuid.UUID
+	for i, tokenExpiresAt := range []time.Time{
+		now.Add(-time.Minute).In(time.FixedZone("UTC+14", 14*60*60)),
+		now.Add(time.Minute).In(time.FixedZone("UTC-12", -12*60*60)),
+	} {
+		session := models.Session{
+			ID:             uuid.New(),
+			UserID:         bob,
+			RefreshToken:   fmt.Sprintf("bob-%d", i+2),
+			TokenID:        uuid.New(),
+			TokenExpiresAt: tokenExpiresAt,
+			ExpiresAt:      now.Add(time.Hour),
+		}
+		if err := s.CreateSession(&session); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		tokenIDs = append(tokenIDs, session.TokenID)
+	}
+	if err := s.DeleteSessions(bob); err != nil {
+		t.Fatalf("DeleteSessions: %v", err)
+	}
+	create(bob, "bob-4", now.Add(time.Hour))
+	if revoked, err := s.IsTokenRevoked(tokenIDs[0]); err != nil || revoked {
+		t.Errorf("IsTokenRevoked(expired token) after login = %v, %v, want false", revoked, err)
+	}
+	if revoked, err := s.IsTokenRevoked(tokenIDs[1]); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked(unexpired token) after login = %v, %v, want true", revoked, err)
+	}
+}
+
+func createUser(t testing.TB, s database.Store, name string) uuid.UUID {
+	t.Helper()
+	user := models.User{Username: name, Email: name + "@example.com", Password: "password"}
+	if err := s.CreateUser(&user); err != nil {
+		t.Fatalf("CreateUser(%s): %v", name, err)
+	}
+	return user.ID
+}
+
+func createFolder(t testing.TB, s database.Store, userID uuid.UUID, name string, parentID *uuid.UUID) models.Folder {
+	t.Helper()
+	folder := models.Folder{ID: uuid.New(), Name: name, UserID: userID, ParentID: parentID}
+	if err := s.CreateFolder(folder); err != nil {
+		t.Fatalf("CreateFolder(%s): %v", name, err)
+	}
+	return folder
+}
+
+func newSnippet(userID uuid.UUID, title, language string, tags ...string) models.Snippet {
+	return models.Snippet{
+		ID:          uuid.New(),
+		Title:       title,
+		Description: "description of " + title,
+		Language:    language,
+		Code:        "// " + title,
+		UserID:      userID,
+		Tags:        append([]string{language}, tags...),
+	}
+}
+
+func assertTags(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	got = slices.Clone(got)
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+}
+
+func assertSnippetIDs(t *testing.T, snippets []models.Snippet, want ...uuid.UUID) {
+	t.Helper()
+	var got []string
+	for _, snip := range snippets {
+		got = append(got, snip.ID.String())
+	}
+	var wantStr []string
+	for _, id := range want {
+		wantStr = append(wantStr, id.String())
+	}
+	slices.Sort(got)
+	slices.Sort(wantStr)
+	if !slices.Equal(got, wantStr) {
+		t.Errorf("snippet IDs = %v, want %v", got, wantStr)
+	}
+}
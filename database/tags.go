@@ -0,0 +1,265 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// The queries below are shared by PostgresStorage and SQLiteStorage; see
+// queryer for the placeholder rules.
+
+// attachTags adds the named tags to a snippet, creating the user's missing
+// tags
+func attachTags(tx *sql.Tx, userID, snippetID uuid.UUID, tags []string) error {
+	for _, tag := range tags {
+		var tagID uuid.UUID
+		err := tx.QueryRow(
+			"INSERT INTO tags (id, user_id, name) VALUES ($1, $2, $3) ON CONFLICT (user_id, name) DO UPDATE SET name = excluded.name RETURNING id",
+			uuid.New(),
+			userID,
+			tag,
+		).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO snippet_tags (snippet_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			snippetID,
+			tagID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTag puts a tag on a snippet and records a revision unless the snippet
+// already carries it
+func addTag(tx *sql.Tx, userID, snippetID uuid.UUID, tagName string) error {
+	if err := checkSnippetOwner(tx, userID, snippetID); err != nil {
+		return err
+	}
+	var carried bool
+	err := tx.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+            WHERE st.snippet_id = $1 AND t.user_id = $2 AND t.name = $3)
+    `, snippetID, userID, tagName).Scan(&carried)
+	if err != nil || carried {
+		return err
+	}
+	if err := attachTags(tx, userID, snippetID, []string{tagName}); err != nil {
+		return err
+	}
+	return recordTagChange(tx, []uuid.UUID{snippetID})
+}
+
+// removeTag takes a tag off a snippet and records a revision if the snippet
+// carried it
+func removeTag(tx *sql.Tx, userID, snippetID uuid.UUID, tagName string) error {
+	if err := checkSnippetOwner(tx, userID, snippetID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+        DELETE FROM snippet_tags
+        WHERE snippet_id = $1 AND tag_id = (SELECT id FROM tags WHERE user_id = $2 AND name = $3)
+    `, snippetID, userID, tagName)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil
+	}
+	if err := deleteOrphanTags(tx, userID); err != nil {
+		return err
+	}
+	return recordTagChange(tx, []uuid.UUID{snippetID})
+}
+
+// deleteOrphanTags deletes the tags of a user that no snippet carries
+// anymore. It runs after every change that can detach tags.
+func deleteOrphanTags(tx *sql.Tx, userID uuid.UUID) error {
+	_, err := tx.Exec(
+		"DELETE FROM tags WHERE user_id = $1 AND NOT EXISTS (SELECT 1 FROM snippet_tags st WHERE st.tag_id = tags.id)",
+		userID,
+	)
+	return err
+}
+
+func getTagID(q queryer, userID uuid.UUID, name string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := q.QueryRow("SELECT id FROM tags WHERE user_id = $1 AND name = $2", userID, name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrTagNotFound
+	}
+	return id, err
+}
+
+func listTags(db *sql.DB, userID uuid.UUID) ([]models.Tag, error) {
+	rows, err := db.Query(`
+        SELECT t.name, COUNT(st.snippet_id)
+        FROM tags t
+        LEFT JOIN snippet_tags st ON st.tag_id = t.id
+        WHERE t.user_id = $1
+        GROUP BY t.id, t.name
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.SnippetCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortTags(tags)
+	return tags, nil
+}
+
+// sortTags orders tags by name, ignoring case, the same way in every
+// backend
+func sortTags(tags []models.Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := strings.ToLower(tags[i].Name), strings.ToLower(tags[j].Name)
+		if a != b {
+			return a < b
+		}
+		return tags[i].Name < tags[j].Name
+	})
+}
+
+func renameTag(tx *sql.Tx, userID uuid.UUID, name, newName string) error {
+	id, err := getTagID(tx, userID, name)
+	if err != nil {
+		return err
+	}
+	if newName == name {
+		return nil
+	}
+	if _, err := getTagID(tx, userID, newName); err == nil {
+		return ErrTagExists
+	} else if !errors.Is(err, ErrTagNotFound) {
+		return err
+	}
+	snippetIDs, err := taggedSnippets(tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE tags SET name = $1 WHERE id = $2", newName, id); err != nil {
+		return err
+	}
+	return recordTagChange(tx, snippetIDs)
+}
+
+// mergeTags puts the target tag on every snippet carrying the source tag
+// and deletes the source tag
+func mergeTags(tx *sql.Tx, userID uuid.UUID, source, target string) error {
+	sourceID, err := getTagID(tx, userID, source)
+	if err != nil {
+		return err
+	}
+	targetID, err := getTagID(tx, userID, target)
+	if err != nil {
+		return err
+	}
+	if sourceID == targetID {
+		return nil
+	}
+	snippetIDs, err := taggedSnippets(tx, sourceID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+        INSERT INTO snippet_tags (snippet_id, tag_id)
+        SELECT snippet_id, $1 FROM snippet_tags WHERE tag_id = $2
+        ON CONFLICT DO NOTHING
+    `, targetID, sourceID)
+	if err != nil {
+		return err
+	}
+	if err := deleteTagID(tx, sourceID); err != nil {
+		return err
+	}
+	return recordTagChange(tx, snippetIDs)
+}
+
+func deleteTag(tx *sql.Tx, userID uuid.UUID, name string) error {
+	id, err := getTagID(tx, userID, name)
+	if err != nil {
+		return err
+	}
+	snippetIDs, err := taggedSnippets(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := deleteTagID(tx, id); err != nil {
+		return err
+	}
+	return recordTagChange(tx, snippetIDs)
+}
+
+// taggedSnippets returns the IDs of the snippets carrying a tag
+func taggedSnippets(tx *sql.Tx, tagID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query("SELECT snippet_id FROM snippet_tags WHERE tag_id = $1", tagID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// snippetTags returns the names of the tags a snippet carries
+func snippetTags(tx *sql.Tx, snippetID uuid.UUID) ([]string, error) {
+	rows, err := tx.Query(`
+        SELECT t.name FROM tags t JOIN snippet_tags st ON st.tag_id = t.id
+        WHERE st.snippet_id = $1
+    `, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func deleteTagID(tx *sql.Tx, id uuid.UUID) error {
+	if _, err := tx.Exec("DELETE FROM snippet_tags WHERE tag_id = $1", id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM tags WHERE id = $1", id)
+	return err
+}
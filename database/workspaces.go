@@ -0,0 +1,298 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// The queries below are shared by PostgresStorage and SQLiteStorage; see
+// queryer for the placeholder rules.
+
+// workspacePassword is stored as the password of workspace accounts. It is
+// not a bcrypt hash, so no password matches it.
+const workspacePassword = "!"
+
+// workspaceAccount returns the username and email of the account of a
+// workspace. The email uses a reserved domain that cannot receive mail.
+func workspaceAccount(id uuid.UUID) (string, string) {
+	return "workspace:" + id.String(), id.String() + "@workspace.invalid"
+}
+
+func createWorkspace(db *sql.DB, workspace *models.Workspace, ownerID uuid.UUID) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	workspace.ID = uuid.New()
+	workspace.Role = models.RoleOwner
+	workspace.CreatedAt = time.Now()
+	username, email := workspaceAccount(workspace.ID)
+	_, err = tx.Exec(
+		"INSERT INTO users (id, username, email, password, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		workspace.ID,
+		username,
+		email,
+		workspacePassword,
+		workspace.CreatedAt,
+		workspace.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO workspaces (id, name, created_at) VALUES ($1, $2, $3)",
+		workspace.ID,
+		workspace.Name,
+		workspace.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+		workspace.ID,
+		ownerID,
+		workspace.Role,
+		workspace.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func getWorkspace(db *sql.DB, userID, id uuid.UUID) (models.Workspace, error) {
+	var workspace models.Workspace
+	err := db.QueryRow(`
+        SELECT w.id, w.name, m.role, w.created_at
+        FROM workspaces w
+        JOIN workspace_members m ON m.workspace_id = w.id
+        WHERE w.id = $1 AND m.user_id = $2
+    `, id, userID).Scan(&workspace.ID, &workspace.Name, &workspace.Role, &workspace.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return workspace, ErrWorkspaceNotFound
+	}
+	return workspace, err
+}
+
+func listWorkspaces(db *sql.DB, userID uuid.UUID) ([]models.Workspace, error) {
+	rows, err := db.Query(`
+        SELECT w.id, w.name, m.role, w.created_at
+        FROM workspaces w
+        JOIN workspace_members m ON m.workspace_id = w.id
+        WHERE m.user_id = $1
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []models.Workspace{}
+	for rows.Next() {
+		var workspace models.Workspace
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.Role, &workspace.CreatedAt); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortWorkspaces(workspaces)
+	return workspaces, nil
+}
+
+// sortWorkspaces orders workspaces by name, ignoring case
+func sortWorkspaces(workspaces []models.Workspace) {
+	sort.Slice(workspaces, func(i, j int) bool {
+		a, b := strings.ToLower(workspaces[i].Name), strings.ToLower(workspaces[j].Name)
+		if a != b {
+			return a < b
+		}
+		return workspaces[i].ID.String() < workspaces[j].ID.String()
+	})
+}
+
+// deleteWorkspace deletes the account of a workspace, which takes the
+// workspace, its members and its library with it
+func deleteWorkspace(db *sql.DB, id uuid.UUID) error {
+	result, err := db.Exec("DELETE FROM users WHERE id = $1 AND id IN (SELECT id FROM workspaces)", id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result, ErrWorkspaceNotFound)
+}
+
+func listMembers(db *sql.DB, workspaceID uuid.UUID) ([]models.WorkspaceMember, error) {
+	rows, err := db.Query(`
+        SELECT m.user_id, u.username, m.role, m.created_at
+        FROM workspace_members m
+        JOIN users u ON u.id = m.user_id
+        WHERE m.workspace_id = $1
+    `, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.WorkspaceMember{}
+	for rows.Next() {
+		var member models.WorkspaceMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Role, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortMembers(members)
+	return members, nil
+}
+
+// sortMembers orders members by username, ignoring case
+func sortMembers(members []models.WorkspaceMember) {
+	sort.Slice(members, func(i, j int) bool {
+		a, b := strings.ToLower(members[i].Username), strings.ToLower(members[j].Username)
+		if a != b {
+			return a < b
+		}
+		return members[i].Username < members[j].Username
+	})
+}
+
+func addMember(db *sql.DB, workspaceID uuid.UUID, member *models.WorkspaceMember) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := workspaceExists(tx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrWorkspaceNotFound
+	}
+	// Workspaces cannot be members of workspaces
+	isWorkspace, err := workspaceExists(tx, member.UserID)
+	if err != nil {
+		return err
+	}
+	if isWorkspace {
+		return ErrUserNotFound
+	}
+	if _, err := memberRole(tx, workspaceID, member.UserID); err == nil {
+		return ErrMemberExists
+	} else if !errors.Is(err, ErrMemberNotFound) {
+		return err
+	}
+
+	member.CreatedAt = time.Now()
+	_, err = tx.Exec(
+		"INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+		workspaceID,
+		member.UserID,
+		member.Role,
+		member.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setMemberRole(db *sql.DB, workspaceID, userID uuid.UUID, role models.Role) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != models.RoleOwner {
+		if err := checkNotLastOwner(tx, workspaceID, userID); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec(
+		"UPDATE workspace_members SET role = $1 WHERE workspace_id = $2 AND user_id = $3",
+		role,
+		workspaceID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result, ErrMemberNotFound); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func removeMember(db *sql.DB, workspaceID, userID uuid.UUID) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotLastOwner(tx, workspaceID, userID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkNotLastOwner returns ErrMemberNotFound if the user is not a member
+// of the workspace and ErrLastOwner if they are its only owner
+func checkNotLastOwner(q queryer, workspaceID, userID uuid.UUID) error {
+	role, err := memberRole(q, workspaceID, userID)
+	if err != nil || role != models.RoleOwner {
+		return err
+	}
+	var owners int
+	err = q.QueryRow(
+		"SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2",
+		workspaceID,
+		models.RoleOwner,
+	).Scan(&owners)
+	if err != nil {
+		return err
+	}
+	if owners < 2 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func memberRole(q queryer, workspaceID, userID uuid.UUID) (models.Role, error) {
+	var role models.Role
+	err := q.QueryRow(
+		"SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2",
+		workspaceID,
+		userID,
+	).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return role, ErrMemberNotFound
+	}
+	return role, err
+}
+
+func workspaceExists(q queryer, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM workspaces WHERE id = $1)", id).Scan(&exists)
+	return exists, err
+}
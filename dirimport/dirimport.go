@@ -0,0 +1,150 @@
+// Package dirimport turns a tree of source files, as uploaded in a tar or
+// zip archive, into an archive that the store can import: each directory
+// becomes a folder and each text file a snippet.
+package dirimport
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/languages"
+	"snippet-manager-go/models"
+)
+
+// File is a regular file of the tree. Path is relative to the root of the
+// tree and uses forward slashes.
+type File struct {
+	Path    string
+	Data    []byte
+	ModTime time.Time
+}
+
+type Options struct {
+	// MaxCodeLength is the size above which files are skipped
+	MaxCodeLength int
+}
+
+// Result is a tree converted by Build. Sources holds the path of each
+// snippet of the archive, in the same order.
+type Result struct {
+	Archive models.Archive
+	Sources []string
+	Skipped []models.SkippedFile
+}
+
+// Build converts files into an archive. Hidden files and directories are
+// ignored; empty, binary and oversized files are skipped and reported.
+// Folders are only created for directories that hold a snippet.
+func Build(files []File, opts Options) Result {
+	result := Result{Archive: models.Archive{
+		Version:  models.ArchiveVersion,
+		Folders:  []models.ArchiveFolder{},
+		Snippets: []models.ArchiveSnippet{},
+	}}
+
+	files = append([]File(nil), files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	folders := map[string]uuid.UUID{}
+	for _, file := range files {
+		name := cleanPath(file.Path)
+		if name == "" || hidden(name) {
+			continue
+		}
+
+		reason := ""
+		switch {
+		case !utf8.Valid(file.Data) || strings.ContainsRune(string(file.Data), 0):
+			reason = "binary file"
+		case strings.TrimSpace(string(file.Data)) == "":
+			reason = "empty file"
+		case opts.MaxCodeLength > 0 && len(file.Data) > opts.MaxCodeLength:
+			reason = "file is too large"
+		}
+		if reason != "" {
+			result.skip(name, reason)
+			continue
+		}
+
+		snippet := newSnippet(name, string(file.Data))
+		snippet.CreatedAt = file.ModTime
+		snippet.UpdatedAt = file.ModTime
+		if dir := path.Dir(name); dir != "." {
+			folderID := result.folder(folders, dir)
+			snippet.FolderID = &folderID
+		}
+		result.Archive.Snippets = append(result.Archive.Snippets, snippet)
+		result.Sources = append(result.Sources, name)
+	}
+	return result
+}
+
+// newSnippet makes a snippet of a file, taking its title, description, tags
+// and language from its front matter if it has one
+func newSnippet(name, code string) models.ArchiveSnippet {
+	meta, code := parseFrontMatter(code)
+	snippet := models.ArchiveSnippet{
+		ID:          uuid.New(),
+		Title:       meta.title,
+		Description: meta.description,
+		Language:    meta.language,
+		Code:        code,
+		Tags:        meta.tags,
+	}
+	if snippet.Title == "" {
+		snippet.Title = path.Base(name)
+	}
+	if snippet.Language == "" {
+		snippet.Language = languages.Detect(name, code)
+	}
+	if snippet.Language == "" {
+		snippet.Language = languages.Text
+	}
+	if snippet.Tags == nil {
+		snippet.Tags = []string{}
+	}
+	return snippet
+}
+
+// folder returns the ID of the folder for dir, creating it and its parents
+// if needed
+func (r *Result) folder(folders map[string]uuid.UUID, dir string) uuid.UUID {
+	if id, ok := folders[dir]; ok {
+		return id
+	}
+	folder := models.ArchiveFolder{ID: uuid.New(), Name: path.Base(dir)}
+	if parent := path.Dir(dir); parent != "." {
+		parentID := r.folder(folders, parent)
+		folder.ParentID = &parentID
+	}
+	folders[dir] = folder.ID
+	r.Archive.Folders = append(r.Archive.Folders, folder)
+	return folder.ID
+}
+
+func (r *Result) skip(name, reason string) {
+	r.Skipped = append(r.Skipped, models.SkippedFile{Path: name, Reason: reason})
+}
+
+// cleanPath makes a path of an archive relative to its root, dropping any
+// ".." that would leave it
+func cleanPath(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+	return strings.TrimPrefix(name, "/")
+}
+
+// hidden reports whether a file or one of its directories is hidden, or is
+// part of the metadata that macOS adds to zip files
+func hidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
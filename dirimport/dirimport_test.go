@@ -0,0 +1,120 @@
+package dirimport
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"snippet-manager-go/models"
+)
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"main.go", "main.go"},
+		{"./src/main.go", "src/main.go"},
+		{"/etc/passwd", "etc/passwd"},
+		{"../../etc/passwd", "etc/passwd"},
+		{"src/../../main.go", "main.go"},
+		{`src\win\main.go`, "src/win/main.go"},
+		{`..\..\main.go`, "main.go"},
+		{"src//lib/", "src/lib"},
+		{".", ""},
+		{"..", ""},
+	}
+	for _, tt := range tests {
+		if got := cleanPath(tt.name); got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHidden(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"main.go", false},
+		{"src/main.go", false},
+		{"src/.env", true},
+		{".git/config", true},
+		{"src/.cache/main.go", true},
+		{"__MACOSX/._main.go", true},
+		{"src/__MACOSX/main.go", true},
+		{"macosx/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := hidden(tt.name); got != tt.want {
+			t.Errorf("hidden(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	files := []File{
+		{Path: "b/c/deep.py", Data: []byte("print('deep')\n")},
+		{Path: "../escape.go", Data: []byte("package main\n")},
+		{Path: `win\tool.sh`, Data: []byte("echo hi\n")},
+		{Path: "b/notes.txt", Data: []byte("# title: Notes\n\nremember\n")},
+		{Path: ".git/HEAD", Data: []byte("ref: refs/heads/main\n")},
+		{Path: "__MACOSX/b/._notes.txt", Data: []byte("resource fork")},
+		{Path: "b/image.png", Data: []byte("\x89PNG\x00\x00")},
+		{Path: "b/latin1.txt", Data: []byte("caf\xe9")},
+		{Path: "empty.txt", Data: []byte(" \n\t\n")},
+		{Path: "big.txt", Data: []byte(strings.Repeat("x", 65))},
+	}
+	result := Build(files, Options{MaxCodeLength: 64})
+
+	if want := []string{"b/c/deep.py", "b/notes.txt", "escape.go", "win/tool.sh"}; !slices.Equal(sorted(result.Sources), want) {
+		t.Errorf("sources = %v, want %v", result.Sources, want)
+	}
+	wantSkipped := []models.SkippedFile{
+		{Path: "b/image.png", Reason: "binary file"},
+		{Path: "b/latin1.txt", Reason: "binary file"},
+		{Path: "big.txt", Reason: "file is too large"},
+		{Path: "empty.txt", Reason: "empty file"},
+	}
+	if !slices.Equal(result.Skipped, wantSkipped) {
+		t.Errorf("skipped = %v, want %v", result.Skipped, wantSkipped)
+	}
+	if len(result.Archive.Snippets) != len(result.Sources) {
+		t.Fatalf("%d snippets for %d sources", len(result.Archive.Snippets), len(result.Sources))
+	}
+
+	// Folders are made for b, b/c and win, and nothing else
+	folders := map[string]models.ArchiveFolder{}
+	for _, f := range result.Archive.Folders {
+		folders[f.Name] = f
+	}
+	if len(folders) != 3 || folders["b"].ParentID != nil || folders["win"].ParentID != nil {
+		t.Fatalf("folders = %+v, want top-level b and win and their children", result.Archive.Folders)
+	}
+	if c := folders["c"]; c.ParentID == nil || *c.ParentID != folders["b"].ID {
+		t.Errorf("folder c has parent %v, want b", c.ParentID)
+	}
+
+	for i, snip := range result.Archive.Snippets {
+		switch result.Sources[i] {
+		case "b/c/deep.py":
+			if snip.Title != "deep.py" || snip.Language != "python" || snip.FolderID == nil || *snip.FolderID != folders["c"].ID {
+				t.Errorf("deep.py = %+v, want a python snippet in folder c", snip)
+			}
+		case "b/notes.txt":
+			if snip.Title != "Notes" || snip.Code != "remember\n" || snip.Language != "text" {
+				t.Errorf("notes.txt = %+v, want the title of its front matter", snip)
+			}
+		case "escape.go":
+			if snip.FolderID != nil || snip.Language != "go" {
+				t.Errorf("escape.go = %+v, want a go snippet at the root", snip)
+			}
+		}
+	}
+}
+
+func sorted(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
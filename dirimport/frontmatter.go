@@ -0,0 +1,93 @@
+package dirimport
+
+import (
+	"strings"
+
+	"snippet-manager-go/languages"
+)
+
+// commentPrefixes are the line comment markers front matter may use
+var commentPrefixes = []string{"//", "#", "--", ";"}
+
+type frontMatter struct {
+	title       string
+	description string
+	language    string
+	tags        []string
+}
+
+// parseFrontMatter reads the comment lines at the top of a file, after an
+// optional shebang, that set its metadata:
+//
+//	# title: Retry with backoff
+//	# description: Retries a call with exponential backoff
+//	# tags: http, retry
+//	# language: python
+//
+// It returns the metadata and the code without those lines and the blank
+// line after them. Files without front matter are returned unchanged.
+func parseFrontMatter(code string) (frontMatter, string) {
+	var meta frontMatter
+	lines := strings.SplitAfter(code, "\n")
+
+	start := 0
+	if strings.HasPrefix(code, "#!") {
+		start = 1
+	}
+	end := start
+	for ; end < len(lines); end++ {
+		key, value, ok := metadataLine(lines[end])
+		if !ok {
+			break
+		}
+		switch key {
+		case "title":
+			meta.title = value
+		case "description":
+			meta.description = value
+		case "language":
+			meta.language = languages.Normalize(value)
+		case "tags":
+			meta.tags = splitTags(value)
+		}
+	}
+	if end == start {
+		return meta, code
+	}
+	if end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+		end++
+	}
+	return meta, strings.Join(lines[:start], "") + strings.Join(lines[end:], "")
+}
+
+// metadataLine parses a comment line of the form "key: value" with a known
+// key
+func metadataLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	for _, prefix := range commentPrefixes {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		key, value, ok := strings.Cut(line[len(prefix):], ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		switch key {
+		case "title", "description", "language", "tags":
+			return key, strings.TrimSpace(value), ok
+		}
+		return "", "", false
+	}
+	return "", "", false
+}
+
+// splitTags splits a comma separated list of tags, which may be written in
+// brackets
+func splitTags(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,67 @@
+package dirimport
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		meta frontMatter
+		want string
+	}{
+		{
+			name: "none",
+			code: "package main\n",
+			want: "package main\n",
+		},
+		{
+			name: "all keys",
+			code: "// title: Retry\n// Description: Retries a call\n// tags: [http, retry, ]\n// language: golang\n\nfunc retry() {}\n",
+			meta: frontMatter{title: "Retry", description: "Retries a call", language: "go", tags: []string{"http", "retry"}},
+			want: "func retry() {}\n",
+		},
+		{
+			name: "shebang",
+			code: "#!/usr/bin/env python3\n# title: Serve\n# tags: http\nimport http.server\n",
+			meta: frontMatter{title: "Serve", tags: []string{"http"}},
+			want: "#!/usr/bin/env python3\nimport http.server\n",
+		},
+		{
+			name: "other comment prefixes",
+			code: "-- title: Users\n; description: all of them\nSELECT * FROM users;\n",
+			meta: frontMatter{title: "Users", description: "all of them"},
+			want: "SELECT * FROM users;\n",
+		},
+		{
+			name: "stops at the first other line",
+			code: "# title: Loop\n# a plain comment\n# tags: bash\n",
+			meta: frontMatter{title: "Loop"},
+			want: "# a plain comment\n# tags: bash\n",
+		},
+		{
+			name: "unknown key",
+			code: "// author: me\nfunc f() {}\n",
+			want: "// author: me\nfunc f() {}\n",
+		},
+		{
+			name: "only front matter",
+			code: "# title: Empty",
+			meta: frontMatter{title: "Empty"},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, code := parseFrontMatter(tt.code)
+			if meta.title != tt.meta.title || meta.description != tt.meta.description || meta.language != tt.meta.language || !slices.Equal(meta.tags, tt.meta.tags) {
+				t.Errorf("metadata = %+v, want %+v", meta, tt.meta)
+			}
+			if code != tt.want {
+				t.Errorf("code = %q, want %q", code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package dirimport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTooLarge is returned when the files of an archive are larger than
+// allowed once uncompressed
+var ErrTooLarge = errors.New("archive is too large")
+
+// Read reads the regular files of a zip, tar or tar.gz archive, telling them
+// apart by their magic numbers. maxSize bounds the total size of the files.
+func Read(r io.Reader, maxSize int64) ([]File, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")) {
+		// zip needs random access to its central directory, at the end
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		return readZip(bytes.NewReader(data), int64(len(data)), maxSize)
+	}
+	if bytes.HasPrefix(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return readTar(gz, maxSize)
+	}
+	return readTar(br, maxSize)
+}
+
+func readTar(r io.Reader, maxSize int64) ([]File, error) {
+	var files []File
+	var total int64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if total += hdr.Size; total > maxSize {
+			return nil, ErrTooLarge
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: hdr.Name, Data: data, ModTime: hdr.ModTime})
+	}
+}
+
+func readZip(r io.ReaderAt, size, maxSize int64) ([]File, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	var total int64
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		// The header may lie about the size, so the read is bounded too
+		remaining := maxSize - total
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, remaining+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if total += int64(len(data)); total > maxSize {
+			return nil, ErrTooLarge
+		}
+		files = append(files, File{Path: f.Name, Data: data, ModTime: f.Modified})
+	}
+	return files, nil
+}
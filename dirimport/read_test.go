@@ -0,0 +1,119 @@
+package dirimport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testFiles are the files of the archives built by the tests, in order
+var testFiles = []struct {
+	name string
+	data string
+}{
+	{"src/main.go", "package main\n"},
+	{"README.md", "# Hello\n"},
+}
+
+func tarArchive(t *testing.T, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Name: "src/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range testFiles {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("src/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range testFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	// The files hold 21 bytes in all
+	archives := []struct {
+		format string
+		data   []byte
+	}{
+		{"tar", tarArchive(t, false)},
+		{"tar.gz", tarArchive(t, true)},
+		{"zip", zipArchive(t)},
+	}
+	for _, a := range archives {
+		t.Run(a.format, func(t *testing.T) {
+			files, err := Read(bytes.NewReader(a.data), 21)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if len(files) != len(testFiles) {
+				t.Fatalf("Read returned %d files, want %d", len(files), len(testFiles))
+			}
+			for i, f := range files {
+				if f.Path != testFiles[i].name || string(f.Data) != testFiles[i].data {
+					t.Errorf("file %d = %s %q, want %s %q", i, f.Path, f.Data, testFiles[i].name, testFiles[i].data)
+				}
+			}
+
+			if _, err := Read(bytes.NewReader(a.data), 20); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("Read with a 20 byte limit: err = %v, want ErrTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"garbage":       []byte("not an archive at all, just some text that is long enough"),
+		"broken gzip":   {0x1f, 0x8b, 0x08, 0x00},
+		"broken zip":    []byte("PK\x03\x04 truncated"),
+		"truncated tar": tarArchive(t, false)[:600],
+	} {
+		if _, err := Read(bytes.NewReader(data), 1<<20); err == nil {
+			t.Errorf("Read(%s) succeeded", name)
+		}
+	}
+}
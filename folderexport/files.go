@@ -0,0 +1,115 @@
+package folderexport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"snippet-manager-go/languages"
+)
+
+// WriteFiles writes the folder as a tar.gz holding a directory named after
+// it, with a file per snippet and a directory per subfolder. Files are named
+// after the titles of the snippets, with the extension of their language.
+func WriteFiles(w io.Writer, root *Node) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeDir(tw, fileName(root.Folder.Name, "folder"), root); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeDir(tw *tar.Writer, dir string, node *Node) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir + "/",
+		Mode:     0o755,
+		ModTime:  node.Folder.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Names are made unique within the directory, subfolders first so that
+	// they keep their names
+	used := map[string]bool{}
+	for _, child := range node.Children {
+		name := uniqueName(used, fileName(child.Folder.Name, "folder"), "")
+		if err := writeDir(tw, dir+"/"+name, child); err != nil {
+			return err
+		}
+	}
+	for _, snippet := range node.Snippets {
+		base, ext := snippetFileName(snippet.Title, snippet.Language)
+		name := uniqueName(used, base, ext)
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     dir + "/" + name,
+			Mode:     0o644,
+			Size:     int64(len(snippet.Code)),
+			ModTime:  snippet.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tw, snippet.Code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnippetFileName returns the name of the file holding the code of a
+// snippet, made from its title and the extension of its language
+func SnippetFileName(title, language string) string {
+	base, ext := snippetFileName(title, language)
+	return base + ext
+}
+
+// snippetFileName splits the file name of a snippet into a base name and an
+// extension. Titles that already end with an extension of the language, such
+// as "main.go", are kept as they are.
+func snippetFileName(title, language string) (string, string) {
+	name := fileName(title, "snippet")
+	if ext := path.Ext(name); ext != "" && languages.FromFilename(name) == languages.Normalize(language) {
+		return strings.TrimSuffix(name, ext), ext
+	}
+	return name, languages.Extension(language)
+}
+
+// fileName turns a title into a name that is safe in a path
+func fileName(title, fallback string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '-'
+		}
+		return r
+	}, title)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// uniqueName returns base+ext, or base-2+ext, base-3+ext... if it is taken,
+// and marks it as used. Names are compared ignoring case, since not every
+// file system tells them apart.
+func uniqueName(used map[string]bool, base, ext string) string {
+	name := base + ext
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
@@ -0,0 +1,108 @@
+package folderexport
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"snippet-manager-go/models"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"hello world", "hello world"},
+		{"a/b", "a-b"},
+		{`C:\temp`, "C--temp"},
+		{"..", "snippet"},
+		{"../../etc/passwd", "-..-etc-passwd"},
+		{" .hidden. ", "hidden"},
+		{"tab\there", "tabhere"},
+		{"", "snippet"},
+		{"???", "---"},
+	}
+	for _, tt := range tests {
+		if got := fileName(tt.title, "snippet"); got != tt.want {
+			t.Errorf("fileName(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSnippetFileName(t *testing.T) {
+	tests := []struct {
+		title, language string
+		want            string
+	}{
+		{"hello", "go", "hello.go"},
+		{"main.go", "go", "main.go"},
+		{"main.go", "golang", "main.go"},
+		{"main.go", "python", "main.go.py"},
+		{"v1.2", "python", "v1.2.py"},
+		{"notes", "unknown", "notes.txt"},
+		{"a/b", "bash", "a-b.sh"},
+		{"..", "go", "snippet.go"},
+	}
+	for _, tt := range tests {
+		if got := SnippetFileName(tt.title, tt.language); got != tt.want {
+			t.Errorf("SnippetFileName(%q, %q) = %q, want %q", tt.title, tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	used := map[string]bool{}
+	var names []string
+	for _, base := range []string{"main", "Main", "MAIN", "main-2", "other"} {
+		names = append(names, uniqueName(used, base, ".go"))
+	}
+	want := []string{"main.go", "Main-2.go", "MAIN-3.go", "main-2-2.go", "other.go"}
+	if !slices.Equal(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	root := &Node{
+		Folder: models.Folder{Name: "a/b"},
+		Snippets: []models.Snippet{
+			{Title: "lib", Language: "go", Code: "package lib\n"},
+			{Title: "Readme", Language: "markdown", Code: "# one"},
+			{Title: "README", Language: "markdown", Code: "# two"},
+			{Title: "..", Language: "bash", Code: "echo"},
+		},
+		Children: []*Node{{Folder: models.Folder{Name: "Lib.go"}}},
+	}
+	var buf bytes.Buffer
+	if err := WriteFiles(&buf, root); err != nil {
+		t.Fatalf("WriteFiles: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	// The subfolder keeps its name and the snippet that clashes with it
+	// ignoring case is renamed
+	want := []string{"a-b/", "a-b/Lib.go/", "a-b/lib-2.go", "a-b/Readme.md", "a-b/README-2.md", "a-b/snippet.sh"}
+	if !slices.Equal(names, want) {
+		t.Errorf("entries = %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,52 @@
+// Package folderexport renders a folder and everything below it in human
+// readable forms: a tree of source files or a Markdown cheat sheet.
+package folderexport
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/database"
+	"snippet-manager-go/models"
+)
+
+// Node is a folder with its snippets and subfolders, sorted by name
+type Node struct {
+	Folder   models.Folder
+	Snippets []models.Snippet
+	Children []*Node
+}
+
+// Load reads a folder of the user and everything below it
+func Load(store database.FolderStore, userID, folderID uuid.UUID) (*Node, error) {
+	folder, err := store.GetFolder(userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	return load(store, userID, folder)
+}
+
+func load(store database.FolderStore, userID uuid.UUID, folder models.Folder) (*Node, error) {
+	snippets, subfolders, err := store.GetFolderContents(userID, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return strings.ToLower(snippets[i].Title) < strings.ToLower(snippets[j].Title)
+	})
+	sort.SliceStable(subfolders, func(i, j int) bool {
+		return strings.ToLower(subfolders[i].Name) < strings.ToLower(subfolders[j].Name)
+	})
+
+	node := &Node{Folder: folder, Snippets: snippets}
+	for _, subfolder := range subfolders {
+		child, err := load(store, userID, subfolder)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+	return node, nil
+}
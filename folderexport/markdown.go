@@ -0,0 +1,60 @@
+package folderexport
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the folder as a Markdown document with a heading per
+// folder, one level deeper for each subfolder, and a section per snippet
+// with its description, tags and code in a fenced block
+func WriteMarkdown(w io.Writer, root *Node) error {
+	bw := bufio.NewWriter(w)
+	writeSection(bw, root, 1)
+	return bw.Flush()
+}
+
+func writeSection(w *bufio.Writer, node *Node, level int) {
+	writeHeading(w, level, node.Folder.Name)
+	for _, snippet := range node.Snippets {
+		writeHeading(w, level+1, snippet.Title)
+		if description := strings.TrimSpace(snippet.Description); description != "" {
+			w.WriteString(description + "\n\n")
+		}
+		if len(snippet.Tags) > 0 {
+			w.WriteString("Tags: `" + strings.Join(snippet.Tags, "`, `") + "`\n\n")
+		}
+		fence := codeFence(snippet.Code)
+		w.WriteString(fence + snippet.Language + "\n")
+		w.WriteString(snippet.Code)
+		if !strings.HasSuffix(snippet.Code, "\n") {
+			w.WriteString("\n")
+		}
+		w.WriteString(fence + "\n\n")
+	}
+	for _, child := range node.Children {
+		writeSection(w, child, level+1)
+	}
+}
+
+// writeHeading writes an ATX heading. Markdown has no level below 6, so
+// deeper headings stay at 6.
+func writeHeading(w *bufio.Writer, level int, title string) {
+	w.WriteString(strings.Repeat("#", min(level, 6)) + " " + strings.ReplaceAll(title, "\n", " ") + "\n\n")
+}
+
+// codeFence returns a fence longer than any run of backticks in the code, so
+// that the code cannot close its block
+func codeFence(code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
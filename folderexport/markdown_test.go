@@ -0,0 +1,76 @@
+package folderexport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"snippet-manager-go/models"
+)
+
+func TestCodeFence(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"fmt.Println()", "```"},
+		{"use `x` here", "```"},
+		{"```go\nnested\n```", "````"},
+		{"a ````` b ``` c", "``````"},
+		{"", "```"},
+	}
+	for _, tt := range tests {
+		if got := codeFence(tt.code); got != tt.want {
+			t.Errorf("codeFence(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	root := &Node{
+		Folder: models.Folder{Name: "Notes"},
+		Snippets: []models.Snippet{{
+			Title:       "Fences\nin code",
+			Description: " Shows a block ",
+			Language:    "markdown",
+			Code:        "```sh\nls\n```",
+			Tags:        []string{"md", "docs"},
+		}},
+		Children: []*Node{{
+			Folder:   models.Folder{Name: "Deeper"},
+			Snippets: []models.Snippet{{Title: "Empty", Language: "text", Code: "x\n"}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, root); err != nil {
+		t.Fatalf("WriteMarkdown: %v", err)
+	}
+	want := strings.Join([]string{
+		"# Notes",
+		"",
+		"## Fences in code",
+		"",
+		"Shows a block",
+		"",
+		"Tags: `md`, `docs`",
+		"",
+		"````markdown",
+		"```sh",
+		"ls",
+		"```",
+		"````",
+		"",
+		"## Deeper",
+		"",
+		"### Empty",
+		"",
+		"```text",
+		"x",
+		"```",
+		"",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("WriteMarkdown =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/database"
+	"snippet-manager-go/dirimport"
+	"snippet-manager-go/models"
+)
+
+// archiveFileName is the name of the JSON document inside a tar.gz archive
+const archiveFileName = "archive.json"
+
+// HandleExport serves /export
+//
+//	@Summary		Export the caller's library
+//	@Description	Returns every folder and snippet of the caller, with tags, as a versioned archive that POST /import accepts.
+//	@Tags			archive
+//	@Produce		json
+//	@Produce		application/gzip
+//	@Security		BearerAuth
+//	@Param			format	query		string	false	"Archive format"	Enums(json, tar.gz)	default(json)
+//	@Success		200		{object}	models.Archive
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Router			/export [get]
+func (h *SnippetHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "tar.gz" {
+		http.Error(w, "Format must be json or tar.gz", http.StatusBadRequest)
+		return
+	}
+
+	archive, err := database.Export(h.storage, userID)
+	if err != nil {
+		http.Error(w, "Failed to export snippets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name := "snippets-" + archive.ExportedAt.Format("20060102")
+
+	if format != "tar.gz" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		json.NewEncoder(w).Encode(archive)
+		return
+	}
+
+	doc, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to export snippets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.tar.gz"`)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{
+		Name:    archiveFileName,
+		Mode:    0o644,
+		Size:    int64(len(doc)),
+		ModTime: archive.ExportedAt,
+	})
+	tw.Write(doc)
+	tw.Close()
+	gz.Close()
+}
+
+// HandleImport serves /import
+//
+//	@Summary		Import an archive
+//	@Description	Restores an archive made by GET /export, as JSON or tar.gz. Nothing is imported if any part of the archive is invalid.
+//	@Description	Folders and snippets whose ID belongs to another user are imported under a new ID; conflict decides what happens to those whose ID is already the caller's.
+//	@Tags			archive
+//	@Accept			json
+//	@Accept			application/gzip
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			archive		body		models.Archive	true	"Archive"
+//	@Param			conflict	query		string			false	"What to do with objects that already exist"	Enums(skip, overwrite, duplicate)	default(skip)
+//	@Param			new_ids		query		bool			false	"Import every object under a new ID"
+//	@Success		200			{object}	models.ImportResult
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		413			{string}	string
+//	@Router			/import [post]
+func (h *SnippetHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var opts database.ImportOptions
+	query := r.URL.Query()
+	switch query.Get("conflict") {
+	case "", "skip":
+		opts.OnConflict = database.ImportSkip
+	case "overwrite":
+		opts.OnConflict = database.ImportOverwrite
+	case "duplicate":
+		opts.OnConflict = database.ImportDuplicate
+	default:
+		http.Error(w, "Conflict must be skip, overwrite or duplicate", http.StatusBadRequest)
+		return
+	}
+	if newIDs := query.Get("new_ids"); newIDs != "" {
+		var err error
+		if opts.NewIDs, err = strconv.ParseBool(newIDs); err != nil {
+			http.Error(w, "new_ids must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	maxSize := int64(h.limits.MaxImportSize)
+	archive, err := readArchive(http.MaxBytesReader(w, r.Body, maxSize), maxSize)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, errArchiveTooLarge) {
+			http.Error(w, fmt.Sprintf("Archive cannot exceed %d bytes", maxSize), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Invalid archive: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if err := h.validateArchive(archive); err != nil {
+		http.Error(w, "Invalid archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.storage.Import(userID, archive, opts)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidArchive) {
+			detail := strings.TrimPrefix(err.Error(), database.ErrInvalidArchive.Error()+": ")
+			http.Error(w, "Invalid archive: "+detail, http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to import archive: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// HandleDirectoryImport serves /import/directory
+//
+//	@Summary		Import a directory of source files
+//	@Description	Creates a snippet for each text file of a zip, tar or tar.gz archive and a folder for each directory holding one. Languages are detected from file extensions and shebang lines.
+//	@Description	A leading block of comments such as "# title: ...", "# description: ...", "# tags: a, b" and "# language: ..." sets the metadata of a snippet and is removed from its code.
+//	@Description	Hidden files are ignored; binary, empty and invalid files are skipped and listed in the response.
+//	@Tags			archive
+//	@Accept			application/zip
+//	@Accept			application/gzip
+//	@Accept			application/x-tar
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			archive	body		string	true	"Archive of the directory"
+//	@Success		200		{object}	models.DirectoryImportResult
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Failure		413		{string}	string
+//	@Router			/import/directory [post]
+func (h *SnippetHandler) HandleDirectoryImport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxSize := int64(h.limits.MaxImportSize)
+	files, err := dirimport.Read(http.MaxBytesReader(w, r.Body, maxSize), maxSize)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, dirimport.ErrTooLarge) {
+			http.Error(w, fmt.Sprintf("Archive cannot exceed %d bytes", maxSize), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Invalid archive: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	tree := dirimport.Build(files, dirimport.Options{MaxCodeLength: h.limits.MaxCodeLength})
+	skipped := tree.Skipped
+	if skipped == nil {
+		skipped = []models.SkippedFile{}
+	}
+	// Files that would not pass the create endpoint are skipped rather than
+	// failing the whole import
+	archive := tree.Archive
+	archive.Snippets = archive.Snippets[:0]
+	for i, s := range tree.Archive.Snippets {
+		snippet := models.Snippet{Title: s.Title, Language: s.Language, Code: s.Code, Tags: s.Tags}
+		if err := h.validateSnippet(&snippet); err != nil {
+			skipped = append(skipped, models.SkippedFile{Path: tree.Sources[i], Reason: err.Error()})
+			continue
+		}
+		s.Language = snippet.Language
+		archive.Snippets = append(archive.Snippets, s)
+	}
+
+	result, err := h.storage.Import(userID, archive, database.ImportOptions{NewIDs: true})
+	if err != nil {
+		http.Error(w, "Failed to import directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DirectoryImportResult{
+		Folders:      result.Folders,
+		Snippets:     result.Snippets,
+		SkippedFiles: skipped,
+	})
+}
+
+// errArchiveTooLarge is returned by readArchive when a tar.gz expands past
+// the size limit
+var errArchiveTooLarge = errors.New("archive too large")
+
+// readArchive decodes an archive sent as JSON or as a tar.gz holding
+// archive.json, telling them apart by the gzip magic number. The body is
+// limited by the caller. Since a small gzip stream can expand to any size,
+// the whole uncompressed tar stream, including entries skipped on the way to
+// archive.json, is limited to maxSize bytes, failing with errArchiveTooLarge.
+func readArchive(r io.Reader, maxSize int64) (models.Archive, error) {
+	var archive models.Archive
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return archive, err
+	}
+	if !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		err := json.NewDecoder(br).Decode(&archive)
+		return archive, err
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return archive, err
+	}
+	tr := tar.NewReader(&budgetReader{r: gz, left: maxSize})
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return archive, fmt.Errorf("%s not found in tar.gz", archiveFileName)
+		}
+		if err != nil {
+			return archive, err
+		}
+		if hdr.Size > maxSize {
+			return archive, errArchiveTooLarge
+		}
+		if strings.TrimPrefix(hdr.Name, "./") == archiveFileName {
+			err := json.NewDecoder(tr).Decode(&archive)
+			return archive, err
+		}
+	}
+}
+
+// budgetReader reads from r until left bytes are used up, then fails with
+// errArchiveTooLarge. Reading exactly left bytes and then EOF is fine.
+type budgetReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n, errArchiveTooLarge
+	}
+	return n, err
+}
+
+// validateArchive applies the checks of the create endpoints to every
+// object of an archive, normalizing the languages of its snippets in place
+func (h *SnippetHandler) validateArchive(archive models.Archive) error {
+	for _, folder := range archive.Folders {
+		if strings.TrimSpace(folder.Name) == "" {
+			return fmt.Errorf("folder %s: name cannot be empty", folder.ID)
+		}
+	}
+	for i := range archive.Snippets {
+		s := &archive.Snippets[i]
+		snippet := models.Snippet{Title: s.Title, Language: s.Language, Code: s.Code, Tags: s.Tags}
+		if err := h.validateSnippet(&snippet); err != nil {
+			id := s.ID.String()
+			if s.ID == uuid.Nil {
+				id = "#" + strconv.Itoa(i+1)
+			}
+			return fmt.Errorf("snippet %s: %w", id, err)
+		}
+		s.Language = snippet.Language
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/config"
+	"snippet-manager-go/models"
+)
+
+// tarEntry is a file of a tar.gz built by targz
+type tarEntry struct {
+	name string
+	data []byte
+}
+
+func targz(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func archiveJSON(t *testing.T, snippets ...models.ArchiveSnippet) []byte {
+	t.Helper()
+	data, err := json.Marshal(models.Archive{Version: models.ArchiveVersion, Snippets: snippets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestImportArchive(t *testing.T) {
+	limits := config.Default().Limits
+	limits.MaxImportSize = 16 << 10
+	valid := models.ArchiveSnippet{ID: uuid.New(), Title: "hello", Language: "go", Code: "fmt.Println()"}
+	orphan := models.ArchiveSnippet{ID: uuid.New(), Title: "orphan", Language: "go", Code: "x", FolderID: &valid.ID}
+	untitled := models.ArchiveSnippet{ID: uuid.New(), Language: "go", Code: "x"}
+	huge := models.ArchiveSnippet{ID: uuid.New(), Title: "huge", Language: "go", Code: strings.Repeat("x", 32<<10)}
+	zeros := func(n int) []byte { return make([]byte, n) }
+
+	tests := []struct {
+		name   string
+		body   []byte
+		status int
+	}{
+		{"json", archiveJSON(t, valid), http.StatusOK},
+		{"tar.gz", targz(t, tarEntry{"README", []byte("hi")}, tarEntry{"./archive.json", archiveJSON(t, valid)}), http.StatusOK},
+		{"bomb before archive.json", targz(t, tarEntry{"padding", zeros(1 << 20)}, tarEntry{"archive.json", archiveJSON(t, valid)}), http.StatusRequestEntityTooLarge},
+		{"bomb in archive.json", targz(t, tarEntry{"archive.json", append(archiveJSON(t, valid), bytes.Repeat([]byte(" "), 1<<20)...)}), http.StatusRequestEntityTooLarge},
+		{"many entries over the total", targz(t, tarEntry{"a", zeros(6 << 10)}, tarEntry{"b", zeros(6 << 10)}, tarEntry{"c", zeros(6 << 10)}, tarEntry{"archive.json", archiveJSON(t, valid)}), http.StatusRequestEntityTooLarge},
+		{"body over the limit", archiveJSON(t, valid, huge), http.StatusRequestEntityTooLarge},
+		{"invalid json", []byte(`{"version": `), http.StatusBadRequest},
+		{"no archive.json", targz(t, tarEntry{"README", []byte("hi")}), http.StatusBadRequest},
+		{"truncated gzip", targz(t, tarEntry{"archive.json", archiveJSON(t, valid)})[:30], http.StatusBadRequest},
+		{"invalid snippet", archiveJSON(t, valid, untitled), http.StatusBadRequest},
+		{"missing folder", archiveJSON(t, valid, orphan), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, limits)
+			user := srv.signUp("alice")
+
+			rec := srv.do(http.MethodPost, "/import", user.Token, bytes.NewReader(tt.body))
+			assertStatus(t, "import", rec, tt.status)
+
+			snippets, err := srv.store.GetAll(user.ID)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			imported := tt.status == http.StatusOK
+			if imported && (len(snippets) != 1 || snippets[0].ID != valid.ID) {
+				t.Errorf("snippets after import = %+v, want %s", snippets, valid.ID)
+			}
+			if !imported && len(snippets) != 0 {
+				t.Errorf("%d snippets imported from a rejected archive", len(snippets))
+			}
+			if tt.status == http.StatusRequestEntityTooLarge && !strings.Contains(rec.Body.String(), "cannot exceed") {
+				t.Errorf("body = %q, want the size limit", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	database "snippet-manager-go/database"
+	"snippet-manager-go/folderexport"
+)
+
+// folderPatch is the body of PATCH /folders/{id}. Fields left out are not
+// changed; a null parent_id moves the folder to the root.
+type folderPatch struct {
+	Name     *string         `json:"name"`
+	ParentID json.RawMessage `json:"parent_id" swaggertype:"string" format:"uuid"`
+}
+
+// HandleFolder serves /folders/tree, /folders/shared, /folders/{id} and
+// its subresources
+func (h *SnippetHandler) HandleFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	idStr, subPath, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/folders/"), "/")
+	if (idStr == "tree" || idStr == "shared") && subPath == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if idStr == "tree" {
+			h.getFolderTree(w, r, userID)
+		} else {
+			h.listIncomingShares(w, r, userID)
+		}
+		return
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid folder ID", http.StatusBadRequest)
+		return
+	}
+	if resource, rest, _ := strings.Cut(subPath, "/"); resource == "shares" {
+		h.handleFolderShares(w, r, userID, id, rest)
+		return
+	}
+	if subPath != "" {
+		if subPath != "breadcrumbs" && subPath != "export" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if subPath == "export" {
+			h.exportFolder(w, r, userID, id)
+		} else {
+			h.getBreadcrumbs(w, r, userID, id)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.getFolder(w, r, userID, id)
+	case http.MethodPatch:
+		h.updateFolder(w, r, userID, id)
+	case http.MethodDelete:
+		h.deleteFolder(w, r, userID, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getFolder returns one folder
+//
+//	@Summary	Get a folder
+//	@Tags		folders
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id	path		string	true	"Folder ID"	Format(uuid)
+//	@Success	200	{object}	models.Folder
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/folders/{id} [get]
+func (h *SnippetHandler) getFolder(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	folder, err := h.storage.GetFolder(userID, id)
+	if err != nil {
+		writeFolderError(w, "Failed to retrieve folder", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folder)
+}
+
+// updateFolder renames a folder or moves it to another parent
+//
+//	@Summary		Rename or move a folder
+//	@Description	Moving a folder into itself or one of its subfolders is rejected with 409.
+//	@Tags			folders
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		string		true	"Folder ID"	Format(uuid)
+//	@Param			patch	body		folderPatch	true	"New name and/or parent; a null parent_id moves the folder to the root"
+//	@Success		200		{object}	models.Folder
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Failure		404		{string}	string
+//	@Failure		409		{string}	string
+//	@Router			/folders/{id} [patch]
+func (h *SnippetHandler) updateFolder(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	var patch folderPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	folder, err := h.storage.GetFolder(userID, id)
+	if err != nil {
+		writeFolderError(w, "Failed to retrieve folder", err)
+		return
+	}
+	if patch.Name != nil {
+		folder.Name = strings.TrimSpace(*patch.Name)
+		if folder.Name == "" {
+			http.Error(w, "Folder name cannot be empty", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(patch.ParentID) > 0 {
+		folder.ParentID = nil
+		if err := json.Unmarshal(patch.ParentID, &folder.ParentID); err != nil {
+			http.Error(w, "Invalid parent folder ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.storage.UpdateFolder(folder); err != nil {
+		writeFolderError(w, "Failed to update folder", err)
+		return
+	}
+	if folder, err = h.storage.GetFolder(userID, id); err != nil {
+		writeFolderError(w, "Failed to retrieve folder", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folder)
+}
+
+// deleteFolder removes a folder. By default its snippets and subfolders move
+// to its parent; contents=delete deletes them along with it.
+//
+//	@Summary	Delete a folder
+//	@Tags		folders
+//	@Security	BearerAuth
+//	@Param		id			path	string	true	"Folder ID"	Format(uuid)
+//	@Param		contents	query	string	false	"What happens to the snippets and subfolders"	Enums(move, delete)	default(move)
+//	@Success	204
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/folders/{id} [delete]
+func (h *SnippetHandler) deleteFolder(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	var mode database.FolderDeleteMode
+	switch r.URL.Query().Get("contents") {
+	case "", "move":
+		mode = database.MoveContentsToParent
+	case "delete":
+		mode = database.DeleteContents
+	default:
+		http.Error(w, "Contents must be move or delete", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.DeleteFolder(userID, id, mode); err != nil {
+		writeFolderError(w, "Failed to delete folder", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getFolderTree returns every folder of the user, nested
+//
+//	@Summary		Get the folder tree
+//	@Description	Returns the top-level folders with their subfolders nested in children, sorted by name.
+//	@Tags			folders
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			depth		query		int		false	"Number of levels to return; 1 returns the top-level folders only"	minimum(1)
+//	@Param			snippets	query		bool	false	"Include a summary of the snippets in each folder"
+//	@Success		200			{array}		models.FolderNode
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Router			/folders/tree [get]
+func (h *SnippetHandler) getFolderTree(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	query := r.URL.Query()
+	var opts database.FolderTreeOptions
+	if depthStr := query.Get("depth"); depthStr != "" {
+		depth, err := strconv.Atoi(depthStr)
+		if err != nil || depth < 1 {
+			http.Error(w, "Depth must be a positive number", http.StatusBadRequest)
+			return
+		}
+		opts.MaxDepth = depth
+	}
+	if snippetsStr := query.Get("snippets"); snippetsStr != "" {
+		withSnippets, err := strconv.ParseBool(snippetsStr)
+		if err != nil {
+			http.Error(w, "Snippets must be true or false", http.StatusBadRequest)
+			return
+		}
+		opts.WithSnippets = withSnippets
+	}
+
+	tree, err := h.storage.GetFolderTree(userID, opts)
+	if err != nil {
+		http.Error(w, "Failed to retrieve folder tree: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+// getBreadcrumbs returns the path from the top level to a folder
+//
+//	@Summary	Get the path to a folder
+//	@Tags		folders
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id	path		string	true	"Folder ID"	Format(uuid)
+//	@Success	200	{array}		models.Folder	"Top-level folder first, the requested folder last"
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/folders/{id}/breadcrumbs [get]
+func (h *SnippetHandler) getBreadcrumbs(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	path, err := h.storage.GetFolderPath(userID, id)
+	if err != nil {
+		writeFolderError(w, "Failed to retrieve folder path", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(path)
+}
+
+// exportFolder renders a folder and everything below it as files or as a
+// Markdown document
+//
+//	@Summary		Export a folder
+//	@Description	With format=files, returns a tar.gz of a directory named after the folder, holding a file per snippet, named after its title with the extension of its language, and a directory per subfolder.
+//	@Description	With format=markdown, returns a Markdown document with a heading per folder and a section per snippet with its description, tags and code.
+//	@Tags			folders
+//	@Produce		application/gzip
+//	@Produce		text/markdown
+//	@Security		BearerAuth
+//	@Param			id		path		string	true	"Folder ID"	Format(uuid)
+//	@Param			format	query		string	true	"Export format"	Enums(files, markdown)
+//	@Success		200		{file}		file
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Failure		404		{string}	string
+//	@Router			/folders/{id}/export [get]
+func (h *SnippetHandler) exportFolder(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	format := r.URL.Query().Get("format")
+	if format != "files" && format != "markdown" {
+		http.Error(w, "Format must be files or markdown", http.StatusBadRequest)
+		return
+	}
+
+	root, err := folderexport.Load(h.storage, userID, id)
+	if err != nil {
+		writeFolderError(w, "Failed to export folder", err)
+		return
+	}
+
+	// The export is built in memory so that a failure can still be reported
+	// instead of sending a truncated file
+	var buf bytes.Buffer
+	contentType, fileName := "application/gzip", root.Folder.Name+".tar.gz"
+	if format == "markdown" {
+		contentType, fileName = "text/markdown; charset=utf-8", root.Folder.Name+".md"
+		err = folderexport.WriteMarkdown(&buf, root)
+	} else {
+		err = folderexport.WriteFiles(&buf, root)
+	}
+	if err != nil {
+		http.Error(w, "Failed to export folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Write(buf.Bytes())
+}
+
+func writeFolderError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, database.ErrFolderNotFound):
+		http.Error(w, "Folder not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrFolderCycle):
+		http.Error(w, "Folder cannot be moved into itself or one of its subfolders", http.StatusConflict)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
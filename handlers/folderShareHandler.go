@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/database"
+	"snippet-manager-go/models"
+)
+
+// folderShareGrant is the body of POST /folders/{id}/shares
+type folderShareGrant struct {
+	// User is the username or the email of the user to share with
+	User       string            `json:"user"`
+	Permission models.Permission `json:"permission"`
+}
+
+// handleFolderShares serves /folders/{id}/shares[/{userID}], with path
+// holding whatever follows "shares/"
+func (h *SnippetHandler) handleFolderShares(w http.ResponseWriter, r *http.Request, userID, folderID uuid.UUID, path string) {
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listFolderShares(w, r, userID, folderID)
+		case http.MethodPost:
+			h.shareFolder(w, r, userID, folderID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	granteeID, err := uuid.Parse(path)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.unshareFolder(w, r, userID, folderID, granteeID)
+}
+
+// listFolderShares returns the users a folder is shared with
+//
+//	@Summary		List the shares of a folder
+//	@Description	Returns the users the folder is shared with, by username. Shares of the folders above it are not included.
+//	@Tags			folders
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Folder ID"	Format(uuid)
+//	@Success		200	{array}		models.FolderShare
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		404	{string}	string
+//	@Router			/folders/{id}/shares [get]
+func (h *SnippetHandler) listFolderShares(w http.ResponseWriter, r *http.Request, userID, folderID uuid.UUID) {
+	shares, err := h.storage.ListFolderShares(userID, folderID)
+	if err != nil {
+		writeFolderShareError(w, "Failed to retrieve folder shares", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shares)
+}
+
+// shareFolder grants a user access to a folder and everything below it
+//
+//	@Summary		Share a folder with a user
+//	@Description	With read permission the user can list the folder and its subfolders and read their snippets; with write permission they can also update the snippets, but not move or delete them.
+//	@Description	Sharing a folder again with the same user replaces the permission.
+//	@Tags			folders
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		string				true	"Folder ID"	Format(uuid)
+//	@Param			share	body		folderShareGrant	true	"User and permission"
+//	@Success		201		{object}	models.FolderShare
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Failure		404		{string}	string
+//	@Router			/folders/{id}/shares [post]
+func (h *SnippetHandler) shareFolder(w http.ResponseWriter, r *http.Request, userID, folderID uuid.UUID) {
+	var grant folderShareGrant
+	if err := json.NewDecoder(r.Body).Decode(&grant); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !grant.Permission.Valid() {
+		http.Error(w, "Permission must be read or write", http.StatusBadRequest)
+		return
+	}
+	login := strings.TrimSpace(grant.User)
+	if login == "" {
+		http.Error(w, "Username or email is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.findUser(login)
+	if err != nil {
+		writeFolderShareError(w, "Failed to find user", err)
+		return
+	}
+	if user.ID == userID {
+		http.Error(w, "Folders cannot be shared with yourself", http.StatusBadRequest)
+		return
+	}
+	share := models.FolderShare{FolderID: folderID, UserID: user.ID, Username: user.Username, Permission: grant.Permission}
+	if err := h.storage.ShareFolder(userID, &share); err != nil {
+		writeFolderShareError(w, "Failed to share folder", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(share)
+}
+
+// unshareFolder revokes the access of a user to a folder
+//
+//	@Summary	Stop sharing a folder with a user
+//	@Tags		folders
+//	@Security	BearerAuth
+//	@Param		id		path	string	true	"Folder ID"	Format(uuid)
+//	@Param		userID	path	string	true	"User the folder is shared with"	Format(uuid)
+//	@Success	204
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/folders/{id}/shares/{userID} [delete]
+func (h *SnippetHandler) unshareFolder(w http.ResponseWriter, r *http.Request, userID, folderID, granteeID uuid.UUID) {
+	if err := h.storage.UnshareFolder(userID, folderID, granteeID); err != nil {
+		writeFolderShareError(w, "Failed to unshare folder", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listIncomingShares returns the folders other users shared with the caller
+//
+//	@Summary		List the folders shared with me
+//	@Description	Returns the folders other users shared with the caller, by name, with their owner and the permission granted. Pass a folder ID to GET /folders to list its contents.
+//	@Tags			folders
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		models.IncomingShare
+//	@Failure		401	{string}	string
+//	@Router			/folders/shared [get]
+func (h *SnippetHandler) listIncomingShares(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	shares, err := h.storage.ListIncomingShares(userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve shared folders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shares)
+}
+
+// folderOwner returns the owner of a folder the user can read: the user, or
+// another user who shared it or a folder above it. It writes a 404 response
+// when the user has no access.
+func (h *SnippetHandler) folderOwner(w http.ResponseWriter, userID, id uuid.UUID) (uuid.UUID, bool) {
+	owner, _, err := h.storage.FolderAccess(userID, id)
+	if err != nil {
+		writeFolderError(w, "Failed to retrieve folder", err)
+		return uuid.Nil, false
+	}
+	return owner, true
+}
+
+// snippetOwner does the same for a snippet through its folder, requiring
+// write permission when write is set
+func (h *SnippetHandler) snippetOwner(w http.ResponseWriter, userID, id uuid.UUID, write bool) (uuid.UUID, bool) {
+	owner, permission, err := h.storage.SnippetAccess(userID, id)
+	if err != nil {
+		if errors.Is(err, database.ErrSnippetNotFound) {
+			http.Error(w, "Snippet not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve snippet: "+err.Error(), http.StatusInternalServerError)
+		}
+		return uuid.Nil, false
+	}
+	if write && permission != models.PermissionWrite {
+		http.Error(w, "The snippet is shared read-only", http.StatusForbidden)
+		return uuid.Nil, false
+	}
+	return owner, true
+}
+
+func writeFolderShareError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, database.ErrFolderNotFound):
+		http.Error(w, "Folder not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrFolderShareNotFound):
+		http.Error(w, "Folder share not found", http.StatusNotFound)
+	default:
+		http.Error(w, msg+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
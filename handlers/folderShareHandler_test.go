@@ -0,0 +1,138 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/config"
+	"snippet-manager-go/models"
+)
+
+// createFolder creates a folder through the API
+func (s *testServer) createFolder(token string, folder models.Folder) models.Folder {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/folders", token, folder)
+	if rec.Code != http.StatusCreated {
+		s.t.Fatalf("create folder: %d %s", rec.Code, rec.Body)
+	}
+	decodeBody(s.t, rec, &folder)
+	return folder
+}
+
+// shareFolder shares a folder of alice with bob and carol, read-only for bob
+// and writable for carol. It returns the ID of the folder and a snippet in a
+// subfolder of it with two revisions.
+func shareFolder(t *testing.T, srv *testServer, alice testUser) (uuid.UUID, models.Snippet) {
+	t.Helper()
+	folder := srv.createFolder(alice.Token, models.Folder{Name: "shared"})
+	sub := srv.createFolder(alice.Token, models.Folder{Name: "sub", ParentID: &folder.ID})
+	snippet := srv.createSnippet(alice.Token, models.Snippet{Title: "v1", Language: "go", Code: "package v1", FolderID: &sub.ID})
+	snippet.Title, snippet.Code = "v2", "package v2"
+	assertStatus(t, "update", srv.do(http.MethodPut, "/snippets/"+snippet.ID.String(), alice.Token, snippet), http.StatusOK)
+
+	for user, permission := range map[string]models.Permission{"bob": models.PermissionRead, "carol": models.PermissionWrite} {
+		rec := srv.do(http.MethodPost, "/folders/"+folder.ID.String()+"/shares", alice.Token, map[string]any{"user": user, "permission": permission})
+		assertStatus(t, "share with "+user, rec, http.StatusCreated)
+	}
+	return folder.ID, snippet
+}
+
+func TestFolderShareAccess(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	bob := srv.signUp("bob")
+	carol := srv.signUp("carol")
+	dave := srv.signUp("dave")
+	_, snippet := shareFolder(t, srv, alice)
+	path := "/snippets/" + snippet.ID.String()
+
+	for _, u := range []testUser{bob, carol} {
+		rec := srv.do(http.MethodGet, path, u.Token, nil)
+		assertStatus(t, u.Name+" reads", rec, http.StatusOK)
+		var got models.Snippet
+		decodeBody(t, rec, &got)
+		if got.Code != "package v2" {
+			t.Errorf("%s reads %q, want package v2", u.Name, got.Code)
+		}
+		var contents models.FolderContents
+		rec = srv.do(http.MethodGet, "/folders?id="+snippet.FolderID.String(), u.Token, nil)
+		assertStatus(t, u.Name+" lists the subfolder", rec, http.StatusOK)
+		decodeBody(t, rec, &contents)
+		if len(contents.Snippets) != 1 || contents.Snippets[0].ID != snippet.ID {
+			t.Errorf("%s lists %+v, want the snippet", u.Name, contents.Snippets)
+		}
+		// Grantees can update at most, never delete
+		assertStatus(t, u.Name+" deletes", srv.do(http.MethodDelete, path, u.Token, nil), http.StatusNotFound)
+	}
+	assertStatus(t, "non-grantee reads", srv.do(http.MethodGet, path, dave.Token, nil), http.StatusNotFound)
+	assertStatus(t, "non-grantee lists", srv.do(http.MethodGet, "/folders?id="+snippet.FolderID.String(), dave.Token, nil), http.StatusNotFound)
+
+	update := models.Snippet{Title: "v3", Language: "go", Code: "package v3"}
+	assertStatus(t, "read-only grantee updates", srv.do(http.MethodPut, path, bob.Token, update), http.StatusForbidden)
+	assertStatus(t, "non-grantee updates", srv.do(http.MethodPut, path, dave.Token, update), http.StatusNotFound)
+
+	// A writable grantee cannot move the snippet out of the folder
+	elsewhere := uuid.New()
+	update.FolderID = &elsewhere
+	rec := srv.do(http.MethodPut, path, carol.Token, update)
+	assertStatus(t, "writable grantee updates", rec, http.StatusOK)
+	rec = srv.do(http.MethodGet, path, alice.Token, nil)
+	var got models.Snippet
+	decodeBody(t, rec, &got)
+	if got.Code != "package v3" || got.FolderID == nil || *got.FolderID != *snippet.FolderID {
+		t.Errorf("after the update of a grantee: %+v, want v3 in the same folder", got)
+	}
+}
+
+func TestFolderShareRevisions(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	bob := srv.signUp("bob")
+	carol := srv.signUp("carol")
+	dave := srv.signUp("dave")
+	folderID, snippet := shareFolder(t, srv, alice)
+	path := "/snippets/" + snippet.ID.String()
+
+	for _, u := range []testUser{bob, carol} {
+		var revisions []models.Revision
+		rec := srv.do(http.MethodGet, path+"/revisions", u.Token, nil)
+		assertStatus(t, u.Name+" lists revisions", rec, http.StatusOK)
+		decodeBody(t, rec, &revisions)
+		if len(revisions) != 2 {
+			t.Errorf("%s lists %d revisions, want 2", u.Name, len(revisions))
+		}
+		var revision models.Revision
+		rec = srv.do(http.MethodGet, path+"/revisions/1", u.Token, nil)
+		assertStatus(t, u.Name+" reads a revision", rec, http.StatusOK)
+		decodeBody(t, rec, &revision)
+		if revision.Code != "package v1" {
+			t.Errorf("%s reads revision 1 = %q, want package v1", u.Name, revision.Code)
+		}
+		assertStatus(t, u.Name+" diffs", srv.do(http.MethodGet, path+"/diff?from=1&to=2", u.Token, nil), http.StatusOK)
+	}
+	assertStatus(t, "non-grantee lists revisions", srv.do(http.MethodGet, path+"/revisions", dave.Token, nil), http.StatusNotFound)
+	assertStatus(t, "non-grantee reads a revision", srv.do(http.MethodGet, path+"/revisions/1", dave.Token, nil), http.StatusNotFound)
+
+	restore := path + "/revisions/1/restore"
+	assertStatus(t, "read-only grantee restores", srv.do(http.MethodPost, restore, bob.Token, nil), http.StatusForbidden)
+	assertStatus(t, "non-grantee restores", srv.do(http.MethodPost, restore, dave.Token, nil), http.StatusNotFound)
+
+	rec := srv.do(http.MethodPost, restore, carol.Token, nil)
+	assertStatus(t, "writable grantee restores", rec, http.StatusOK)
+	var restored models.Snippet
+	decodeBody(t, rec, &restored)
+	if restored.Code != "package v1" || restored.UserID != alice.ID {
+		t.Errorf("restored snippet = %+v, want package v1 owned by alice", restored)
+	}
+	var revisions []models.Revision
+	decodeBody(t, srv.do(http.MethodGet, path+"/revisions", alice.Token, nil), &revisions)
+	if len(revisions) != 3 {
+		t.Errorf("owner lists %d revisions after the restore, want 3", len(revisions))
+	}
+
+	// Revoking the share revokes access to the revisions
+	assertStatus(t, "unshare", srv.do(http.MethodDelete, "/folders/"+folderID.String()+"/shares/"+carol.ID.String(), alice.Token, nil), http.StatusNoContent)
+	assertStatus(t, "former grantee lists revisions", srv.do(http.MethodGet, path+"/revisions", carol.Token, nil), http.StatusNotFound)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"snippet-manager-go/languages"
+	"snippet-manager-go/models"
+)
+
+// HandleLanguages serves /languages
+//
+//	@Summary		List languages
+//	@Description	Returns the known languages, with their aliases and file extensions, and any other language the caller's snippets use, ordered by name. Each comes with the number of the caller's snippets in it.
+//	@Tags			snippets
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			used	query		bool	false	"Only list languages the caller's snippets use"
+//	@Success		200		{array}		models.Language
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Router			/languages [get]
+func (h *SnippetHandler) HandleLanguages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	usedOnly := false
+	if used := r.URL.Query().Get("used"); used != "" {
+		var err error
+		if usedOnly, err = strconv.ParseBool(used); err != nil {
+			http.Error(w, "used must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	counts, err := h.storage.CountLanguages(userID)
+	if err != nil {
+		http.Error(w, "Failed to count languages: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := []models.Language{}
+	for _, lang := range languages.All() {
+		count := counts[lang.Name]
+		delete(counts, lang.Name)
+		if usedOnly && count == 0 {
+			continue
+		}
+		list = append(list, models.Language{
+			Name:         lang.Name,
+			Aliases:      nonNil(lang.Aliases),
+			Extensions:   nonNil(lang.Extensions),
+			Known:        true,
+			SnippetCount: count,
+		})
+	}
+	// Snippets saved before languages were normalized, or in languages the
+	// registry does not know
+	for name, count := range counts {
+		list = append(list, models.Language{Name: name, Aliases: []string{}, Extensions: []string{}, SnippetCount: count})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
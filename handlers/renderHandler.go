@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/database"
+	"snippet-manager-go/folderexport"
+	"snippet-manager-go/highlight"
+	"snippet-manager-go/languages"
+	"snippet-manager-go/models"
+)
+
+// snippetPage is the HTML page of GET /snippets/{id}?format=html
+var snippetPage = template.Must(template.New("snippet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Snippet.Title}}</title>
+</head>
+<body style="background-color:{{.Background}};font-family:sans-serif;color:{{.Foreground}}">
+<h1>{{.Snippet.Title}}</h1>
+{{if .Snippet.Description}}<p>{{.Snippet.Description}}</p>
+{{end}}<p>{{.Snippet.Language}}{{range .Snippet.Tags}} · {{.}}{{end}}</p>
+{{.Code}}
+</body>
+</html>
+`))
+
+// renderSnippet returns the code of a snippet with syntax highlighting
+//
+//	@Summary		Render a snippet
+//	@Description	Returns the code highlighted for the language of the snippet, as an HTML fragment with inline styles or as text colored with ANSI escape codes for 256-color terminals.
+//	@Tags			snippets
+//	@Produce		html
+//	@Produce		plain
+//	@Security		BearerAuth
+//	@Param			id				path		string	true	"Snippet ID"	Format(uuid)
+//	@Param			format			query		string	false	"Output format"	Enums(html, ansi)	default(html)
+//	@Param			theme			query		string	false	"Theme; see GET /themes"	default(github)
+//	@Param			line_numbers	query		bool	false	"Number the lines"
+//	@Success		200				{string}	string
+//	@Failure		400				{string}	string
+//	@Failure		401				{string}	string
+//	@Failure		404				{string}	string
+//	@Router			/snippets/{id}/render [get]
+func (h *SnippetHandler) renderSnippet(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "html" && format != "ansi" {
+		http.Error(w, "Format must be html or ansi", http.StatusBadRequest)
+		return
+	}
+	opts, ok := renderOptions(w, r)
+	if !ok {
+		return
+	}
+	ownerID, ok := h.snippetOwner(w, userID, id, false)
+	if !ok {
+		return
+	}
+	snippet, err := h.storage.Get(ownerID, id)
+	if err != nil {
+		if errors.Is(err, database.ErrSnippetNotFound) {
+			http.Error(w, "Snippet not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve snippet: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var b strings.Builder
+	render := highlight.HTML
+	contentType := "text/html; charset=utf-8"
+	if format == "ansi" {
+		render = highlight.ANSI
+		contentType = "text/plain; charset=utf-8"
+	}
+	if err := render(&b, snippet.Language, snippet.Code, opts); err != nil {
+		http.Error(w, "Failed to render snippet: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(b.String()))
+}
+
+// rawSnippet returns the code of a snippet alone
+//
+//	@Summary		Get the code of a snippet
+//	@Description	Returns the code as is, with a content type matching the language of the snippet, for use with curl and scripts. Supports If-None-Match, If-Modified-Since and range requests.
+//	@Tags			snippets
+//	@Produce		plain
+//	@Security		BearerAuth
+//	@Param			id			path		string	true	"Snippet ID"	Format(uuid)
+//	@Param			download	query		bool	false	"Send as an attachment named after the title and language"
+//	@Success		200			{string}	string
+//	@Header			200			{string}	ETag			"Changes whenever the snippet is updated"
+//	@Header			200			{string}	Last-Modified	"When the snippet was last updated"
+//	@Success		304
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		404			{string}	string
+//	@Router			/snippets/{id}/raw [get]
+func (h *SnippetHandler) rawSnippet(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	var download bool
+	if value := r.URL.Query().Get("download"); value != "" {
+		var err error
+		if download, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "download must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	ownerID, ok := h.snippetOwner(w, userID, id, false)
+	if !ok {
+		return
+	}
+	snippet, err := h.storage.Get(ownerID, id)
+	if err != nil {
+		if errors.Is(err, database.ErrSnippetNotFound) {
+			http.Error(w, "Snippet not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve snippet: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	writeRawCode(w, r, snippet, download)
+}
+
+// writeRawCode writes the code of a snippet as a file, answering
+// conditional and range requests. The ETag and Last-Modified headers both
+// come from UpdatedAt, which every change of the snippet moves.
+func writeRawCode(w http.ResponseWriter, r *http.Request, snippet models.Snippet, download bool) {
+	w.Header().Set("Content-Type", languages.MediaType(snippet.Language)+"; charset=utf-8")
+	// Keep browsers from sniffing code into something they would run
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, snippet.UpdatedAt.UnixNano()))
+	if download {
+		name := folderexport.SnippetFileName(snippet.Title, snippet.Language)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	http.ServeContent(w, r, "", snippet.UpdatedAt, strings.NewReader(snippet.Code))
+}
+
+// writeSnippetPage writes a snippet as a standalone HTML page with its
+// metadata and highlighted code
+func writeSnippetPage(w http.ResponseWriter, snippet models.Snippet, opts highlight.Options) {
+	var code strings.Builder
+	if err := highlight.HTML(&code, snippet.Language, snippet.Code, opts); err != nil {
+		http.Error(w, "Failed to render snippet: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	background, foreground := highlight.Colors(opts.Theme)
+
+	var page strings.Builder
+	err := snippetPage.Execute(&page, map[string]any{
+		"Snippet":    snippet,
+		"Code":       template.HTML(code.String()),
+		"Background": template.CSS(background),
+		"Foreground": template.CSS(foreground),
+	})
+	if err != nil {
+		http.Error(w, "Failed to render snippet: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page.String()))
+}
+
+// renderOptions reads the theme and line_numbers query parameters, writing
+// a 400 response if they are invalid
+func renderOptions(w http.ResponseWriter, r *http.Request) (highlight.Options, bool) {
+	query := r.URL.Query()
+	opts := highlight.Options{Theme: query.Get("theme")}
+	if opts.Theme != "" && !highlight.HasTheme(opts.Theme) {
+		http.Error(w, "Unknown theme; see /themes", http.StatusBadRequest)
+		return opts, false
+	}
+	if lineNumbers := query.Get("line_numbers"); lineNumbers != "" {
+		var err error
+		if opts.LineNumbers, err = strconv.ParseBool(lineNumbers); err != nil {
+			http.Error(w, "line_numbers must be true or false", http.StatusBadRequest)
+			return opts, false
+		}
+	}
+	return opts, true
+}
+
+// HandleThemes serves /themes
+//
+//	@Summary	List highlighting themes
+//	@Tags		snippets
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Success	200	{array}		string
+//	@Failure	401	{string}	string
+//	@Router		/themes [get]
+func (h *SnippetHandler) HandleThemes(w http.ResponseWriter, r *http.Request) {
+	if _, ok := currentUser(w, r); !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(highlight.Themes())
+}
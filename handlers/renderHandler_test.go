@@ -0,0 +1,106 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"snippet-manager-go/config"
+	"snippet-manager-go/models"
+)
+
+func TestRawSnippet(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	snippet := srv.createSnippet(alice.Token, models.Snippet{Title: "hello world", Language: "python", Code: "print('<b>hi</b>')\n"})
+	path := "/snippets/" + snippet.ID.String() + "/raw"
+
+	rec := srv.do(http.MethodGet, path, alice.Token, nil)
+	assertStatus(t, "raw", rec, http.StatusOK)
+	if rec.Body.String() != snippet.Code {
+		t.Errorf("body = %q, want the code", rec.Body)
+	}
+	headers := map[string]string{
+		"Content-Type":           "text/x-python; charset=utf-8",
+		"X-Content-Type-Options": "nosniff",
+		"Content-Disposition":    "",
+	}
+	for name, want := range headers {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Last-Modified") == "" {
+		t.Fatalf("ETag = %q, Last-Modified = %q, want both set", etag, rec.Header().Get("Last-Modified"))
+	}
+
+	rec = srv.do(http.MethodGet, path, alice.Token, nil, "If-None-Match", etag)
+	assertStatus(t, "raw with a matching ETag", rec, http.StatusNotModified)
+	if rec.Body.Len() != 0 {
+		t.Errorf("304 body = %q, want empty", rec.Body)
+	}
+	assertStatus(t, "raw with another ETag", srv.do(http.MethodGet, path, alice.Token, nil, "If-None-Match", `"other"`), http.StatusOK)
+	rec = srv.do(http.MethodGet, path, alice.Token, nil, "Range", "bytes=0-4")
+	assertStatus(t, "raw range", rec, http.StatusPartialContent)
+	if rec.Body.String() != "print" {
+		t.Errorf("range body = %q, want print", rec.Body)
+	}
+
+	snippet.Code = "print('bye')\n"
+	assertStatus(t, "update", srv.do(http.MethodPut, "/snippets/"+snippet.ID.String(), alice.Token, snippet), http.StatusOK)
+	rec = srv.do(http.MethodGet, path, alice.Token, nil, "If-None-Match", etag)
+	assertStatus(t, "raw with the ETag of an old version", rec, http.StatusOK)
+	if rec.Header().Get("ETag") == etag {
+		t.Errorf("ETag %s did not change with the code", etag)
+	}
+
+	assertStatus(t, "invalid download", srv.do(http.MethodGet, path+"?download=maybe", alice.Token, nil), http.StatusBadRequest)
+	assertStatus(t, "raw of another user", srv.do(http.MethodGet, path, srv.signUp("bob").Token, nil), http.StatusNotFound)
+}
+
+func TestRawSnippetDownload(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+
+	tests := []struct {
+		title, language string
+		want            string
+	}{
+		{"hello world", "python", `attachment; filename="hello world.py"`},
+		{"main.go", "go", "attachment; filename=main.go"},
+		{"a/b", "bash", "attachment; filename=a-b.sh"},
+		{"..", "go", "attachment; filename=snippet.go"},
+		{"café", "text", "attachment; filename*=utf-8''caf%C3%A9.txt"},
+	}
+	for _, tt := range tests {
+		snippet := srv.createSnippet(alice.Token, models.Snippet{Title: tt.title, Language: tt.language, Code: "x"})
+		rec := srv.do(http.MethodGet, "/snippets/"+snippet.ID.String()+"/raw?download=true", alice.Token, nil)
+		assertStatus(t, "download "+tt.title, rec, http.StatusOK)
+		if got := rec.Header().Get("Content-Disposition"); got != tt.want {
+			t.Errorf("Content-Disposition of %q = %q, want %q", tt.title, got, tt.want)
+		}
+		if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+		}
+	}
+}
+
+func TestSharedRawSnippet(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	snippet := srv.createSnippet(alice.Token, models.Snippet{Title: "page", Language: "html", Code: "<script>alert(1)</script>"})
+	path, _ := srv.createShareLink(alice.Token, models.ShareLink{SnippetID: &snippet.ID})
+
+	rec := srv.do(http.MethodGet, path+"?format=raw", "", nil)
+	assertStatus(t, "shared raw", rec, http.StatusOK)
+	// HTML is served as text so that a shared page cannot run scripts
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("Content-Disposition = %q, want none", got)
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pmezard/go-difflib/difflib"
+
+	database "snippet-manager-go/database"
+)
+
+// handleRevisions serves /snippets/{id}/revisions[/{n}[/restore]], with
+// path holding whatever follows "revisions/"
+func (h *SnippetHandler) handleRevisions(w http.ResponseWriter, r *http.Request, userID, snippetID uuid.UUID, path string) {
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.listRevisions(w, r, userID, snippetID)
+		return
+	}
+
+	numberStr, action, _ := strings.Cut(path, "/")
+	number, err := strconv.Atoi(numberStr)
+	if err != nil || number < 1 {
+		http.Error(w, "Invalid revision number", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.getRevision(w, r, userID, snippetID, number)
+	case action == "restore" && r.Method == http.MethodPost:
+		h.restoreRevision(w, r, userID, snippetID, number)
+	case action == "" || action == "restore":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// listRevisions returns the revisions of a snippet, newest first
+//
+//	@Summary	List the revisions of a snippet
+//	@Tags		revisions
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id	path		string	true	"Snippet ID"	Format(uuid)
+//	@Success	200	{array}		models.Revision
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/snippets/{id}/revisions [get]
+func (h *SnippetHandler) listRevisions(w http.ResponseWriter, r *http.Request, userID, snippetID uuid.UUID) {
+	ownerID, ok := h.snippetOwner(w, userID, snippetID, false)
+	if !ok {
+		return
+	}
+	revisions, err := h.storage.ListRevisions(ownerID, snippetID)
+	if err != nil {
+		writeRevisionError(w, "Failed to retrieve revisions", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// getRevision returns one revision of a snippet
+//
+//	@Summary	Get a revision of a snippet
+//	@Tags		revisions
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id	path		string	true	"Snippet ID"	Format(uuid)
+//	@Param		n	path		int		true	"Revision number"	minimum(1)
+//	@Success	200	{object}	models.Revision
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/snippets/{id}/revisions/{n} [get]
+func (h *SnippetHandler) getRevision(w http.ResponseWriter, r *http.Request, userID, snippetID uuid.UUID, number int) {
+	ownerID, ok := h.snippetOwner(w, userID, snippetID, false)
+	if !ok {
+		return
+	}
+	revision, err := h.storage.GetRevision(ownerID, snippetID, number)
+	if err != nil {
+		writeRevisionError(w, "Failed to retrieve revision", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// restoreRevision makes a revision the current state of its snippet
+//
+//	@Summary		Restore a revision
+//	@Description	Saves the content of the revision as the current state of the snippet, recording a new revision. Snippets shared through a folder can be restored with write permission only.
+//	@Tags			revisions
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Snippet ID"	Format(uuid)
+//	@Param			n	path		int		true	"Revision number"	minimum(1)
+//	@Success		200	{object}	models.Snippet
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		403	{string}	string
+//	@Failure		404	{string}	string
+//	@Router			/snippets/{id}/revisions/{n}/restore [post]
+func (h *SnippetHandler) restoreRevision(w http.ResponseWriter, r *http.Request, userID, snippetID uuid.UUID, number int) {
+	ownerID, ok := h.snippetOwner(w, userID, snippetID, true)
+	if !ok {
+		return
+	}
+	snippet, err := h.storage.RestoreRevision(ownerID, snippetID, number)
+	if err != nil {
+		writeRevisionError(w, "Failed to restore revision", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snippet)
+}
+
+// diffRevisions writes a unified diff of the code of two revisions. Without
+// parameters it compares the latest revision with the one before it.
+//
+//	@Summary	Diff two revisions
+//	@Tags		revisions
+//	@Produce	plain
+//	@Security	BearerAuth
+//	@Param		id		path		string	true	"Snippet ID"	Format(uuid)
+//	@Param		from	query		int		false	"Old revision; defaults to the one before to"
+//	@Param		to		query		int		false	"New revision; defaults to the latest"
+//	@Success	200		{string}	string	"Unified diff (text/x-diff)"
+//	@Failure	400		{string}	string
+//	@Failure	401		{string}	string
+//	@Failure	404		{string}	string
+//	@Router		/snippets/{id}/diff [get]
+func (h *SnippetHandler) diffRevisions(w http.ResponseWriter, r *http.Request, userID, snippetID uuid.UUID) {
+	query := r.URL.Query()
+	var from, to int
+	for param, target := range map[string]*int{"from": &from, "to": &to} {
+		if v := query.Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "Invalid "+param+" revision", http.StatusBadRequest)
+				return
+			}
+			*target = n
+		}
+	}
+
+	ownerID, ok := h.snippetOwner(w, userID, snippetID, false)
+	if !ok {
+		return
+	}
+	if to == 0 {
+		revisions, err := h.storage.ListRevisions(ownerID, snippetID)
+		if err != nil {
+			writeRevisionError(w, "Failed to retrieve revisions", err)
+			return
+		}
+		if len(revisions) == 0 {
+			http.Error(w, "Revision not found", http.StatusNotFound)
+			return
+		}
+		to = revisions[0].Number
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+
+	a, err := h.storage.GetRevision(ownerID, snippetID, from)
+	if err != nil {
+		writeRevisionError(w, "Failed to retrieve revision", err)
+		return
+	}
+	b, err := h.storage.GetRevision(ownerID, snippetID, to)
+	if err != nil {
+		writeRevisionError(w, "Failed to retrieve revision", err)
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(a.Code),
+		B:        diffLines(b.Code),
+		FromFile: fmt.Sprintf("%s (revision %d)", a.Title, a.Number),
+		ToFile:   fmt.Sprintf("%s (revision %d)", b.Title, b.Number),
+		Context:  3,
+	})
+	if err != nil {
+		http.Error(w, "Failed to compute diff: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.Write([]byte(diff))
+}
+
+// diffLines splits code into newline terminated lines, without the empty
+// line difflib.SplitLines adds after a trailing newline
+func diffLines(code string) []string {
+	if code == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(code, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
+
+func writeRevisionError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, database.ErrSnippetNotFound):
+		http.Error(w, "Snippet not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrRevisionNotFound):
+		http.Error(w, "Revision not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+
+	httpSwagger "github.com/swaggo/http-swagger"
+
+	"snippet-manager-go/docs"
+	"snippet-manager-go/middleware"
+)
+
+// Route is a pattern of the API and the handler serving it
+type Route struct {
+	Pattern string
+	Handler http.HandlerFunc
+}
+
+// Routes lists the routes of the API. Every route but registration, login,
+// token refresh and share links requires a token.
+func Routes(snippets *SnippetHandler, users *UserHandler, tokens *middleware.TokenManager) []Route {
+	return []Route{
+		// Public routes
+		{"/register", users.Register},
+		{"/login", users.Login},
+		{"/token/refresh", users.RefreshToken},
+		{"/s/", snippets.HandleShared},
+
+		// Protected routes
+		{"/logout", tokens.JWTAuth(users.Logout)},
+		{"/sessions", tokens.JWTAuth(users.HandleSessions)},
+		{"/sessions/", tokens.JWTAuth(users.HandleSession)},
+		{"/snippets/", tokens.JWTAuth(snippets.HandleSnippet)},
+		{"/snippets", tokens.JWTAuth(snippets.HandleSnippets)},
+		{"/languages", tokens.JWTAuth(snippets.HandleLanguages)},
+		{"/themes", tokens.JWTAuth(snippets.HandleThemes)},
+		{"/tags", tokens.JWTAuth(snippets.HandleTagList)},
+		{"/tags/", tokens.JWTAuth(snippets.HandleTags)},
+		{"/folders", tokens.JWTAuth(snippets.HandleFolders)},
+		{"/folders/", tokens.JWTAuth(snippets.HandleFolder)},
+		{"/folders/user/", tokens.JWTAuth(snippets.HandleUserFolders)},
+		{"/export", tokens.JWTAuth(snippets.HandleExport)},
+		{"/import", tokens.JWTAuth(snippets.HandleImport)},
+		{"/import/directory", tokens.JWTAuth(snippets.HandleDirectoryImport)},
+		{"/share-links", tokens.JWTAuth(snippets.HandleShareLinks)},
+		{"/share-links/", tokens.JWTAuth(snippets.HandleShareLink)},
+		{"/workspaces", tokens.JWTAuth(snippets.HandleWorkspaces)},
+		{"/workspaces/", tokens.JWTAuth(snippets.HandleWorkspace)},
+	}
+}
+
+// NewRouter serves the API along with its OpenAPI document at /openapi.json
+// and a Swagger UI at /docs
+func NewRouter(snippets *SnippetHandler, users *UserHandler, tokens *middleware.TokenManager) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, route := range Routes(snippets, users, tokens) {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
+
+	mux.HandleFunc("/openapi.json", ServeOpenAPI)
+	// The UI resolves its assets relative to the page, so it must be served
+	// below /docs/
+	mux.Handle("/docs", http.RedirectHandler("/docs/index.html", http.StatusMovedPermanently))
+	mux.HandleFunc("/docs/", httpSwagger.Handler(httpSwagger.URL("/openapi.json")))
+	return mux
+}
+
+// ServeOpenAPI writes the OpenAPI document generated from the annotations of
+// the handlers; see make docs
+func ServeOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(docs.SwaggerInfo.ReadDoc()))
+}
@@ -0,0 +1,109 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/config"
+	database "snippet-manager-go/database"
+	"snippet-manager-go/docs"
+	"snippet-manager-go/handlers"
+	"snippet-manager-go/middleware"
+	"snippet-manager-go/models"
+)
+
+// notFound is the body of http.NotFound, written for paths no handler knows
+const notFound = "404 page not found"
+
+// TestOpenAPIMatchesRoutes fails when the OpenAPI document in docs has
+// drifted from the routes the server registers. Every documented operation
+// must reach a handler that serves it, and every route must have a
+// documented operation. It runs the real handlers against an in-memory
+// store.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(docs.SwaggerInfo.ReadDoc()), &spec); err != nil {
+		t.Fatalf("parse OpenAPI document: %v", err)
+	}
+
+	store := database.NewMemoryStorage()
+	user := &models.User{Username: "checkdocs", Email: "checkdocs@example.com", Password: "checkdocs"}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	// The token belongs to no stored session, so POST /logout cannot
+	// revoke it halfway through the check
+	tokens := middleware.NewTokenManager("checkdocs-secret-checkdocs-secret", time.Hour, store)
+	token, _, err := tokens.Issue(user.ID, user.Username, uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := handlers.Routes(handlers.NewSnippetHandler(store, config.Default().Limits), handlers.NewUserHandler(store, tokens, time.Hour), tokens)
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
+
+	// Path parameters are filled with values the handlers accept, so that
+	// requests get past parsing to the storage layer
+	params := strings.NewReplacer(
+		"{id}", user.ID.String(),
+		"{snippetID}", user.ID.String(),
+		"{userID}", user.ID.String(),
+		"{n}", "1",
+		"{tag}", "checkdocs",
+	)
+
+	documented := make(map[string]bool)
+	for _, path := range sortedKeys(spec.Paths) {
+		for _, method := range sortedKeys(spec.Paths[path]) {
+			method = strings.ToUpper(method)
+			req := httptest.NewRequest(method, params.Replace(path), strings.NewReader("{}"))
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			_, pattern := mux.Handler(req)
+			if pattern == "" {
+				t.Errorf("%s %s is documented but no route matches it", method, path)
+				continue
+			}
+			documented[pattern] = true
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			switch {
+			case rec.Code == http.StatusMethodNotAllowed:
+				t.Errorf("%s %s is documented but the route does not allow %s", method, path, method)
+			case rec.Code == http.StatusNotFound && strings.TrimSpace(rec.Body.String()) == notFound:
+				t.Errorf("%s %s is documented but its handler does not serve it", method, path)
+			}
+		}
+	}
+
+	for _, route := range routes {
+		if !documented[route.Pattern] {
+			t.Errorf("route %s has no documented operation", route.Pattern)
+		}
+	}
+	if t.Failed() {
+		t.Log("docs/ has drifted from the routes; update the annotations and run make docs")
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
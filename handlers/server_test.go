@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/config"
+	database "snippet-manager-go/database"
+	"snippet-manager-go/handlers"
+	"snippet-manager-go/middleware"
+	"snippet-manager-go/models"
+)
+
+// testServer runs the router of the API against an in-memory store
+type testServer struct {
+	t       *testing.T
+	store   *database.MemoryStorage
+	tokens  *middleware.TokenManager
+	handler http.Handler
+}
+
+// testUser is a registered user with the tokens of a session
+type testUser struct {
+	ID           uuid.UUID
+	Name         string
+	Token        string
+	RefreshToken string
+}
+
+const (
+	testPassword = "password"
+	testSecret   = "handlers-test-secret-handlers-test"
+)
+
+func newTestServer(t *testing.T, limits config.Limits) *testServer {
+	t.Helper()
+	store := database.NewMemoryStorage()
+	tokens := middleware.NewTokenManager(testSecret, time.Hour, store)
+	router := handlers.NewRouter(handlers.NewSnippetHandler(store, limits), handlers.NewUserHandler(store, tokens, time.Hour), tokens)
+	return &testServer{t: t, store: store, tokens: tokens, handler: router}
+}
+
+// do sends a request with an optional bearer token. A body that is not an
+// io.Reader is sent as JSON. header holds pairs of header names and values.
+func (s *testServer) do(method, path, token string, body any, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// signUp registers a user and logs in
+func (s *testServer) signUp(name string) testUser {
+	s.t.Helper()
+	user := models.User{Username: name, Email: name + "@example.com", Password: testPassword}
+	if rec := s.do(http.MethodPost, "/register", "", user); rec.Code != http.StatusCreated {
+		s.t.Fatalf("register %s: %d %s", name, rec.Code, rec.Body)
+	}
+	return s.login(name)
+}
+
+// login starts a new session of a registered user
+func (s *testServer) login(name string) testUser {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/login", "", map[string]string{"username": name, "password": testPassword})
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("login %s: %d %s", name, rec.Code, rec.Body)
+	}
+	var resp struct {
+		Token        string      `json:"token"`
+		RefreshToken string      `json:"refresh_token"`
+		User         models.User `json:"user"`
+	}
+	decodeBody(s.t, rec, &resp)
+	return testUser{ID: resp.User.ID, Name: name, Token: resp.Token, RefreshToken: resp.RefreshToken}
+}
+
+// createSnippet creates a snippet through the API and returns it
+func (s *testServer) createSnippet(token string, snippet models.Snippet, header ...string) models.Snippet {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/snippets", token, snippet, header...)
+	if rec.Code != http.StatusCreated {
+		s.t.Fatalf("create snippet: %d %s", rec.Code, rec.Body)
+	}
+	var created models.Snippet
+	decodeBody(s.t, rec, &created)
+	return created
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body, err)
+	}
+}
+
+// assertStatus fails the test unless the response has the wanted status
+func assertStatus(t *testing.T, what string, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Errorf("%s: status = %d (%s), want %d", what, rec.Code, bytes.TrimSpace(rec.Body.Bytes()), want)
+	}
+}
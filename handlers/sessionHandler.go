@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/database"
+	"snippet-manager-go/middleware"
+	"snippet-manager-go/models"
+)
+
+// tokenRefresh is the body of POST /token/refresh
+type tokenRefresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken serves /token/refresh
+//
+//	@Summary		Renew the tokens of a session
+//	@Description	Exchanges a refresh token for a new access token and a new refresh token. The old refresh token and the previous access token of the session stop working.
+//	@Description	A session ends when it has not been refreshed for the refresh TTL of the server, 30 days by default.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			refresh	body		tokenRefresh	true	"Refresh token"
+//	@Success		200		{object}	tokenResponse
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Router			/token/refresh [post]
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var refresh tokenRefresh
+	if err := json.NewDecoder(r.Body).Decode(&refresh); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if refresh.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.storage.GetSession(refresh.RefreshToken)
+	if err != nil {
+		writeRefreshError(w, "Failed to retrieve session", err)
+		return
+	}
+	user, err := h.storage.GetUserByID(current.UserID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session := models.Session{ID: current.ID, UserID: current.UserID}
+	tokens, err := h.issueTokens(r, &session, user.Username)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	if err := h.storage.RotateSession(refresh.RefreshToken, &session); err != nil {
+		writeRefreshError(w, "Failed to refresh session", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout serves /logout
+//
+//	@Summary		Log out
+//	@Description	Ends the session of the access token, which stops working along with its refresh token. With all=true, ends every session of the user.
+//	@Tags			users
+//	@Security		BearerAuth
+//	@Param			all	query	bool	false	"End every session of the user, on all devices"
+//	@Success		204
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Router			/logout [post]
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var all bool
+	if value := r.URL.Query().Get("all"); value != "" {
+		var err error
+		if all, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "all must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var err error
+	if all {
+		err = h.storage.DeleteSessions(userID)
+	} else {
+		sessionID, _ := middleware.SessionIDFromContext(r.Context())
+		err = h.storage.DeleteSession(userID, sessionID)
+	}
+	// A session that is already gone is as good as ended
+	if err != nil && !errors.Is(err, database.ErrSessionNotFound) {
+		http.Error(w, "Failed to log out: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSessions serves /sessions
+func (h *UserHandler) HandleSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.listSessions(w, r, userID)
+}
+
+// HandleSession serves /sessions/{id}
+func (h *UserHandler) HandleSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/sessions/"))
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.deleteSession(w, r, userID, id)
+}
+
+// listSessions returns the active sessions of the caller
+//
+//	@Summary		List sessions
+//	@Description	Returns the sessions of the caller, most recently used first, with the user agent and IP address of the device that last logged in or refreshed its tokens with each. current marks the session of the request.
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		models.Session
+//	@Failure		401	{string}	string
+//	@Router			/sessions [get]
+func (h *UserHandler) listSessions(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	sessions, err := h.storage.ListSessions(userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sessionID, _ := middleware.SessionIDFromContext(r.Context())
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == sessionID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// deleteSession ends a session of the caller, such as one on a lost device
+//
+//	@Summary	End a session
+//	@Tags		users
+//	@Security	BearerAuth
+//	@Param		id	path	string	true	"Session ID"	Format(uuid)
+//	@Success	204
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/sessions/{id} [delete]
+func (h *UserHandler) deleteSession(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	if err := h.storage.DeleteSession(userID, id); err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to end session: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueTokens gives a session a new access token and refresh token, records
+// the device making the request and extends the session by the refresh TTL.
+// The caller stores the session.
+func (h *UserHandler) issueTokens(r *http.Request, session *models.Session, username string) (tokenResponse, error) {
+	refreshToken, err := newToken()
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	session.TokenID = uuid.New()
+	token, expiresAt, err := h.tokens.Issue(session.UserID, username, session.ID, session.TokenID)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	session.RefreshToken = refreshToken
+	session.TokenExpiresAt = expiresAt
+	session.ExpiresAt = time.Now().Add(h.refreshTTL)
+	session.UserAgent = r.UserAgent()
+	session.IP = clientIP(r)
+	return tokenResponse{Token: token, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// clientIP returns the address of the peer of a request. Headers set by
+// proxies are ignored, since anyone can send them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeRefreshError fails a refresh token that is unknown, expired or
+// already used with the same 401
+func writeRefreshError(w http.ResponseWriter, msg string, err error) {
+	if errors.Is(err, database.ErrSessionNotFound) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, msg+": "+err.Error(), http.StatusInternalServerError)
+}
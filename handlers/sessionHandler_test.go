@@ -0,0 +1,184 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"snippet-manager-go/config"
+	"snippet-manager-go/middleware"
+	"snippet-manager-go/models"
+)
+
+// refresh trades a refresh token for new tokens
+func (s *testServer) refresh(user testUser) (testUser, int) {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": user.RefreshToken})
+	if rec.Code != http.StatusOK {
+		return testUser{}, rec.Code
+	}
+	var resp struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	decodeBody(s.t, rec, &resp)
+	user.Token, user.RefreshToken = resp.Token, resp.RefreshToken
+	return user, rec.Code
+}
+
+func TestLogout(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	laptop := srv.login("alice")
+
+	assertStatus(t, "logout", srv.do(http.MethodPost, "/logout", alice.Token, nil), http.StatusNoContent)
+	assertStatus(t, "revoked token", srv.do(http.MethodGet, "/snippets", alice.Token, nil), http.StatusUnauthorized)
+	assertStatus(t, "logout with a revoked token", srv.do(http.MethodPost, "/logout", alice.Token, nil), http.StatusUnauthorized)
+	if _, code := srv.refresh(alice); code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: status = %d, want 401", code)
+	}
+
+	// Other sessions go on
+	assertStatus(t, "other session", srv.do(http.MethodGet, "/snippets", laptop.Token, nil), http.StatusOK)
+	assertStatus(t, "invalid all", srv.do(http.MethodPost, "/logout?all=maybe", laptop.Token, nil), http.StatusBadRequest)
+}
+
+func TestLogoutAll(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	laptop := srv.login("alice")
+	bob := srv.signUp("bob")
+
+	assertStatus(t, "logout everywhere", srv.do(http.MethodPost, "/logout?all=true", alice.Token, nil), http.StatusNoContent)
+	for _, u := range []testUser{alice, laptop} {
+		assertStatus(t, "token after logout everywhere", srv.do(http.MethodGet, "/snippets", u.Token, nil), http.StatusUnauthorized)
+		if _, code := srv.refresh(u); code != http.StatusUnauthorized {
+			t.Errorf("refresh after logout everywhere: status = %d, want 401", code)
+		}
+	}
+	assertStatus(t, "session of another user", srv.do(http.MethodGet, "/snippets", bob.Token, nil), http.StatusOK)
+
+	// Logging in again starts over
+	alice = srv.login("alice")
+	var sessions []models.Session
+	rec := srv.do(http.MethodGet, "/sessions", alice.Token, nil)
+	assertStatus(t, "list sessions", rec, http.StatusOK)
+	decodeBody(t, rec, &sessions)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("sessions after logging in again = %+v, want only the current one", sessions)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+
+	rotated, code := srv.refresh(alice)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status = %d, want 200", code)
+	}
+	if rotated.RefreshToken == alice.RefreshToken || rotated.Token == alice.Token {
+		t.Fatalf("refresh did not rotate the tokens")
+	}
+	assertStatus(t, "rotated token", srv.do(http.MethodGet, "/snippets", rotated.Token, nil), http.StatusOK)
+	assertStatus(t, "replaced token", srv.do(http.MethodGet, "/snippets", alice.Token, nil), http.StatusUnauthorized)
+
+	// Each refresh token works once
+	if _, code := srv.refresh(alice); code != http.StatusUnauthorized {
+		t.Errorf("reused refresh token: status = %d, want 401", code)
+	}
+	if _, code := srv.refresh(rotated); code != http.StatusOK {
+		t.Errorf("refresh with the rotated token: status = %d, want 200", code)
+	}
+	unknown := rotated
+	unknown.RefreshToken = "unknown"
+	if _, code := srv.refresh(unknown); code != http.StatusUnauthorized {
+		t.Errorf("unknown refresh token: status = %d, want 401", code)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	phone := srv.login("alice")
+	bob := srv.signUp("bob")
+
+	var sessions []models.Session
+	rec := srv.do(http.MethodGet, "/sessions", alice.Token, nil)
+	assertStatus(t, "list sessions", rec, http.StatusOK)
+	decodeBody(t, rec, &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("listed %d sessions, want 2", len(sessions))
+	}
+	var other uuid.UUID
+	for _, session := range sessions {
+		if !session.Current {
+			other = session.ID
+		}
+	}
+
+	assertStatus(t, "end a session of another user", srv.do(http.MethodDelete, "/sessions/"+other.String(), bob.Token, nil), http.StatusNotFound)
+	assertStatus(t, "end a session", srv.do(http.MethodDelete, "/sessions/"+other.String(), alice.Token, nil), http.StatusNoContent)
+	assertStatus(t, "ended session", srv.do(http.MethodGet, "/snippets", phone.Token, nil), http.StatusUnauthorized)
+	assertStatus(t, "current session", srv.do(http.MethodGet, "/snippets", alice.Token, nil), http.StatusOK)
+	assertStatus(t, "end an ended session", srv.do(http.MethodDelete, "/sessions/"+other.String(), alice.Token, nil), http.StatusNotFound)
+}
+
+func TestTokenClaims(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+
+	sign := func(secret string, method jwt.SigningMethod, claims middleware.Claims) string {
+		t.Helper()
+		key := any([]byte(secret))
+		if method == jwt.SigningMethodNone {
+			key = jwt.UnsafeAllowNoneSignatureType
+		}
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := middleware.Claims{
+		UserID:    alice.ID,
+		Username:  "alice",
+		SessionID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	// A token without a session or a jti cannot be revoked
+	noSession := valid
+	noSession.SessionID = uuid.Nil
+	noID := valid
+	noID.ID = ""
+	badID := valid
+	badID.ID = "token-1"
+	noUser := valid
+	noUser.UserID = uuid.Nil
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"all claims", sign(testSecret, jwt.SigningMethodHS256, valid), http.StatusOK},
+		{"no sid", sign(testSecret, jwt.SigningMethodHS256, noSession), http.StatusUnauthorized},
+		{"no jti", sign(testSecret, jwt.SigningMethodHS256, noID), http.StatusUnauthorized},
+		{"jti not a UUID", sign(testSecret, jwt.SigningMethodHS256, badID), http.StatusUnauthorized},
+		{"no user ID", sign(testSecret, jwt.SigningMethodHS256, noUser), http.StatusUnauthorized},
+		{"expired", sign(testSecret, jwt.SigningMethodHS256, expired), http.StatusUnauthorized},
+		{"other secret", sign("another-secret-another-secret-ano", jwt.SigningMethodHS256, valid), http.StatusUnauthorized},
+		{"unsigned", sign("", jwt.SigningMethodNone, valid), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		assertStatus(t, tt.name, srv.do(http.MethodGet, "/snippets", tt.token, nil), tt.want)
+	}
+}
@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"snippet-manager-go/database"
+	"snippet-manager-go/folderexport"
+	"snippet-manager-go/highlight"
+	"snippet-manager-go/models"
+)
+
+// sharePasswordHeader carries the password of a protected share link. It is
+// not accepted in the URL, where it would end up in logs and histories.
+const sharePasswordHeader = "X-Share-Password"
+
+// After shareFreeAttempts wrong passwords in a row, a share link is locked
+// for shareLockout, twice as long after every further wrong password, up to
+// shareMaxLockout
+const (
+	shareFreeAttempts = 5
+	shareLockout      = time.Second
+	shareMaxLockout   = time.Hour
+)
+
+// shareFailures counts the wrong passwords given for each share link, so
+// that guessing a password gets slower with every miss. The counts are kept
+// in memory; the right password resets them.
+type shareFailures struct {
+	mu    sync.Mutex
+	links map[uuid.UUID]shareFailure
+}
+
+type shareFailure struct {
+	count       int
+	lockedUntil time.Time
+}
+
+func newShareFailures() *shareFailures {
+	return &shareFailures{links: map[uuid.UUID]shareFailure{}}
+}
+
+// locked returns how long the link stays locked, or 0
+func (f *shareFailures) locked(id uuid.UUID) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return max(time.Until(f.links[id].lockedUntil), 0)
+}
+
+func (f *shareFailures) fail(id uuid.UUID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failure := f.links[id]
+	failure.count++
+	if extra := failure.count - shareFreeAttempts; extra >= 0 {
+		lockout := shareMaxLockout
+		if extra < 32 {
+			lockout = min(shareLockout<<extra, shareMaxLockout)
+		}
+		failure.lockedUntil = time.Now().Add(lockout)
+	}
+	f.links[id] = failure
+}
+
+func (f *shareFailures) reset(id uuid.UUID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.links, id)
+}
+
+// HandleShareLinks serves /share-links
+func (h *SnippetHandler) HandleShareLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.listShareLinks(w, r, userID)
+	case http.MethodPost:
+		h.createShareLink(w, r, userID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleShareLink serves /share-links/{id}
+func (h *SnippetHandler) HandleShareLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/share-links/"))
+	if err != nil {
+		http.Error(w, "Invalid share link ID", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.deleteShareLink(w, r, userID, id)
+}
+
+// createShareLink makes a link giving anonymous access to a snippet or a
+// folder
+//
+//	@Summary		Create a share link
+//	@Description	Set exactly one of snippet_id and folder_id. The token of the link is only returned here; anyone holding it can read the target at /s/{token} until the link expires, reaches max_views or is revoked.
+//	@Tags			share links
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			link	body		models.ShareLink	true	"Link; only snippet_id, folder_id, password, expires_at and max_views are read"
+//	@Success		201		{object}	models.ShareLink
+//	@Header			201		{string}	Location	"Path of the shared content"
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Failure		404		{string}	string
+//	@Router			/share-links [post]
+func (h *SnippetHandler) createShareLink(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	var input models.ShareLink
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (input.SnippetID == nil) == (input.FolderID == nil) {
+		http.Error(w, "Exactly one of snippet_id and folder_id is required", http.StatusBadRequest)
+		return
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if input.MaxViews != nil && *input.MaxViews < 1 {
+		http.Error(w, "max_views must be at least 1", http.StatusBadRequest)
+		return
+	}
+	// bcrypt ignores anything past 72 bytes
+	if len(input.Password) > 72 {
+		http.Error(w, "Password cannot exceed 72 bytes", http.StatusBadRequest)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		http.Error(w, "Failed to create share link: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	link := models.ShareLink{
+		ID:        uuid.New(),
+		UserID:    userID,
+		SnippetID: input.SnippetID,
+		FolderID:  input.FolderID,
+		Token:     token,
+		Password:  input.Password,
+		ExpiresAt: input.ExpiresAt,
+		MaxViews:  input.MaxViews,
+	}
+	if err := h.storage.CreateShareLink(&link); err != nil {
+		switch {
+		case errors.Is(err, database.ErrSnippetNotFound):
+			http.Error(w, "Snippet not found", http.StatusNotFound)
+		case errors.Is(err, database.ErrFolderNotFound):
+			http.Error(w, "Folder not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to create share link: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/s/"+token)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+// listShareLinks returns the caller's active share links
+//
+//	@Summary		List share links
+//	@Description	Returns the caller's links that have not expired or used up their views, newest first. Tokens are not included.
+//	@Tags			share links
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		models.ShareLink
+//	@Failure		401	{string}	string
+//	@Router			/share-links [get]
+func (h *SnippetHandler) listShareLinks(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	links, err := h.storage.ListShareLinks(userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve share links: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// deleteShareLink revokes a share link
+//
+//	@Summary	Revoke a share link
+//	@Tags		share links
+//	@Security	BearerAuth
+//	@Param		id	path	string	true	"Share link ID"	Format(uuid)
+//	@Success	204
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/share-links/{id} [delete]
+func (h *SnippetHandler) deleteShareLink(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	if err := h.storage.DeleteShareLink(userID, id); err != nil {
+		if errors.Is(err, database.ErrShareLinkNotFound) {
+			http.Error(w, "Share link not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to revoke share link: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleShared serves /s/{token}, without authentication
+//
+//	@Summary		Open a share link
+//	@Description	Returns the snippet or folder behind a share link. Snippets can be returned as JSON, as raw code, as an HTML page or as ANSI colored text; folders as JSON, with everything below them, or as Markdown.
+//	@Description	Every successful request counts as a view. Expired, used up and revoked links are not found.
+//	@Tags			share links
+//	@Produce		json
+//	@Produce		plain
+//	@Produce		html
+//	@Produce		text/markdown
+//	@Param			token			path		string	true	"Token of the link"
+//	@Param			format			query		string	false	"Response format; raw, html and ansi are for snippets, markdown for folders"	Enums(json, raw, html, ansi, markdown)	default(json)
+//	@Param			theme			query		string	false	"Theme of html and ansi; see GET /themes"
+//	@Param			line_numbers	query		bool	false	"Number the lines of html and ansi"
+//	@Param			X-Share-Password	header	string	false	"Password of a protected link"
+//	@Success		200				{object}	models.SharedSnippet	"A snippet; folders are returned as models.SharedFolder"
+//	@Failure		400				{string}	string
+//	@Failure		401				{string}	string
+//	@Failure		404				{string}	string
+//	@Failure		429				{string}	string	"Too many wrong passwords; retry after Retry-After seconds"
+//	@Router			/s/{token} [get]
+func (h *SnippetHandler) HandleShared(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, "/s/")
+	link, err := h.storage.GetShareLink(token)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	valid := format == "" || format == "json"
+	if link.SnippetID != nil {
+		valid = valid || format == "raw" || format == "html" || format == "ansi"
+	} else {
+		valid = valid || format == "markdown"
+	}
+	if !valid {
+		http.Error(w, "Format not available for this link", http.StatusBadRequest)
+		return
+	}
+	opts, ok := renderOptions(w, r)
+	if !ok {
+		return
+	}
+
+	if link.HasPassword {
+		if wait := h.shareFailures.locked(link.ID); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many wrong passwords, try again later", http.StatusTooManyRequests)
+			return
+		}
+		password := r.Header.Get(sharePasswordHeader)
+		if password == "" {
+			http.Error(w, "Missing password", http.StatusUnauthorized)
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.Password), []byte(password)) != nil {
+			h.shareFailures.fail(link.ID)
+			http.Error(w, "Wrong password", http.StatusUnauthorized)
+			return
+		}
+		h.shareFailures.reset(link.ID)
+	}
+	if err := h.storage.RecordShareView(link.ID); err != nil {
+		writeShareError(w, err)
+		return
+	}
+
+	// Links can be revoked at any time, so their content must not be kept
+	w.Header().Set("Cache-Control", "private, no-store")
+	if link.SnippetID != nil {
+		h.writeSharedSnippet(w, r, link, format, opts)
+	} else {
+		h.writeSharedFolder(w, link, format)
+	}
+}
+
+func (h *SnippetHandler) writeSharedSnippet(w http.ResponseWriter, r *http.Request, link models.ShareLink, format string, opts highlight.Options) {
+	snippet, err := h.storage.Get(link.UserID, *link.SnippetID)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+
+	switch format {
+	case "raw":
+		writeRawCode(w, r, snippet, false)
+	case "html":
+		writeSnippetPage(w, snippet, opts)
+	case "ansi":
+		var b strings.Builder
+		if err := highlight.ANSI(&b, snippet.Language, snippet.Code, opts); err != nil {
+			http.Error(w, "Failed to render snippet: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(b.String()))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sharedSnippet(snippet))
+	}
+}
+
+func (h *SnippetHandler) writeSharedFolder(w http.ResponseWriter, link models.ShareLink, format string) {
+	root, err := folderexport.Load(h.storage, link.UserID, *link.FolderID)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+	if format == "markdown" {
+		var buf bytes.Buffer
+		if err := folderexport.WriteMarkdown(&buf, root); err != nil {
+			http.Error(w, "Failed to render folder: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write(buf.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sharedFolder(root))
+}
+
+func sharedSnippet(snippet models.Snippet) models.SharedSnippet {
+	tags := snippet.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return models.SharedSnippet{
+		Title:       snippet.Title,
+		Description: snippet.Description,
+		Language:    snippet.Language,
+		Code:        snippet.Code,
+		Tags:        tags,
+		UpdatedAt:   snippet.UpdatedAt,
+	}
+}
+
+func sharedFolder(node *folderexport.Node) models.SharedFolder {
+	folder := models.SharedFolder{
+		Name:     node.Folder.Name,
+		Snippets: []models.SharedSnippet{},
+		Folders:  []models.SharedFolder{},
+	}
+	for _, snippet := range node.Snippets {
+		folder.Snippets = append(folder.Snippets, sharedSnippet(snippet))
+	}
+	for _, child := range node.Children {
+		folder.Folders = append(folder.Folders, sharedFolder(child))
+	}
+	return folder
+}
+
+// writeShareError answers 404 for links that cannot be used, including
+// links whose target was deleted in the meantime
+func writeShareError(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrShareLinkNotFound) ||
+		errors.Is(err, database.ErrSnippetNotFound) ||
+		errors.Is(err, database.ErrFolderNotFound) {
+		http.Error(w, "Share link not found or expired", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Failed to open share link: "+err.Error(), http.StatusInternalServerError)
+}
+
+// newToken returns a random, URL safe token of 256 bits
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/config"
+	"snippet-manager-go/models"
+)
+
+// createShareLink shares a snippet through the API and returns the path of
+// the link and its ID
+func (s *testServer) createShareLink(token string, link models.ShareLink) (string, uuid.UUID) {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/share-links", token, link)
+	if rec.Code != http.StatusCreated {
+		s.t.Fatalf("create share link: %d %s", rec.Code, rec.Body)
+	}
+	var created models.ShareLink
+	decodeBody(s.t, rec, &created)
+	return rec.Header().Get("Location"), created.ID
+}
+
+func TestSharedSnippet(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	snippet := srv.createSnippet(alice.Token, models.Snippet{Title: "hello", Language: "go", Code: "package main"})
+
+	path, id := srv.createShareLink(alice.Token, models.ShareLink{SnippetID: &snippet.ID})
+	rec := srv.do(http.MethodGet, path, "", nil)
+	assertStatus(t, "open link", rec, http.StatusOK)
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "no-store") {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+	var shared models.SharedSnippet
+	decodeBody(t, rec, &shared)
+	if shared.Title != "hello" || shared.Code != "package main" {
+		t.Errorf("shared snippet = %+v, want the snippet", shared)
+	}
+
+	assertStatus(t, "revoke link", srv.do(http.MethodDelete, "/share-links/"+id.String(), alice.Token, nil), http.StatusNoContent)
+	assertStatus(t, "open revoked link", srv.do(http.MethodGet, path, "", nil), http.StatusNotFound)
+	assertStatus(t, "open unknown link", srv.do(http.MethodGet, "/s/unknown", "", nil), http.StatusNotFound)
+}
+
+func TestSharedSnippetLimits(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	snippet := srv.createSnippet(alice.Token, models.Snippet{Title: "hello", Language: "go", Code: "package main"})
+
+	views := 2
+	path, _ := srv.createShareLink(alice.Token, models.ShareLink{SnippetID: &snippet.ID, MaxViews: &views})
+	for i := 1; i <= views; i++ {
+		assertStatus(t, "view within max_views", srv.do(http.MethodGet, path, "", nil), http.StatusOK)
+	}
+	assertStatus(t, "view past max_views", srv.do(http.MethodGet, path, "", nil), http.StatusNotFound)
+
+	past := time.Now().Add(-time.Minute)
+	expired := models.ShareLink{ID: uuid.New(), UserID: alice.ID, SnippetID: &snippet.ID, Token: "expired-token", ExpiresAt: &past}
+	if err := srv.store.CreateShareLink(&expired); err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+	assertStatus(t, "open expired link", srv.do(http.MethodGet, "/s/expired-token", "", nil), http.StatusNotFound)
+
+	rec := srv.do(http.MethodPost, "/share-links", alice.Token, models.ShareLink{SnippetID: &snippet.ID, ExpiresAt: &past})
+	assertStatus(t, "create expired link", rec, http.StatusBadRequest)
+}
+
+func TestSharedSnippetPassword(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	snippet := srv.createSnippet(alice.Token, models.Snippet{Title: "hello", Language: "go", Code: "package main"})
+
+	views := 1
+	path, _ := srv.createShareLink(alice.Token, models.ShareLink{SnippetID: &snippet.ID, Password: "open sesame", MaxViews: &views})
+	assertStatus(t, "no password", srv.do(http.MethodGet, path, "", nil), http.StatusUnauthorized)
+	assertStatus(t, "password in the URL", srv.do(http.MethodGet, path+"?password=open+sesame", "", nil), http.StatusUnauthorized)
+	assertStatus(t, "wrong password", srv.do(http.MethodGet, path, "", nil, "X-Share-Password", "open barley"), http.StatusUnauthorized)
+
+	// Failed attempts are not views
+	rec := srv.do(http.MethodGet, path, "", nil, "X-Share-Password", "open sesame")
+	assertStatus(t, "right password", rec, http.StatusOK)
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "no-store") {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+}
+
+func TestSharedSnippetPasswordLockout(t *testing.T) {
+	srv := newTestServer(t, config.Default().Limits)
+	alice := srv.signUp("alice")
+	snippet := srv.createSnippet(alice.Token, models.Snippet{Title: "hello", Language: "go", Code: "package main"})
+	path, _ := srv.createShareLink(alice.Token, models.ShareLink{SnippetID: &snippet.ID, Password: "open sesame"})
+	other, _ := srv.createShareLink(alice.Token, models.ShareLink{SnippetID: &snippet.ID, Password: "open sesame"})
+
+	guess := func(path, password string) int {
+		return srv.do(http.MethodGet, path, "", nil, "X-Share-Password", password).Code
+	}
+
+	// The right password resets the count
+	for i := 0; i < 4; i++ {
+		guess(path, "wrong")
+	}
+	if code := guess(path, "open sesame"); code != http.StatusOK {
+		t.Fatalf("right password after 4 misses: status = %d, want 200", code)
+	}
+	for i := 0; i < 5; i++ {
+		if code := guess(path, "wrong"); code != http.StatusUnauthorized {
+			t.Fatalf("miss %d: status = %d, want 401", i+1, code)
+		}
+	}
+
+	// Five misses in a row lock the link, even for the right password
+	rec := srv.do(http.MethodGet, path, "", nil, "X-Share-Password", "open sesame")
+	assertStatus(t, "right password on a locked link", rec, http.StatusTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("no Retry-After on a locked link")
+	}
+	if code := guess(other, "open sesame"); code != http.StatusOK {
+		t.Errorf("other link: status = %d, want 200", code)
+	}
+}
@@ -3,38 +3,75 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
+	"snippet-manager-go/config"
 	database "snippet-manager-go/database"
+	"snippet-manager-go/highlight"
+	"snippet-manager-go/languages"
+	"snippet-manager-go/middleware"
 	"snippet-manager-go/models"
 )
 
-type SnippetHandler struct {
-	storage *database.PostgresStorage
+// Bounds of the number of snippets returned by a listing or a search
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
-type UserHandler struct {
-	storage *database.PostgresStorage
+// tokenResponse holds the tokens of a session: a short-lived access token,
+// sent as a bearer token, and the refresh token that renews it
+type tokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
-type Claims struct {
-	Username string `json:"username"`
-	jwt.RegisteredClaims
+type loginResponse struct {
+	tokenResponse
+	User models.User `json:"user"`
+}
+
+type SnippetHandler struct {
+	storage       database.Store
+	limits        config.Limits
+	shareFailures *shareFailures
 }
 
-var jwtKey = []byte("my_secret_key") // secret key for signing the JWT
+type UserHandler struct {
+	storage    database.Store
+	tokens     *middleware.TokenManager
+	refreshTTL time.Duration
+}
 
-func NewUserHandler(storage *database.PostgresStorage) *UserHandler {
-	return &UserHandler{storage: storage}
+func NewUserHandler(storage database.Store, tokens *middleware.TokenManager, refreshTTL time.Duration) *UserHandler {
+	return &UserHandler{storage: storage, tokens: tokens, refreshTTL: refreshTTL}
 }
 
+// Register creates an account
+//
+//	@Summary	Register a user
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Param		user	body		models.User	true	"Username, email and password"
+//	@Success	201		{object}	models.User
+//	@Failure	400		{string}	string
+//	@Failure	500		{string}	string
+//	@Router		/register [post]
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	err := json.NewDecoder(r.Body).Decode(&user)
@@ -57,11 +94,21 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// Login checks a user's password and starts a session
+//
+//	@Summary		Log in
+//	@Description	Starts a session on the calling device and responds with its tokens and the user.
+//	@Description	The access token expires at expires_at; exchange the refresh token for new tokens at POST /token/refresh before then.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			credentials	body		loginRequest	true	"Username and password"
+//	@Success		200			{object}	loginResponse
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Router			/login [post]
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var credentials struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
+	var credentials loginRequest
 
 	err := json.NewDecoder(r.Body).Decode(&credentials)
 	if err != nil {
@@ -82,110 +129,364 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour) // token valid for 24 hours
-	claims := &Claims{
-		Username: credentials.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
-	}
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	session := models.Session{ID: uuid.New(), UserID: user.ID}
+	tokens, err := h.issueTokens(r, &session, user.Username)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
+	if err := h.storage.CreateSession(&session); err != nil {
+		http.Error(w, "Failed to create session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Send token to client
 	// Clear password before sending response
 	user.Password = ""
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
-
-	if err := json.NewEncoder(w).Encode(user); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	json.NewEncoder(w).Encode(loginResponse{tokenResponse: tokens, User: *user})
 }
 
-func NewSnippetHandler(storage *database.PostgresStorage) *SnippetHandler {
-	return &SnippetHandler{storage: storage}
+func NewSnippetHandler(storage database.Store, limits config.Limits) *SnippetHandler {
+	return &SnippetHandler{storage: storage, limits: limits, shareFailures: newShareFailures()}
 }
 
 func (h *SnippetHandler) HandleSnippets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
-		h.getSnippets(w, r)
+		h.getSnippets(w, r, userID)
 	case http.MethodPost:
-		h.createSnippet(w, r)
+		h.createSnippet(w, r, userID)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (h *SnippetHandler) HandleSnippet(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/snippets/")
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	idStr, subPath, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/snippets/"), "/")
+	if idStr == "search" && subPath == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.searchSnippets(w, r, userID)
+		return
+	}
 	id, err := uuid.Parse(idStr)
 	if err != nil {
 		http.Error(w, "Invalid snippet ID", http.StatusBadRequest)
 		return
 	}
+	if subPath != "" {
+		resource, rest, _ := strings.Cut(subPath, "/")
+		switch resource {
+		case "revisions":
+			h.handleRevisions(w, r, userID, id, rest)
+		case "render":
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.renderSnippet(w, r, userID, id)
+		case "raw":
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.rawSnippet(w, r, userID, id)
+		case "diff":
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.diffRevisions(w, r, userID, id)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
-		h.getSnippet(w, r, id)
+		h.getSnippet(w, r, userID, id)
 	case http.MethodPut:
-		h.updateSnippet(w, r, id)
+		h.updateSnippet(w, r, userID, id)
 	case http.MethodDelete:
-		h.deleteSnippet(w, r, id)
+		h.deleteSnippet(w, r, userID, id)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (h *SnippetHandler) getSnippets(w http.ResponseWriter, r *http.Request) {
-	snippets, err := h.storage.GetAll()
+// getSnippets lists a page of the user's snippets
+//
+//	@Summary		List snippets
+//	@Description	Returns a page of the user's snippets. Pass next_cursor back as cursor to get the following page.
+//	@Tags			snippets
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			limit			query		int			false	"Page size, 1 to 100"	default(20)
+//	@Param			cursor			query		string		false	"Cursor from the previous page"
+//	@Param			sort			query		string		false	"Sort field"	Enums(created_at, updated_at, title)
+//	@Param			order			query		string		false	"Sort order; title defaults to asc, the rest to desc"	Enums(asc, desc)
+//	@Param			language		query		string		false	"Only snippets in this language"
+//	@Param			tag				query		[]string	false	"Only snippets with these tags"	collectionFormat(multi)
+//	@Param			tag_mode		query		string		false	"Whether snippets need all or any of the tags"	Enums(all, any)	default(all)
+//	@Param			folder_id		query		string		false	"Only snippets in this folder"	Format(uuid)
+//	@Param			created_after	query		string		false	"RFC 3339 timestamp"	Format(date-time)
+//	@Param			created_before	query		string		false	"RFC 3339 timestamp"	Format(date-time)
+//	@Success		200				{object}	models.SnippetPage
+//	@Failure		400				{string}	string
+//	@Failure		401				{string}	string
+//	@Router			/snippets [get]
+func (h *SnippetHandler) getSnippets(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	query := r.URL.Query()
+	opts := database.ListOptions{
+		Cursor:   query.Get("cursor"),
+		Language: languages.Normalize(query.Get("language")),
+		Tags:     query["tag"],
+	}
+
+	var ok bool
+	if opts.Limit, ok = parseLimit(w, query.Get("limit")); !ok {
+		return
+	}
+
+	switch sort := database.SortField(query.Get("sort")); sort {
+	case "", database.SortCreatedAt, database.SortUpdatedAt:
+		opts.Sort = sort
+	case database.SortTitle:
+		// Titles read naturally A to Z, timestamps newest first
+		opts.Sort = sort
+		opts.Ascending = true
+	default:
+		http.Error(w, "Sort must be one of created_at, updated_at or title", http.StatusBadRequest)
+		return
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		opts.Ascending = true
+	case "desc":
+		opts.Ascending = false
+	default:
+		http.Error(w, "Order must be asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	switch query.Get("tag_mode") {
+	case "", "all":
+	case "any":
+		opts.AnyTag = true
+	default:
+		http.Error(w, "Tag mode must be any or all", http.StatusBadRequest)
+		return
+	}
+
+	if folderIDStr := query.Get("folder_id"); folderIDStr != "" {
+		folderID, err := uuid.Parse(folderIDStr)
+		if err != nil {
+			http.Error(w, "Invalid folder ID", http.StatusBadRequest)
+			return
+		}
+		opts.FolderID = &folderID
+	}
+	for param, target := range map[string]*time.Time{
+		"created_after":  &opts.CreatedAfter,
+		"created_before": &opts.CreatedBefore,
+	} {
+		if v := query.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+param+": expected an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			*target = t
+		}
+	}
+
+	page, err := h.storage.List(userID, opts)
 	if err != nil {
-		http.Error(w, "Failed to retrieve snippets: "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to retrieve snippets: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
+	if page.Snippets == nil {
+		page.Snippets = []models.Snippet{}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(snippets)
+	json.NewEncoder(w).Encode(page)
 }
 
-func (h *SnippetHandler) getSnippet(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
-	snippet, err := h.storage.Get(id)
+// parseLimit reads the limit query parameter, writing a 400 response if it
+// is out of range
+func parseLimit(w http.ResponseWriter, limitStr string) (int, bool) {
+	if limitStr == "" {
+		return defaultLimit, true
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxLimit {
+		http.Error(w, fmt.Sprintf("Limit must be between 1 and %d", maxLimit), http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
+
+// getSnippet returns one snippet
+//
+//	@Summary		Get a snippet
+//	@Description	With format=html, returns an HTML page showing the snippet with its code highlighted; theme and line_numbers apply to it only.
+//	@Tags			snippets
+//	@Produce		json
+//	@Produce		html
+//	@Security		BearerAuth
+//	@Param			id				path		string	true	"Snippet ID"	Format(uuid)
+//	@Param			format			query		string	false	"Response format"	Enums(json, html)	default(json)
+//	@Param			theme			query		string	false	"Theme; see GET /themes"	default(github)
+//	@Param			line_numbers	query		bool	false	"Number the lines"
+//	@Success		200				{object}	models.Snippet
+//	@Failure		400				{string}	string
+//	@Failure		401				{string}	string
+//	@Failure		404				{string}	string
+//	@Router			/snippets/{id} [get]
+func (h *SnippetHandler) getSnippet(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "html" {
+		http.Error(w, "Format must be json or html", http.StatusBadRequest)
+		return
+	}
+	var opts highlight.Options
+	if format == "html" {
+		var ok bool
+		if opts, ok = renderOptions(w, r); !ok {
+			return
+		}
+	}
+
+	ownerID, ok := h.snippetOwner(w, userID, id, false)
+	if !ok {
+		return
+	}
+	snippet, err := h.storage.Get(ownerID, id)
 	if err != nil {
-		if err.Error() == "snippet not found" {
+		if errors.Is(err, database.ErrSnippetNotFound) {
 			http.Error(w, "Snippet not found", http.StatusNotFound)
 		} else {
 			http.Error(w, "Failed to retrieve snippet: "+err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
+	if format == "html" {
+		writeSnippetPage(w, snippet, opts)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(snippet)
 }
 
-func (h *SnippetHandler) createSnippet(w http.ResponseWriter, r *http.Request) {
+// searchSnippets runs a full-text search over the user's snippets
+//
+//	@Summary		Search snippets
+//	@Description	Full-text search over title, description and code, best matches first. Highlights are escaped HTML with matches wrapped in <mark></mark>.
+//	@Tags			snippets
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			q			query		string		true	"Search terms; quote phrases"
+//	@Param			language	query		string		false	"Only snippets in this language"
+//	@Param			tag			query		[]string	false	"Only snippets with all of these tags"	collectionFormat(multi)
+//	@Param			folder_id	query		string		false	"Only snippets in this folder"	Format(uuid)
+//	@Param			limit		query		int			false	"Number of results, 1 to 100"	default(20)
+//	@Success		200			{array}		models.SearchResult
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Router			/snippets/search [get]
+func (h *SnippetHandler) searchSnippets(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	query := r.URL.Query()
+	opts := database.SearchOptions{
+		Query:    strings.TrimSpace(query.Get("q")),
+		Language: languages.Normalize(query.Get("language")),
+		Tags:     query["tag"],
+	}
+	if opts.Query == "" {
+		http.Error(w, "Search query cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if folderIDStr := query.Get("folder_id"); folderIDStr != "" {
+		folderID, err := uuid.Parse(folderIDStr)
+		if err != nil {
+			http.Error(w, "Invalid folder ID", http.StatusBadRequest)
+			return
+		}
+		opts.FolderID = &folderID
+	}
+	var ok bool
+	if opts.Limit, ok = parseLimit(w, query.Get("limit")); !ok {
+		return
+	}
+
+	results, err := h.storage.Search(userID, opts)
+	if err != nil {
+		http.Error(w, "Failed to search snippets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// createSnippet stores a new snippet for the user
+//
+//	@Summary		Create a snippet
+//	@Description	The language is stored under its canonical name, so "Go" and "golang" become "go". When it is left out, it is detected from the filename hint, then from the code, and is "text" if both fail.
+//	@Tags			snippets
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			snippet		body		models.Snippet	true	"Snippet; id and user_id are ignored"
+//	@Param			filename	query		string			false	"Name of the file the code comes from, used to detect the language"
+//	@Success		201			{object}	models.Snippet
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		404			{string}	string	"Folder not found"
+//	@Router			/snippets [post]
+func (h *SnippetHandler) createSnippet(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
 	var snippet models.Snippet
 	err := json.NewDecoder(r.Body).Decode(&snippet)
 	if err != nil {
 		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err = validateSnippet(&snippet); err != nil {
+	if strings.TrimSpace(snippet.Language) == "" {
+		snippet.Language = languages.Detect(r.URL.Query().Get("filename"), snippet.Code)
+	}
+	if err = h.validateSnippet(&snippet); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	snippet.ID = uuid.New()
+	snippet.UserID = userID
 	if snippet.Code == "" {
 		http.Error(w, "Code cannot be empty", http.StatusBadRequest)
 		return
 	}
 	err = h.storage.Create(snippet)
 	if err != nil {
-		http.Error(w, "Failed to create snippet: "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, database.ErrFolderNotFound) {
+			http.Error(w, "Folder not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to create snippet: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -193,22 +494,59 @@ func (h *SnippetHandler) createSnippet(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(snippet)
 }
 
-func (h *SnippetHandler) updateSnippet(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+// updateSnippet replaces the content of a snippet
+//
+//	@Summary		Replace a snippet
+//	@Description	The language is normalized and detected as on creation.
+//	@Description	Snippets in a folder shared with the caller need write permission, and stay in their folder.
+//	@Tags			snippets
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		string			true	"Snippet ID"	Format(uuid)
+//	@Param			snippet		body		models.Snippet	true	"New content of the snippet"
+//	@Param			filename	query		string			false	"Name of the file the code comes from, used to detect the language"
+//	@Success		200			{object}	models.Snippet
+//	@Failure		400			{string}	string
+//	@Failure		401			{string}	string
+//	@Failure		403			{string}	string
+//	@Failure		404			{string}	string
+//	@Router			/snippets/{id} [put]
+func (h *SnippetHandler) updateSnippet(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
 	var snippet models.Snippet
 	err := json.NewDecoder(r.Body).Decode(&snippet)
 	if err != nil {
 		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err = validateSnippet(&snippet); err != nil {
+	if strings.TrimSpace(snippet.Language) == "" {
+		snippet.Language = languages.Detect(r.URL.Query().Get("filename"), snippet.Code)
+	}
+	if err = h.validateSnippet(&snippet); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	ownerID, ok := h.snippetOwner(w, userID, id, true)
+	if !ok {
+		return
+	}
+	// Users a folder is shared with cannot move its snippets elsewhere
+	if ownerID != userID {
+		current, err := h.storage.Get(ownerID, id)
+		if err != nil {
+			http.Error(w, "Failed to retrieve snippet: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		snippet.FolderID = current.FolderID
+	}
 	snippet.ID = id
+	snippet.UserID = ownerID
 	err = h.storage.Update(snippet)
 	if err != nil {
-		if err.Error() == "snippet not found" {
+		if errors.Is(err, database.ErrSnippetNotFound) {
 			http.Error(w, "Snippet not found", http.StatusNotFound)
+		} else if errors.Is(err, database.ErrFolderNotFound) {
+			http.Error(w, "Folder not found", http.StatusNotFound)
 		} else {
 			http.Error(w, "Failed to update snippet: "+err.Error(), http.StatusInternalServerError)
 		}
@@ -218,10 +556,21 @@ func (h *SnippetHandler) updateSnippet(w http.ResponseWriter, r *http.Request, i
 	json.NewEncoder(w).Encode(snippet)
 }
 
-func (h *SnippetHandler) deleteSnippet(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
-	err := h.storage.Delete(id)
+// deleteSnippet removes a snippet
+//
+//	@Summary	Delete a snippet
+//	@Tags		snippets
+//	@Security	BearerAuth
+//	@Param		id	path	string	true	"Snippet ID"	Format(uuid)
+//	@Success	204
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/snippets/{id} [delete]
+func (h *SnippetHandler) deleteSnippet(w http.ResponseWriter, r *http.Request, userID, id uuid.UUID) {
+	err := h.storage.Delete(userID, id)
 	if err != nil {
-		if err.Error() == "snippet not found" {
+		if errors.Is(err, database.ErrSnippetNotFound) {
 			http.Error(w, "Snippet not found", http.StatusNotFound)
 		} else {
 			http.Error(w, "Failed to delete snippet: "+err.Error(), http.StatusInternalServerError)
@@ -233,8 +582,17 @@ func (h *SnippetHandler) deleteSnippet(w http.ResponseWriter, r *http.Request, i
 
 // New handlers for tag and folder operations
 
+// HandleTags serves /tags/{snippetID}/{tag}, /tags/merge and /tags/{name}
 func (h *SnippetHandler) HandleTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
 	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) == 3 && parts[2] != "" {
+		h.handleUserTag(w, r, userID, parts[2])
+		return
+	}
 	if len(parts) < 4 {
 		http.Error(w, "Invalid URL", http.StatusBadRequest)
 		return
@@ -248,60 +606,117 @@ func (h *SnippetHandler) HandleTags(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodPost:
-		h.addTag(w, r, snippetID, tagName)
+		h.addTag(w, r, userID, snippetID, tagName)
 	case http.MethodDelete:
-		h.removeTag(w, r, snippetID, tagName)
+		h.removeTag(w, r, userID, snippetID, tagName)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
+// addTag attaches a tag to a snippet
+//
+//	@Summary	Tag a snippet
+//	@Tags		tags
+//	@Security	BearerAuth
+//	@Param		snippetID	path	string	true	"Snippet ID"	Format(uuid)
+//	@Param		tag			path	string	true	"Tag name"
+//	@Success	201
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/tags/{snippetID}/{tag} [post]
 func (h *SnippetHandler) addTag(
 	w http.ResponseWriter,
 	r *http.Request,
-	snippetID uuid.UUID,
+	userID, snippetID uuid.UUID,
 	tagName string,
 ) {
-	err := h.storage.AddTag(snippetID, tagName)
+	err := h.storage.AddTag(userID, snippetID, tagName)
 	if err != nil {
-		http.Error(w, "Failed to add tag: "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, database.ErrSnippetNotFound) {
+			http.Error(w, "Snippet not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to add tag: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 }
 
+// removeTag detaches a tag from a snippet
+//
+//	@Summary	Remove a tag from a snippet
+//	@Tags		tags
+//	@Security	BearerAuth
+//	@Param		snippetID	path	string	true	"Snippet ID"	Format(uuid)
+//	@Param		tag			path	string	true	"Tag name"
+//	@Success	204
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/tags/{snippetID}/{tag} [delete]
 func (h *SnippetHandler) removeTag(
 	w http.ResponseWriter,
 	r *http.Request,
-	snippetID uuid.UUID,
+	userID, snippetID uuid.UUID,
 	tagName string,
 ) {
-	err := h.storage.RemoveTag(snippetID, tagName)
+	err := h.storage.RemoveTag(userID, snippetID, tagName)
 	if err != nil {
-		http.Error(w, "Failed to remove tag: "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, database.ErrSnippetNotFound) {
+			http.Error(w, "Snippet not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to remove tag: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *SnippetHandler) HandleFolders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
 	switch r.Method {
 	case http.MethodPost:
-		h.createFolder(w, r)
+		h.createFolder(w, r, userID)
 	case http.MethodGet:
-		h.getFolderContents(w, r)
+		h.getFolderContents(w, r, userID)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
+// HandleUserFolders lists every folder of the current user
+//
+//	@Summary	List a user's folders
+//	@Tags		folders
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		userID	path		string	true	"ID of the current user, or of the workspace in X-Workspace-ID"	Format(uuid)
+//	@Success	200		{array}		models.Folder
+//	@Failure	400		{string}	string
+//	@Failure	401		{string}	string
+//	@Failure	404		{string}	string
+//	@Router		/folders/user/{userID} [get]
 func (h *SnippetHandler) HandleUserFolders(w http.ResponseWriter, r *http.Request) {
+	ownerID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
 	userIDStr := strings.TrimPrefix(r.URL.Path, "/folders/user/")
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
+	// Folders of other users are reported as missing rather than forbidden
+	if userID != ownerID {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 
 	folders, err := h.storage.GetFoldersByUser(userID)
 	if err != nil {
@@ -313,7 +728,20 @@ func (h *SnippetHandler) HandleUserFolders(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(folders)
 }
 
-func (h *SnippetHandler) createFolder(w http.ResponseWriter, r *http.Request) {
+// createFolder creates a folder, optionally inside another
+//
+//	@Summary	Create a folder
+//	@Tags		folders
+//	@Accept		json
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		folder	body		models.Folder	true	"Folder name and optional parent_id"
+//	@Success	201		{object}	models.Folder
+//	@Failure	400		{string}	string
+//	@Failure	401		{string}	string
+//	@Failure	404		{string}	string	"Parent folder not found"
+//	@Router		/folders [post]
+func (h *SnippetHandler) createFolder(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
 	var folder models.Folder
 	err := json.NewDecoder(r.Body).Decode(&folder)
 	if err != nil {
@@ -321,9 +749,14 @@ func (h *SnippetHandler) createFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	folder.ID = uuid.New()
+	folder.UserID = userID
 	err = h.storage.CreateFolder(folder)
 	if err != nil {
-		http.Error(w, "Failed to create folder: "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, database.ErrFolderNotFound) {
+			http.Error(w, "Parent folder not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to create folder: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -331,7 +764,20 @@ func (h *SnippetHandler) createFolder(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(folder)
 }
 
-func (h *SnippetHandler) getFolderContents(w http.ResponseWriter, r *http.Request) {
+// getFolderContents returns the snippets and subfolders directly inside a folder
+//
+//	@Summary		Get the snippets and subfolders of a folder
+//	@Description	Works on the caller's folders and on folders shared with them, along with the folders below.
+//	@Tags			folders
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	query		string	true	"Folder ID"	Format(uuid)
+//	@Success		200	{object}	models.FolderContents
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		404	{string}	string
+//	@Router			/folders [get]
+func (h *SnippetHandler) getFolderContents(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
 	folderIDStr := r.URL.Query().Get("id")
 	folderID, err := uuid.Parse(folderIDStr)
 	if err != nil {
@@ -339,16 +785,21 @@ func (h *SnippetHandler) getFolderContents(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	snippets, folders, err := h.storage.GetFolderContents(folderID)
+	ownerID, ok := h.folderOwner(w, userID, folderID)
+	if !ok {
+		return
+	}
+	snippets, folders, err := h.storage.GetFolderContents(ownerID, folderID)
 	if err != nil {
-		http.Error(w, "Failed to get folder contents: "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, database.ErrFolderNotFound) {
+			http.Error(w, "Folder not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get folder contents: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	response := struct {
-		Snippets []models.Snippet `json:"snippets"`
-		Folders  []models.Folder  `json:"folders"`
-	}{
+	response := models.FolderContents{
 		Snippets: snippets,
 		Folders:  folders,
 	}
@@ -357,28 +808,43 @@ func (h *SnippetHandler) getFolderContents(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
-func validateSnippet(s *models.Snippet) error {
+// currentUser returns the authenticated user ID stored by middleware.JWTAuth,
+// writing a 401 response when it is missing
+func currentUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}
+	return userID, ok
+}
+
+func (h *SnippetHandler) validateSnippet(s *models.Snippet) error {
 	if strings.TrimSpace(s.Title) == "" {
 		return errors.New("title cannot be empty")
 	}
-	if len(s.Title) > 100 {
-		return errors.New("title cannot exceed 100 characters")
+	if len(s.Title) > h.limits.MaxTitleLength {
+		return fmt.Errorf("title cannot exceed %d characters", h.limits.MaxTitleLength)
 	}
 	if strings.TrimSpace(s.Code) == "" {
 		return errors.New("code cannot be empty")
 	}
-	if len(s.Code) > 10000 {
-		return errors.New("code cannot exceed 10000 characters")
+	if len(s.Code) > h.limits.MaxCodeLength {
+		return fmt.Errorf("code cannot exceed %d characters", h.limits.MaxCodeLength)
 	}
+	// The language is inferred from the code when left out
 	if strings.TrimSpace(s.Language) == "" {
-		return errors.New("language cannot be empty")
+		s.Language = languages.Guess(s.Code)
+	}
+	if s.Language == "" {
+		s.Language = languages.Text
 	}
+	s.Language = languages.Normalize(s.Language)
 	for _, tag := range s.Tags {
 		if strings.TrimSpace(tag) == "" {
 			return errors.New("tags cannot be empty")
 		}
-		if len(tag) > 50 {
-			return errors.New("tag cannot exceed 50 characters")
+		if len(tag) > h.limits.MaxTagLength {
+			return fmt.Errorf("tag cannot exceed %d characters", h.limits.MaxTagLength)
 		}
 	}
 	return nil
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/database"
+)
+
+// tagRename is the body of PATCH /tags/{name}
+type tagRename struct {
+	Name string `json:"name"`
+}
+
+// tagMerge is the body of POST /tags/merge
+type tagMerge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// HandleTagList serves /tags
+func (h *SnippetHandler) HandleTagList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentOwner(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.listTags(w, r, userID)
+}
+
+// handleUserTag serves /tags/merge and /tags/{name}
+func (h *SnippetHandler) handleUserTag(w http.ResponseWriter, r *http.Request, userID uuid.UUID, name string) {
+	if name == "merge" && r.Method == http.MethodPost {
+		h.mergeTags(w, r, userID)
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		h.renameTag(w, r, userID, name)
+	case http.MethodDelete:
+		h.deleteTag(w, r, userID, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listTags returns the caller's tags
+//
+//	@Summary		List tags
+//	@Description	Returns the caller's tags ordered by name, each with the number of snippets carrying it.
+//	@Tags			tags
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		models.Tag
+//	@Failure		401	{string}	string
+//	@Router			/tags [get]
+func (h *SnippetHandler) listTags(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	tags, err := h.storage.ListTags(userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve tags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// renameTag renames a tag on every snippet carrying it
+//
+//	@Summary		Rename a tag
+//	@Description	Renaming a tag to the name of another tag fails; merge them instead.
+//	@Tags			tags
+//	@Accept			json
+//	@Security		BearerAuth
+//	@Param			name	path		string		true	"Tag name"
+//	@Param			tag		body		tagRename	true	"New name"
+//	@Success		204
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		404	{string}	string
+//	@Failure		409	{string}	string
+//	@Router			/tags/{name} [patch]
+func (h *SnippetHandler) renameTag(w http.ResponseWriter, r *http.Request, userID uuid.UUID, name string) {
+	var rename tagRename
+	if err := json.NewDecoder(r.Body).Decode(&rename); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validateTagName(rename.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.RenameTag(userID, name, rename.Name); err != nil {
+		writeTagError(w, "Failed to rename tag", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mergeTags replaces one tag with another on every snippet
+//
+//	@Summary		Merge two tags
+//	@Description	Puts the target tag on every snippet carrying the source tag, then deletes the source tag. Both tags must exist.
+//	@Tags			tags
+//	@Accept			json
+//	@Security		BearerAuth
+//	@Param			merge	body	tagMerge	true	"Tags to merge"
+//	@Success		204
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		404	{string}	string
+//	@Router			/tags/merge [post]
+func (h *SnippetHandler) mergeTags(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	var merge tagMerge
+	if err := json.NewDecoder(r.Body).Decode(&merge); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if merge.Source == "" || merge.Target == "" {
+		http.Error(w, "Source and target tags are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.MergeTags(userID, merge.Source, merge.Target); err != nil {
+		writeTagError(w, "Failed to merge tags", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteTag removes a tag from every snippet and deletes it
+//
+//	@Summary	Delete a tag
+//	@Tags		tags
+//	@Security	BearerAuth
+//	@Param		name	path	string	true	"Tag name"
+//	@Success	204
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/tags/{name} [delete]
+func (h *SnippetHandler) deleteTag(w http.ResponseWriter, r *http.Request, userID uuid.UUID, name string) {
+	if err := h.storage.DeleteTag(userID, name); err != nil {
+		writeTagError(w, "Failed to delete tag", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SnippetHandler) validateTagName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("tag cannot be empty")
+	}
+	if strings.Contains(name, "/") {
+		return errors.New("tag cannot contain /")
+	}
+	if len(name) > h.limits.MaxTagLength {
+		return fmt.Errorf("tag cannot exceed %d characters", h.limits.MaxTagLength)
+	}
+	return nil
+}
+
+func writeTagError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, database.ErrTagNotFound):
+		http.Error(w, "Tag not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrTagExists):
+		http.Error(w, "A tag with this name already exists", http.StatusConflict)
+	default:
+		http.Error(w, msg+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
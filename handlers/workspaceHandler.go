@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/database"
+	"snippet-manager-go/models"
+)
+
+// workspaceHeader selects the workspace whose library a request works on.
+// Without it, requests work on the caller's own library.
+const workspaceHeader = "X-Workspace-ID"
+
+// memberInvite is the body of POST /workspaces/{id}/members
+type memberInvite struct {
+	// User is the username or the email of the user to add
+	User string      `json:"user"`
+	Role models.Role `json:"role"`
+}
+
+// memberUpdate is the body of PATCH /workspaces/{id}/members/{userID}
+type memberUpdate struct {
+	Role models.Role `json:"role"`
+}
+
+// currentOwner returns the ID owning the library a request works on: the
+// caller's, or the workspace named by the X-Workspace-ID header. Viewers of
+// a workspace may only read it.
+func (h *SnippetHandler) currentOwner(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return uuid.Nil, false
+	}
+	header := r.Header.Get(workspaceHeader)
+	if header == "" {
+		return userID, true
+	}
+	workspaceID, err := uuid.Parse(header)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	workspace, err := h.storage.GetWorkspace(userID, workspaceID)
+	if err != nil {
+		writeWorkspaceError(w, "Failed to retrieve workspace", err)
+		return uuid.Nil, false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && !workspace.Role.CanEdit() {
+		http.Error(w, "Viewers cannot change the workspace", http.StatusForbidden)
+		return uuid.Nil, false
+	}
+	return workspaceID, true
+}
+
+// HandleWorkspaces serves /workspaces
+func (h *SnippetHandler) HandleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.listWorkspaces(w, r, userID)
+	case http.MethodPost:
+		h.createWorkspace(w, r, userID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleWorkspace serves /workspaces/{id}, /workspaces/{id}/members and
+// /workspaces/{id}/members/{userID}
+func (h *SnippetHandler) HandleWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	idStr, subPath, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/workspaces/"), "/")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	workspace, err := h.storage.GetWorkspace(userID, id)
+	if err != nil {
+		writeWorkspaceError(w, "Failed to retrieve workspace", err)
+		return
+	}
+
+	resource, memberIDStr, _ := strings.Cut(subPath, "/")
+	switch {
+	case subPath == "":
+		switch r.Method {
+		case http.MethodGet:
+			h.getWorkspace(w, r, workspace)
+		case http.MethodDelete:
+			h.deleteWorkspace(w, r, workspace)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case resource == "members" && memberIDStr == "":
+		switch r.Method {
+		case http.MethodGet:
+			h.listMembers(w, r, workspace)
+		case http.MethodPost:
+			h.addMember(w, r, workspace)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case resource == "members":
+		memberID, err := uuid.Parse(memberIDStr)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			h.updateMember(w, r, workspace, memberID)
+		case http.MethodDelete:
+			h.removeMember(w, r, userID, workspace, memberID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// listWorkspaces returns the workspaces the caller is a member of
+//
+//	@Summary		List workspaces
+//	@Description	Returns the workspaces the caller is a member of, by name, with the caller's role in each. Send the ID of one in the X-Workspace-ID header to work on its library with the other endpoints.
+//	@Tags			workspaces
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		models.Workspace
+//	@Failure		401	{string}	string
+//	@Router			/workspaces [get]
+func (h *SnippetHandler) listWorkspaces(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	workspaces, err := h.storage.ListWorkspaces(userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve workspaces: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspaces)
+}
+
+// createWorkspace creates a workspace owned by the caller
+//
+//	@Summary	Create a workspace
+//	@Tags		workspaces
+//	@Accept		json
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		workspace	body		models.Workspace	true	"Workspace name"
+//	@Success	201			{object}	models.Workspace
+//	@Failure	400			{string}	string
+//	@Failure	401			{string}	string
+//	@Router		/workspaces [post]
+func (h *SnippetHandler) createWorkspace(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	var input models.Workspace
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		http.Error(w, "Workspace name cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if len(name) > h.limits.MaxTitleLength {
+		http.Error(w, fmt.Sprintf("Workspace name cannot exceed %d characters", h.limits.MaxTitleLength), http.StatusBadRequest)
+		return
+	}
+
+	workspace := models.Workspace{Name: name}
+	if err := h.storage.CreateWorkspace(&workspace, userID); err != nil {
+		http.Error(w, "Failed to create workspace: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(workspace)
+}
+
+// getWorkspace returns a workspace with the caller's role in it
+//
+//	@Summary	Get a workspace
+//	@Tags		workspaces
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id	path		string	true	"Workspace ID"	Format(uuid)
+//	@Success	200	{object}	models.Workspace
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/workspaces/{id} [get]
+func (h *SnippetHandler) getWorkspace(w http.ResponseWriter, r *http.Request, workspace models.Workspace) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspace)
+}
+
+// deleteWorkspace deletes a workspace with its library
+//
+//	@Summary		Delete a workspace
+//	@Description	Deletes the workspace along with every folder and snippet in it. Only owners may delete it.
+//	@Tags			workspaces
+//	@Security		BearerAuth
+//	@Param			id	path	string	true	"Workspace ID"	Format(uuid)
+//	@Success		204
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		403	{string}	string
+//	@Failure		404	{string}	string
+//	@Router			/workspaces/{id} [delete]
+func (h *SnippetHandler) deleteWorkspace(w http.ResponseWriter, r *http.Request, workspace models.Workspace) {
+	if !requireOwner(w, workspace) {
+		return
+	}
+	if err := h.storage.DeleteWorkspace(workspace.ID); err != nil {
+		writeWorkspaceError(w, "Failed to delete workspace", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listMembers returns the members of a workspace
+//
+//	@Summary	List the members of a workspace
+//	@Tags		workspaces
+//	@Produce	json
+//	@Security	BearerAuth
+//	@Param		id	path		string	true	"Workspace ID"	Format(uuid)
+//	@Success	200	{array}		models.WorkspaceMember
+//	@Failure	400	{string}	string
+//	@Failure	401	{string}	string
+//	@Failure	404	{string}	string
+//	@Router		/workspaces/{id}/members [get]
+func (h *SnippetHandler) listMembers(w http.ResponseWriter, r *http.Request, workspace models.Workspace) {
+	members, err := h.storage.ListMembers(workspace.ID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve members: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// addMember adds a user to a workspace
+//
+//	@Summary		Add a member to a workspace
+//	@Description	Adds a user, found by username or email, with the given role. Only owners may add members.
+//	@Tags			workspaces
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		string			true	"Workspace ID"	Format(uuid)
+//	@Param			member	body		memberInvite	true	"User and role"
+//	@Success		201		{object}	models.WorkspaceMember
+//	@Failure		400		{string}	string
+//	@Failure		401		{string}	string
+//	@Failure		403		{string}	string
+//	@Failure		404		{string}	string
+//	@Failure		409		{string}	string
+//	@Router			/workspaces/{id}/members [post]
+func (h *SnippetHandler) addMember(w http.ResponseWriter, r *http.Request, workspace models.Workspace) {
+	if !requireOwner(w, workspace) {
+		return
+	}
+	var invite memberInvite
+	if err := json.NewDecoder(r.Body).Decode(&invite); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !invite.Role.Valid() {
+		http.Error(w, "Role must be owner, editor or viewer", http.StatusBadRequest)
+		return
+	}
+	login := strings.TrimSpace(invite.User)
+	if login == "" {
+		http.Error(w, "Username or email is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.findUser(login)
+	if err != nil {
+		writeWorkspaceError(w, "Failed to find user", err)
+		return
+	}
+	member := models.WorkspaceMember{UserID: user.ID, Username: user.Username, Role: invite.Role}
+	if err := h.storage.AddMember(workspace.ID, &member); err != nil {
+		writeWorkspaceError(w, "Failed to add member", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+// updateMember changes the role of a member
+//
+//	@Summary		Change the role of a member
+//	@Description	Only owners may change roles. The last owner of a workspace cannot step down.
+//	@Tags			workspaces
+//	@Accept			json
+//	@Security		BearerAuth
+//	@Param			id		path	string			true	"Workspace ID"	Format(uuid)
+//	@Param			userID	path	string			true	"User ID of the member"	Format(uuid)
+//	@Param			member	body	memberUpdate	true	"New role"
+//	@Success		204
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		403	{string}	string
+//	@Failure		404	{string}	string
+//	@Failure		409	{string}	string
+//	@Router			/workspaces/{id}/members/{userID} [patch]
+func (h *SnippetHandler) updateMember(w http.ResponseWriter, r *http.Request, workspace models.Workspace, memberID uuid.UUID) {
+	if !requireOwner(w, workspace) {
+		return
+	}
+	var update memberUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !update.Role.Valid() {
+		http.Error(w, "Role must be owner, editor or viewer", http.StatusBadRequest)
+		return
+	}
+	if err := h.storage.SetMemberRole(workspace.ID, memberID, update.Role); err != nil {
+		writeWorkspaceError(w, "Failed to change role", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeMember removes a member from a workspace
+//
+//	@Summary		Remove a member from a workspace
+//	@Description	Owners may remove anyone; other members may only remove themselves, to leave the workspace. The last owner cannot leave.
+//	@Tags			workspaces
+//	@Security		BearerAuth
+//	@Param			id		path	string	true	"Workspace ID"	Format(uuid)
+//	@Param			userID	path	string	true	"User ID of the member"	Format(uuid)
+//	@Success		204
+//	@Failure		400	{string}	string
+//	@Failure		401	{string}	string
+//	@Failure		403	{string}	string
+//	@Failure		404	{string}	string
+//	@Failure		409	{string}	string
+//	@Router			/workspaces/{id}/members/{userID} [delete]
+func (h *SnippetHandler) removeMember(w http.ResponseWriter, r *http.Request, userID uuid.UUID, workspace models.Workspace, memberID uuid.UUID) {
+	if memberID != userID && !requireOwner(w, workspace) {
+		return
+	}
+	if err := h.storage.RemoveMember(workspace.ID, memberID); err != nil {
+		writeWorkspaceError(w, "Failed to remove member", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findUser looks a user up by email if login holds an @, by username
+// otherwise
+func (h *SnippetHandler) findUser(login string) (*models.User, error) {
+	if strings.Contains(login, "@") {
+		return h.storage.GetUserByEmail(login)
+	}
+	return h.storage.GetUserByUsername(login)
+}
+
+// requireOwner writes a 403 response unless the caller owns the workspace
+func requireOwner(w http.ResponseWriter, workspace models.Workspace) bool {
+	if workspace.Role != models.RoleOwner {
+		http.Error(w, "Only owners can manage the workspace", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func writeWorkspaceError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, database.ErrWorkspaceNotFound):
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrMemberNotFound):
+		http.Error(w, "Member not found", http.StatusNotFound)
+	case errors.Is(err, database.ErrMemberExists):
+		http.Error(w, "User is already a member of the workspace", http.StatusConflict)
+	case errors.Is(err, database.ErrLastOwner):
+		http.Error(w, "A workspace must keep at least one owner", http.StatusConflict)
+	default:
+		http.Error(w, msg+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
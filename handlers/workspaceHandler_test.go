@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"snippet-manager-go/config"
+	"snippet-manager-go/models"
+)
+
+// workspaceFixture is a workspace owned by alice, with bob as an editor and
+// carol as a viewer. dave is not a member.
+type workspaceFixture struct {
+	srv                     *testServer
+	alice, bob, carol, dave testUser
+	id                      uuid.UUID
+	// header selects the workspace in requests
+	header []string
+}
+
+func newWorkspaceFixture(t *testing.T) *workspaceFixture {
+	t.Helper()
+	srv := newTestServer(t, config.Default().Limits)
+	f := &workspaceFixture{
+		srv:   srv,
+		alice: srv.signUp("alice"),
+		bob:   srv.signUp("bob"),
+		carol: srv.signUp("carol"),
+		dave:  srv.signUp("dave"),
+	}
+	rec := srv.do(http.MethodPost, "/workspaces", f.alice.Token, models.Workspace{Name: "team"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create workspace: %d %s", rec.Code, rec.Body)
+	}
+	var workspace models.Workspace
+	decodeBody(t, rec, &workspace)
+	f.id = workspace.ID
+	f.header = []string{"X-Workspace-ID", f.id.String()}
+
+	members := "/workspaces/" + f.id.String() + "/members"
+	for user, role := range map[string]models.Role{"bob": models.RoleEditor, "carol": models.RoleViewer} {
+		rec := srv.do(http.MethodPost, members, f.alice.Token, map[string]any{"user": user, "role": role})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("add %s: %d %s", user, rec.Code, rec.Body)
+		}
+	}
+	return f
+}
+
+func TestWorkspaceLibraryRoles(t *testing.T) {
+	f := newWorkspaceFixture(t)
+	srv := f.srv
+	snippet := srv.createSnippet(f.bob.Token, models.Snippet{Title: "shared", Language: "go", Code: "package team"}, f.header...)
+	path := "/snippets/" + snippet.ID.String()
+
+	var page models.SnippetPage
+	rec := srv.do(http.MethodGet, "/snippets", f.carol.Token, nil, f.header...)
+	assertStatus(t, "viewer lists", rec, http.StatusOK)
+	decodeBody(t, rec, &page)
+	if len(page.Snippets) != 1 || page.Snippets[0].ID != snippet.ID {
+		t.Errorf("viewer sees %+v, want the snippet of the workspace", page.Snippets)
+	}
+	assertStatus(t, "viewer reads", srv.do(http.MethodGet, path, f.carol.Token, nil, f.header...), http.StatusOK)
+
+	// Viewers cannot change anything in the library
+	writes := []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodPost, "/snippets", models.Snippet{Title: "x", Language: "go", Code: "x"}},
+		{http.MethodPut, path, models.Snippet{Title: "x", Language: "go", Code: "x"}},
+		{http.MethodDelete, path, nil},
+		{http.MethodPost, "/folders", models.Folder{Name: "x"}},
+		{http.MethodPost, path + "/revisions/1/restore", nil},
+	}
+	for _, w := range writes {
+		assertStatus(t, "viewer "+w.method+" "+w.path, srv.do(w.method, w.path, f.carol.Token, w.body, f.header...), http.StatusForbidden)
+	}
+	assertStatus(t, "snippet after viewer writes", srv.do(http.MethodGet, path, f.alice.Token, nil, f.header...), http.StatusOK)
+
+	// Editors can
+	snippet.Code = "package team2"
+	assertStatus(t, "editor updates", srv.do(http.MethodPut, path, f.bob.Token, snippet, f.header...), http.StatusOK)
+
+	// Non-members cannot see the workspace at all
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rec := srv.do(method, "/snippets", f.dave.Token, models.Snippet{Title: "x", Language: "go", Code: "x"}, f.header...)
+		assertStatus(t, "non-member "+method, rec, http.StatusNotFound)
+	}
+	assertStatus(t, "non-member reads", srv.do(http.MethodGet, path, f.dave.Token, nil, f.header...), http.StatusNotFound)
+	assertStatus(t, "invalid workspace ID", srv.do(http.MethodGet, "/snippets", f.bob.Token, nil, "X-Workspace-ID", "team"), http.StatusBadRequest)
+
+	// The snippet is not in the personal library of its author
+	rec = srv.do(http.MethodGet, path, f.bob.Token, nil)
+	assertStatus(t, "read without the workspace header", rec, http.StatusNotFound)
+}
+
+func TestWorkspaceMemberManagement(t *testing.T) {
+	f := newWorkspaceFixture(t)
+	srv := f.srv
+	workspace := "/workspaces/" + f.id.String()
+	member := func(u testUser) string { return workspace + "/members/" + u.ID.String() }
+
+	// Only owners manage members and delete the workspace
+	for _, u := range []testUser{f.bob, f.carol} {
+		assertStatus(t, u.Name+" adds a member", srv.do(http.MethodPost, workspace+"/members", u.Token, map[string]any{"user": "dave", "role": "viewer"}), http.StatusForbidden)
+		assertStatus(t, u.Name+" changes a role", srv.do(http.MethodPatch, member(f.carol), u.Token, map[string]any{"role": "owner"}), http.StatusForbidden)
+		assertStatus(t, u.Name+" removes the owner", srv.do(http.MethodDelete, member(f.alice), u.Token, nil), http.StatusForbidden)
+		assertStatus(t, u.Name+" deletes the workspace", srv.do(http.MethodDelete, workspace, u.Token, nil), http.StatusForbidden)
+	}
+	assertStatus(t, "non-member reads the workspace", srv.do(http.MethodGet, workspace, f.dave.Token, nil), http.StatusNotFound)
+	assertStatus(t, "non-member lists members", srv.do(http.MethodGet, workspace+"/members", f.dave.Token, nil), http.StatusNotFound)
+
+	// Members may leave on their own
+	assertStatus(t, "viewer leaves", srv.do(http.MethodDelete, member(f.carol), f.carol.Token, nil), http.StatusNoContent)
+	assertStatus(t, "former viewer reads", srv.do(http.MethodGet, "/snippets", f.carol.Token, nil, f.header...), http.StatusNotFound)
+
+	// The last owner can neither leave nor step down
+	assertStatus(t, "last owner steps down", srv.do(http.MethodPatch, member(f.alice), f.alice.Token, map[string]any{"role": "editor"}), http.StatusConflict)
+	assertStatus(t, "last owner leaves", srv.do(http.MethodDelete, member(f.alice), f.alice.Token, nil), http.StatusConflict)
+	assertStatus(t, "invalid role", srv.do(http.MethodPatch, member(f.bob), f.alice.Token, map[string]any{"role": "admin"}), http.StatusBadRequest)
+
+	// Once there is another owner, the first one can go
+	assertStatus(t, "promote editor", srv.do(http.MethodPatch, member(f.bob), f.alice.Token, map[string]any{"role": "owner"}), http.StatusNoContent)
+	assertStatus(t, "owner steps down", srv.do(http.MethodPatch, member(f.alice), f.alice.Token, map[string]any{"role": "viewer"}), http.StatusNoContent)
+	assertStatus(t, "former owner adds a member", srv.do(http.MethodPost, workspace+"/members", f.alice.Token, map[string]any{"user": "dave", "role": "viewer"}), http.StatusForbidden)
+	assertStatus(t, "new owner deletes the workspace", srv.do(http.MethodDelete, workspace, f.bob.Token, nil), http.StatusNoContent)
+	assertStatus(t, "deleted workspace", srv.do(http.MethodGet, "/snippets", f.bob.Token, nil, f.header...), http.StatusNotFound)
+}
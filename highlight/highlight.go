@@ -0,0 +1,131 @@
+// Package highlight renders code with syntax highlighting, as HTML or as
+// text colored with ANSI escape codes, using the lexers and themes of
+// chroma.
+package highlight
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// DefaultTheme is the theme used when none is given
+const DefaultTheme = "github"
+
+// ErrUnknownTheme is returned for themes chroma does not have
+var ErrUnknownTheme = errors.New("unknown theme")
+
+type Options struct {
+	// Theme is the name of a chroma style; see Themes
+	Theme       string
+	LineNumbers bool
+}
+
+// Themes returns the names of the available themes, sorted
+func Themes() []string {
+	return styles.Names()
+}
+
+// style returns the chroma style of a theme
+func (o Options) style() (*chroma.Style, error) {
+	if o.Theme == "" {
+		return styles.Get(DefaultTheme), nil
+	}
+	style, ok := styles.Registry[strings.ToLower(o.Theme)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTheme, o.Theme)
+	}
+	return style, nil
+}
+
+// Lexer returns the lexer for a language, falling back to guessing from the
+// code and then to plain text
+func Lexer(language, code string) chroma.Lexer {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// HTML writes code as a <pre> element styled inline, so that it needs no
+// stylesheet
+func HTML(w io.Writer, language, code string, opts Options) error {
+	style, err := opts.style()
+	if err != nil {
+		return err
+	}
+	iterator, err := Lexer(language, code).Tokenise(nil, code)
+	if err != nil {
+		return err
+	}
+	formatter := html.New(
+		html.WithLineNumbers(opts.LineNumbers),
+		html.LineNumbersInTable(opts.LineNumbers),
+		html.TabWidth(4),
+	)
+	return formatter.Format(w, style, iterator)
+}
+
+// HasTheme reports whether a theme exists
+func HasTheme(theme string) bool {
+	_, ok := styles.Registry[strings.ToLower(theme)]
+	return ok
+}
+
+// Colors returns the CSS background and text colors of a theme, for pages
+// that embed highlighted code. Unknown themes get those of DefaultTheme.
+func Colors(theme string) (string, string) {
+	style, err := Options{Theme: theme}.style()
+	if err != nil {
+		style = styles.Get(DefaultTheme)
+	}
+	entry := style.Get(chroma.Background)
+	background, foreground := "#ffffff", "#000000"
+	if entry.Background.IsSet() {
+		background = entry.Background.String()
+	}
+	if entry.Colour.IsSet() {
+		foreground = entry.Colour.String()
+	}
+	return background, foreground
+}
+
+// ANSI writes code colored with the escape codes of 256-color terminals
+func ANSI(w io.Writer, language, code string, opts Options) error {
+	style, err := opts.style()
+	if err != nil {
+		return err
+	}
+	iterator, err := Lexer(language, code).Tokenise(nil, code)
+	if err != nil {
+		return err
+	}
+	if !opts.LineNumbers {
+		return formatters.TTY256.Format(w, style, iterator)
+	}
+
+	// Each line is formatted on its own so that its colors are reset
+	// before the number of the next line
+	lines := chroma.SplitTokensIntoLines(iterator.Tokens())
+	width := len(fmt.Sprint(len(lines)))
+	for i, line := range lines {
+		if _, err := fmt.Fprintf(w, "\x1b[38;5;245m%*d\x1b[0m  ", width, i+1); err != nil {
+			return err
+		}
+		if err := formatters.TTY256.Format(w, style, chroma.Literator(line...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package highlight
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	const code = "package main\n\nfunc main() {\n\tprintln(\"<b>hi</b>\")\n}\n"
+	type renderer func(io.Writer, string, string, Options) error
+
+	tests := []struct {
+		name     string
+		render   renderer
+		language string
+		opts     Options
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "html",
+			render:   HTML,
+			language: "go",
+			contains: []string{"<pre", "style=", "&lt;b&gt;hi&lt;/b&gt;", "main"},
+			excludes: []string{"<b>hi</b>"},
+		},
+		{
+			name:     "html with line numbers",
+			render:   HTML,
+			language: "go",
+			opts:     Options{Theme: "Monokai", LineNumbers: true},
+			contains: []string{"<table", ">5\n<"},
+		},
+		{
+			name:     "html of an unknown language",
+			render:   HTML,
+			language: "no-such-language",
+			contains: []string{"<pre", "&lt;b&gt;hi"},
+		},
+		{
+			name:     "ansi",
+			render:   ANSI,
+			language: "go",
+			contains: []string{"\x1b[", "package", "<b>hi</b>"},
+		},
+		{
+			name:     "ansi with line numbers",
+			render:   ANSI,
+			language: "go",
+			opts:     Options{LineNumbers: true},
+			contains: []string{"\x1b[38;5;245m1\x1b[0m  ", "\x1b[38;5;245m5\x1b[0m  "},
+		},
+		{
+			name:     "ansi of an unknown language",
+			render:   ANSI,
+			language: "no-such-language",
+			contains: []string{"package main\n", "main() {\n", "<b>hi</b>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.render(&buf, tt.language, code, tt.opts); err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			out := buf.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(out, s) {
+					t.Errorf("output %q does not contain %q", out, s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(out, s) {
+					t.Errorf("output %q contains %q", out, s)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownTheme(t *testing.T) {
+	for name, render := range map[string]func(io.Writer, string, string, Options) error{"html": HTML, "ansi": ANSI} {
+		var buf bytes.Buffer
+		err := render(&buf, "go", "package main", Options{Theme: "no-such-theme"})
+		if !errors.Is(err, ErrUnknownTheme) {
+			t.Errorf("%s: err = %v, want ErrUnknownTheme", name, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: wrote %q for an unknown theme", name, buf.String())
+		}
+	}
+
+	if HasTheme("no-such-theme") || !HasTheme("Monokai") || !HasTheme(DefaultTheme) {
+		t.Errorf("HasTheme does not match the registry")
+	}
+	bg, fg := Colors("no-such-theme")
+	if wantBg, wantFg := Colors(DefaultTheme); bg != wantBg || fg != wantFg {
+		t.Errorf("Colors of an unknown theme = %q, %q, want those of %s: %q, %q", bg, fg, DefaultTheme, wantBg, wantFg)
+	}
+}
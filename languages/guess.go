@@ -0,0 +1,173 @@
+package languages
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// clue is a pattern that hints at a language. The scores of the clues found
+// in some code add up per language.
+type clue struct {
+	language string
+	pattern  *regexp.Regexp
+	score    int
+}
+
+func hint(language string, score int, pattern string) clue {
+	return clue{language, regexp.MustCompile(pattern), score}
+}
+
+// minScore is the score a language needs before Guess trusts it
+const minScore = 3
+
+var clues = []clue{
+	hint("bash", 3, `(?m)^\s*(fi|esac)\s*$`),
+	hint("bash", 2, `(?m)^\s*(if|while) \[\[? `),
+	hint("bash", 2, `(?m)^\s*done\s*$`),
+	hint("bash", 2, `(?m)^export \w+=`),
+	hint("bash", 1, `(?m)^\s*echo `),
+
+	hint("c", 3, `(?m)^#include\s*<\w+\.h>`),
+	hint("c", 1, `\bprintf\(`),
+	hint("c", 1, `\bmalloc\(`),
+	hint("c", 1, `\bint main\(`),
+
+	hint("cpp", 3, `(?m)^#include\s*<\w+>`),
+	hint("cpp", 3, `\bstd::`),
+	hint("cpp", 2, `\bcout\s*<<`),
+	hint("cpp", 2, `(?m)^using namespace \w+;`),
+	hint("cpp", 2, `\btemplate\s*<`),
+
+	hint("csharp", 3, `(?m)^using System[\w.]*;`),
+	hint("csharp", 3, `\bConsole\.Write(Line)?\(`),
+	hint("csharp", 1, `(?m)^\s*namespace [\w.]+`),
+
+	hint("css", 2, `(?m)^\s*(color|margin|padding|display|font-[\w-]+|background(-[\w-]+)?|border(-[\w-]+)?|width|height)\s*:[^;{]+;`),
+	hint("css", 1, `(?m)^\s*[.#]?[\w-]+(\s*[,>+~]?\s*[.#:]?[\w-]+)*\s*\{\s*$`),
+	hint("css", 2, `@media\b`),
+
+	hint("dockerfile", 2, `(?m)^FROM \S+`),
+	hint("dockerfile", 2, `(?m)^(RUN|COPY|CMD|ENTRYPOINT|WORKDIR|EXPOSE) `),
+
+	hint("go", 3, `(?m)^package \w+\s*$`),
+	hint("go", 2, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`),
+	hint("go", 2, `\bfmt\.\w+\(`),
+	hint("go", 1, `\w+ := `),
+	hint("go", 1, `\bif err != nil\b`),
+
+	hint("html", 5, `(?i)<!doctype html`),
+	hint("html", 2, `(?i)<(html|head|body|div|span|p|a|ul|li|table)\b[^>]*>`),
+
+	hint("java", 3, `\bpublic (static )?(final )?(class|void|interface)\b`),
+	hint("java", 3, `\bSystem\.out\.print`),
+	hint("java", 3, `(?m)^import java\.`),
+
+	hint("javascript", 3, `\bconsole\.log\(`),
+	hint("javascript", 2, `\brequire\(['"]`),
+	hint("javascript", 2, `\bdocument\.\w+`),
+	hint("javascript", 1, `\b(const|let) \w+ = `),
+	hint("javascript", 1, `=>`),
+	hint("javascript", 1, `\bfunction\s*\w*\(`),
+
+	hint("kotlin", 3, `\bfun \w+\(`),
+	hint("kotlin", 2, `\bval \w+ = `),
+
+	hint("lua", 2, `\blocal \w+ = `),
+	hint("lua", 1, `~=`),
+	hint("lua", 1, `(?m)^\s*end\s*$`),
+	hint("lua", 1, `\bfunction \w+[.:]?\w*\(`),
+
+	hint("makefile", 3, `(?m)^\.PHONY:`),
+	hint("makefile", 3, `(?m)^[\w.-]+:.*\n\t`),
+
+	hint("markdown", 2, "(?m)^```"),
+	hint("markdown", 2, `\[[^\]]+\]\([^)]+\)`),
+	hint("markdown", 1, `(?m)^#{1,6} \S`),
+	hint("markdown", 1, `(?m)^\s*[-*] \S`),
+
+	hint("php", 5, `<\?php`),
+	hint("php", 1, `\$\w+\s*=`),
+
+	hint("python", 3, `(?m)^\s*def \w+\(.*\)( -> [\w\[\], .]+)?:\s*$`),
+	hint("python", 3, `if __name__ == ['"]__main__['"]:`),
+	hint("python", 2, `(?m)^\s*(elif .*|except.*|else):\s*$`),
+	hint("python", 2, `(?m)^from [\w.]+ import \w+`),
+	hint("python", 1, `\bself\.`),
+	hint("python", 1, `(?m)^import \w+$`),
+
+	hint("ruby", 2, `(?m)^\s*def \w+[?!]?(\(.*\))?\s*$`),
+	hint("ruby", 2, `(?m)^require ['"]`),
+	hint("ruby", 2, `\.each do\b`),
+	hint("ruby", 2, `\bputs\b`),
+	hint("ruby", 1, `(?m)^\s*end\s*$`),
+
+	hint("rust", 3, `\blet mut\b`),
+	hint("rust", 3, `\bprintln!\(`),
+	hint("rust", 2, `\bfn \w+\(`),
+	hint("rust", 2, `(?m)^use \w+::`),
+	hint("rust", 2, `(?m)^\s*impl\b`),
+
+	hint("sql", 3, `(?is)\bselect\b.+\bfrom\b`),
+	hint("sql", 3, `(?i)\b(insert into|create table|alter table|delete from)\b`),
+	hint("sql", 2, `(?i)\bupdate \w+ set\b`),
+
+	hint("swift", 4, `(?m)^import (UIKit|Foundation|SwiftUI)\b`),
+	hint("swift", 2, `\bfunc \w+\(.*\) -> `),
+	hint("swift", 1, `\bvar \w+: \w+`),
+	hint("swift", 1, `\bguard let\b`),
+
+	hint("toml", 2, `(?m)^\[[\w.-]+\]\s*$`),
+	hint("toml", 2, `(?m)^[\w-]+ = ("|\d|true|false|\[)`),
+
+	hint("typescript", 2, `:\s*(string|number|boolean|void)\b`),
+	hint("typescript", 2, `(?m)^\s*(export )?interface \w+ \{`),
+	hint("typescript", 2, `\b(const|let) \w+: \w+`),
+
+	hint("xml", 5, `^<\?xml `),
+
+	hint("yaml", 2, `(?m)^---\s*$`),
+	hint("yaml", 1, `(?m)^\s*- \w+`),
+	hint("yaml", 1, `(?m)^[\w-]+:( |$)`),
+}
+
+// Guess infers the language of code from its content. It returns "" when no
+// language stands out.
+func Guess(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return ""
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	scores := map[string]int{}
+	for _, c := range clues {
+		if c.pattern.MatchString(code) {
+			scores[c.language] += c.score
+		}
+	}
+	// Languages that extend another one win when they match as well, since
+	// every clue of the base language also applies to them
+	if scores["typescript"] > 0 {
+		scores["typescript"] += scores["javascript"]
+	}
+	if scores["cpp"] > 0 && scores["c"] > 0 {
+		scores["cpp"] += scores["c"]
+	}
+
+	best, bestScore, tie := "", 0, false
+	for _, lang := range registry {
+		switch score := scores[lang.Name]; {
+		case score > bestScore:
+			best, bestScore, tie = lang.Name, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if bestScore < minScore || tie {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,39 @@
+package languages
+
+import "testing"
+
+func TestGuess(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"empty", "  \n", ""},
+		{"plain text", "buy milk\ncall mum\n", ""},
+		{"json object", `{"name": "snippet", "tags": ["a"]}`, "json"},
+		{"json array", "[1, 2, 3]", "json"},
+		{"invalid json", `{"name": }`, ""},
+		{"go", "package main\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", "go"},
+		{"python", "def greet(name):\n    print(name)\n\nif __name__ == \"__main__\":\n    greet(\"x\")\n", "python"},
+		{"bash", "for f in *; do\n  echo $f\ndone\nif [ -d x ]; then\n  rm -r x\nfi\n", "bash"},
+		{"c", "#include <stdio.h>\nint main() {\n  printf(\"hi\");\n}\n", "c"},
+		{"cpp over c", "#include <iostream>\n#include <stdio.h>\nint main() {\n  std::cout << 1;\n}\n", "cpp"},
+		{"typescript over javascript", "interface User {\n  name: string\n}\nconst u: User = load()\nconsole.log(u)\n", "typescript"},
+		{"javascript", "const x = require('fs')\nconsole.log(x)\n", "javascript"},
+		{"rust", "fn main() {\n    let mut x = 1;\n    println!(\"{}\", x);\n}\n", "rust"},
+		{"sql", "SELECT id, name FROM users WHERE id = 1;", "sql"},
+		{"php", "<?php echo 'hi'; ?>", "php"},
+		{"xml", "<?xml version=\"1.0\"?>\n<root/>", "xml"},
+		{"html", "<!DOCTYPE html>\n<html><body></body></html>", "html"},
+		{"dockerfile", "FROM golang:1.22\nRUN go build\nCMD [\"/app\"]\n", "dockerfile"},
+		{"makefile", ".PHONY: build\nbuild:\n\tgo build ./...\n", "makefile"},
+		{"weak clue", "x := 1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Guess(tt.code); got != tt.want {
+				t.Errorf("Guess(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
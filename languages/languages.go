@@ -0,0 +1,188 @@
+// Package languages is the registry of the languages snippets are written
+// in. It maps names, aliases, file names and scripts to canonical language
+// names, and is shared by the server and the command line tool.
+package languages
+
+import (
+	"path"
+	"strings"
+)
+
+// Text is the language of snippets whose language is unknown
+const Text = "text"
+
+// Language is a known language. Name is its canonical name; the first
+// extension is the one used when writing snippets to files.
+type Language struct {
+	Name       string
+	Aliases    []string
+	Extensions []string
+	// Filenames are whole file names, such as Makefile, that identify the
+	// language
+	Filenames []string
+	// Interpreters are the programs named in shebang lines of scripts
+	Interpreters []string
+	// MediaType is the MIME type of the raw code, if it has a usual one
+	MediaType string
+}
+
+// registry lists the known languages ordered by name
+var registry = []Language{
+	{Name: "bash", Aliases: []string{"sh", "shell", "zsh"}, Extensions: []string{".sh", ".bash", ".zsh"}, Filenames: []string{".bashrc", ".bash_profile", ".zshrc"}, Interpreters: []string{"bash", "sh", "zsh"}, MediaType: "text/x-shellscript"},
+	{Name: "c", Extensions: []string{".c", ".h"}, MediaType: "text/x-c"},
+	{Name: "cpp", Aliases: []string{"c++", "cplusplus"}, Extensions: []string{".cpp", ".cc", ".cxx", ".hpp", ".hh"}, MediaType: "text/x-c++"},
+	{Name: "csharp", Aliases: []string{"c#", "cs"}, Extensions: []string{".cs"}, MediaType: "text/x-csharp"},
+	{Name: "css", Extensions: []string{".css"}, MediaType: "text/css"},
+	{Name: "dart", Extensions: []string{".dart"}},
+	{Name: "dockerfile", Aliases: []string{"docker"}, Extensions: []string{".dockerfile"}, Filenames: []string{"Dockerfile", "Containerfile"}},
+	{Name: "elixir", Aliases: []string{"ex", "exs"}, Extensions: []string{".ex", ".exs"}, Interpreters: []string{"elixir"}},
+	{Name: "go", Aliases: []string{"golang"}, Extensions: []string{".go"}, MediaType: "text/x-go"},
+	{Name: "haskell", Aliases: []string{"hs"}, Extensions: []string{".hs"}, Interpreters: []string{"runhaskell"}},
+	{Name: "html", Aliases: []string{"htm", "xhtml"}, Extensions: []string{".html", ".htm"}},
+	{Name: "java", Extensions: []string{".java"}, MediaType: "text/x-java"},
+	{Name: "javascript", Aliases: []string{"js", "node", "nodejs"}, Extensions: []string{".js", ".mjs", ".cjs"}, Interpreters: []string{"node"}, MediaType: "text/javascript"},
+	{Name: "json", Extensions: []string{".json"}, MediaType: "application/json"},
+	{Name: "kotlin", Aliases: []string{"kt"}, Extensions: []string{".kt", ".kts"}, MediaType: "text/x-kotlin"},
+	{Name: "lua", Extensions: []string{".lua"}, Interpreters: []string{"lua"}},
+	{Name: "makefile", Aliases: []string{"make"}, Extensions: []string{".mk"}, Filenames: []string{"Makefile", "GNUmakefile"}},
+	{Name: "markdown", Aliases: []string{"md"}, Extensions: []string{".md", ".markdown"}, MediaType: "text/markdown"},
+	{Name: "perl", Aliases: []string{"pl"}, Extensions: []string{".pl", ".pm"}, Interpreters: []string{"perl"}},
+	{Name: "php", Extensions: []string{".php"}, Interpreters: []string{"php"}, MediaType: "text/x-php"},
+	{Name: "powershell", Aliases: []string{"ps1", "pwsh", "posh"}, Extensions: []string{".ps1"}, Interpreters: []string{"pwsh"}},
+	{Name: "python", Aliases: []string{"py", "python3", "py3"}, Extensions: []string{".py"}, Interpreters: []string{"python", "python3"}, MediaType: "text/x-python"},
+	{Name: "r", Extensions: []string{".r"}, Interpreters: []string{"Rscript"}},
+	{Name: "ruby", Aliases: []string{"rb"}, Extensions: []string{".rb"}, Filenames: []string{"Gemfile", "Rakefile"}, Interpreters: []string{"ruby"}, MediaType: "text/x-ruby"},
+	{Name: "rust", Aliases: []string{"rs"}, Extensions: []string{".rs"}, MediaType: "text/x-rust"},
+	{Name: "scala", Extensions: []string{".scala"}},
+	{Name: "sql", Extensions: []string{".sql"}, MediaType: "application/sql"},
+	{Name: "swift", Extensions: []string{".swift"}},
+	{Name: Text, Aliases: []string{"plaintext", "plain", "txt"}, Extensions: []string{".txt"}},
+	{Name: "toml", Extensions: []string{".toml"}, MediaType: "application/toml"},
+	{Name: "typescript", Aliases: []string{"ts"}, Extensions: []string{".ts", ".tsx"}, Interpreters: []string{"deno", "ts-node"}, MediaType: "text/x-typescript"},
+	{Name: "xml", Extensions: []string{".xml", ".xsd", ".svg"}},
+	{Name: "yaml", Aliases: []string{"yml"}, Extensions: []string{".yaml", ".yml"}, MediaType: "application/yaml"},
+}
+
+// Lookup tables built from the registry
+var (
+	byName        = map[string]*Language{}
+	byExtension   = map[string]*Language{}
+	byFilename    = map[string]*Language{}
+	byInterpreter = map[string]*Language{}
+)
+
+func init() {
+	for i := range registry {
+		lang := &registry[i]
+		byName[lang.Name] = lang
+		for _, alias := range lang.Aliases {
+			byName[alias] = lang
+		}
+		for _, ext := range lang.Extensions {
+			byExtension[ext] = lang
+		}
+		for _, name := range lang.Filenames {
+			byFilename[name] = lang
+		}
+		for _, interpreter := range lang.Interpreters {
+			byInterpreter[interpreter] = lang
+		}
+	}
+}
+
+// All returns the known languages ordered by name
+func All() []Language {
+	return append([]Language(nil), registry...)
+}
+
+// Lookup finds a language by its name or one of its aliases, ignoring case
+func Lookup(name string) (Language, bool) {
+	lang, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Language{}, false
+	}
+	return *lang, true
+}
+
+// Normalize returns the canonical name of a language, so that "Go" and
+// "golang" both become "go". Unknown languages are lowercased.
+func Normalize(name string) string {
+	if lang, ok := Lookup(name); ok {
+		return lang.Name
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// FromFilename guesses the language of a file from its name or extension
+func FromFilename(name string) string {
+	base := path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if lang, ok := byFilename[base]; ok {
+		return lang.Name
+	}
+	if lang, ok := byExtension[strings.ToLower(path.Ext(base))]; ok {
+		return lang.Name
+	}
+	return ""
+}
+
+// FromShebang returns the language of the interpreter named on the first
+// line of a script, as in "#!/bin/sh" or "#!/usr/bin/env python3"
+func FromShebang(code string) string {
+	line, _, _ := strings.Cut(code, "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// Skip options such as env -S
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = field
+				break
+			}
+		}
+	}
+	if lang, ok := byInterpreter[interpreter]; ok {
+		return lang.Name
+	}
+	// Versioned interpreters such as python3.12 or ruby3.3
+	if lang, ok := byInterpreter[strings.TrimRight(interpreter, "0123456789.")]; ok {
+		return lang.Name
+	}
+	return ""
+}
+
+// Detect guesses the language of a snippet from its file name, then from its
+// shebang line, then from its content. It returns "" if all of them fail.
+func Detect(name, code string) string {
+	if language := FromFilename(name); language != "" {
+		return language
+	}
+	if language := FromShebang(code); language != "" {
+		return language
+	}
+	return Guess(code)
+}
+
+// MediaType returns the MIME type to serve the code of a language with.
+// Languages without a usual type, including markup that browsers would
+// render, such as HTML, are served as plain text.
+func MediaType(language string) string {
+	if lang, ok := Lookup(language); ok && lang.MediaType != "" {
+		return lang.MediaType
+	}
+	return "text/plain"
+}
+
+// Extension returns the file extension of a language, so that editors pick
+// the right syntax highlighting. Unknown languages get ".txt".
+func Extension(language string) string {
+	if lang, ok := Lookup(language); ok && len(lang.Extensions) > 0 {
+		return lang.Extensions[0]
+	}
+	return ".txt"
+}
@@ -0,0 +1,109 @@
+package languages
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"go", "go"},
+		{"Go", "go"},
+		{" golang ", "go"},
+		{"py3", "python"},
+		{"C++", "cpp"},
+		{"c#", "csharp"},
+		{"yml", "yaml"},
+		{"plaintext", Text},
+		{"Brainfuck", "brainfuck"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.name); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFromFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"main.go", "go"},
+		{"src/lib/util.PY", "python"},
+		{`C:\scripts\deploy.ps1`, "powershell"},
+		{"Dockerfile", "dockerfile"},
+		{"build/Makefile", "makefile"},
+		{".bashrc", "bash"},
+		{"makefile.txt", Text},
+		{"README", ""},
+		{"archive.tar.gz", ""},
+	}
+	for _, tt := range tests {
+		if got := FromFilename(tt.name); got != tt.want {
+			t.Errorf("FromFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFromShebang(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"#!/bin/sh\necho hi", "bash"},
+		{"#!/usr/bin/env python3\nprint()", "python"},
+		{"#!/usr/bin/env -S node --harmony\n", "javascript"},
+		{"#!/usr/local/bin/python3.12", "python"},
+		{"#! /usr/bin/ruby -w\n", "ruby"},
+		{"#!/usr/bin/env\n", ""},
+		{"#!/usr/bin/awk -f\n", ""},
+		{"#!\n", ""},
+		{"echo hi\n#!/bin/sh\n", ""},
+	}
+	for _, tt := range tests {
+		if got := FromShebang(tt.code); got != tt.want {
+			t.Errorf("FromShebang(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"script.rb", "#!/usr/bin/env python3\n", "ruby"},
+		{"script", "#!/usr/bin/env python3\nprint('hi')\n", "python"},
+		{"", "package main\n\nfunc main() {}\n", "go"},
+		{"notes", "remember the milk", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.name, tt.code); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %q, want %q", tt.name, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestExtensionAndMediaType(t *testing.T) {
+	tests := []struct {
+		language  string
+		extension string
+		mediaType string
+	}{
+		{"go", ".go", "text/x-go"},
+		{"Python", ".py", "text/x-python"},
+		{"html", ".html", "text/plain"},
+		{"unknown", ".txt", "text/plain"},
+	}
+	for _, tt := range tests {
+		if got := Extension(tt.language); got != tt.extension {
+			t.Errorf("Extension(%q) = %q, want %q", tt.language, got, tt.extension)
+		}
+		if got := MediaType(tt.language); got != tt.mediaType {
+			t.Errorf("MediaType(%q) = %q, want %q", tt.language, got, tt.mediaType)
+		}
+	}
+}
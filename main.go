@@ -4,43 +4,95 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
+	"snippet-manager-go/config"
 	database "snippet-manager-go/database"
 	"snippet-manager-go/handlers"
 	"snippet-manager-go/middleware"
 )
 
+// storage is a Store whose schema is managed by migrations
+type storage interface {
+	database.Store
+	Migrator() *database.Migrator
+}
+
+// main starts the API server or runs a subcommand.
+//
+//	@title						Snippet Manager API
+//	@version					1.0
+//	@description				Store, search and organise code snippets. Requests work on the caller's own library, or on the library of a workspace the caller is a member of when its ID is sent in the X-Workspace-ID header; viewers of a workspace may only read it.
+//	@BasePath					/
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				"Bearer " followed by the token returned by /login or /token/refresh
 func main() {
-	store, err := database.NewPostgresStorage(
-		"localhost",
-		"5432",
-		"postgres",
-		"mysecretpassword",
-		"snippet_manager",
-	)
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Subcommands only touch the database and do not need the rest of the
+	// configuration to be valid
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		if err := cfg.Database.Validate(); err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		store, err := openStorage(cfg.Database)
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		defer store.Close()
+		if err := runMigrate(store.Migrator(), args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	store, err := openStorage(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
 	defer store.Close()
 
-	if err := store.Init(); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	if cfg.Database.AutoMigrate {
+		applied, err := store.Migrator().Up()
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		for _, mig := range applied {
+			log.Printf("Applied migration %04d_%s", mig.Version, mig.Name)
+		}
+	} else if err := checkSchema(store.Migrator()); err != nil {
+		log.Fatal(err)
 	}
 
-	snippetHandler := handlers.NewSnippetHandler(store)
-	userHandler := handlers.NewUserHandler(store)
+	tokens := middleware.NewTokenManager(cfg.Auth.JWTSecret, cfg.Auth.TokenTTL, store)
+	snippetHandler := handlers.NewSnippetHandler(store, cfg.Limits)
+	userHandler := handlers.NewUserHandler(store, tokens, cfg.Auth.RefreshTTL)
 
-	// Public routes
-	http.HandleFunc("/register", userHandler.Register)
-	http.HandleFunc("/login", userHandler.Login)
+	router := handlers.NewRouter(snippetHandler, userHandler, tokens)
 
-	// Protected routes
-	http.HandleFunc("/snippets/", middleware.JWTAuth(snippetHandler.HandleSnippet))
-	http.HandleFunc("/snippets", middleware.JWTAuth(snippetHandler.HandleSnippets))
-	http.HandleFunc("/tags/", middleware.JWTAuth(snippetHandler.HandleTags))
-	http.HandleFunc("/folders", middleware.JWTAuth(snippetHandler.HandleFolders))
-	http.HandleFunc("/folders/user/", middleware.JWTAuth(snippetHandler.HandleUserFolders))
+	fmt.Printf("Server starting on %s...\n", cfg.Server.Addr)
+	log.Fatal(http.ListenAndServe(cfg.Server.Addr, router))
+}
 
-	fmt.Println("Server starting on port 8080...")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+func openStorage(cfg config.Database) (storage, error) {
+	switch cfg.Driver {
+	case "postgres":
+		return database.NewPostgresStorage(cfg.DSN)
+	case "sqlite":
+		return database.NewSQLiteStorage(cfg.SQLitePath)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Driver)
+	}
 }
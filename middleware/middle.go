@@ -1,24 +1,69 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-var jwtKey = []byte("my_secret_key")
+type contextKey string
 
-// Claims struct used to store the JWT claims
+const (
+	userIDKey    contextKey = "user_id"
+	sessionIDKey contextKey = "session_id"
+)
+
+// Claims struct used to store the JWT claims. The registered ID claim (jti)
+// identifies the token so that it can be revoked.
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	SessionID uuid.UUID `json:"sid"`
 	jwt.RegisteredClaims
 }
 
+// Denylist reports whether an access token was revoked before its expiry
+type Denylist interface {
+	IsTokenRevoked(tokenID uuid.UUID) (bool, error)
+}
+
+// TokenManager issues and verifies the JWTs used for authentication
+type TokenManager struct {
+	key      []byte
+	ttl      time.Duration
+	denylist Denylist
+}
+
+func NewTokenManager(secret string, ttl time.Duration, denylist Denylist) *TokenManager {
+	return &TokenManager{key: []byte(secret), ttl: ttl, denylist: denylist}
+}
+
+// Issue signs an access token for a session of the given user and returns
+// it with its expiry. tokenID becomes its jti.
+func (m *TokenManager) Issue(userID uuid.UUID, username string, sessionID, tokenID uuid.UUID) (string, time.Time, error) {
+	expirationTime := time.Now().Add(m.ttl)
+	claims := &Claims{
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(m.key)
+	return tokenString, expirationTime, err
+}
+
 // JWTAuth middleware checks if a valid JWT token is present in the request
-func JWTAuth(next http.HandlerFunc) http.HandlerFunc {
+// and stores the authenticated user ID in the request context
+func (m *TokenManager) JWTAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -37,8 +82,9 @@ func JWTAuth(next http.HandlerFunc) http.HandlerFunc {
 			tokenString,
 			claims,
 			func(token *jwt.Token) (interface{}, error) {
-				return jwtKey, nil
+				return m.key, nil
 			},
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
 		)
 
 		if err != nil || !token.Valid {
@@ -46,7 +92,40 @@ func JWTAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Tokens issued before user IDs and sessions were added to the claims
+		// cannot be attributed to a user or revoked, and must be replaced by
+		// logging in again
+		tokenID, err := uuid.Parse(claims.ID)
+		if claims.UserID == uuid.Nil || claims.SessionID == uuid.Nil || err != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		revoked, err := m.denylist.IsTokenRevoked(tokenID)
+		if err != nil {
+			http.Error(w, "Failed to check token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			http.Error(w, "Token revoked", http.StatusUnauthorized)
+			return
+		}
+
 		// Token is valid, proceed to the next handler
-		next(w, r)
+		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, sessionIDKey, claims.SessionID)
+		next(w, r.WithContext(ctx))
 	}
 }
+
+// UserIDFromContext returns the ID of the user authenticated by JWTAuth
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(userIDKey).(uuid.UUID)
+	return userID, ok && userID != uuid.Nil
+}
+
+// SessionIDFromContext returns the ID of the session whose token JWTAuth
+// authenticated
+func SessionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey).(uuid.UUID)
+	return sessionID, ok && sessionID != uuid.Nil
+}
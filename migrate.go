@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	database "snippet-manager-go/database"
+)
+
+const migrateUsage = "usage: snippet-manager [flags] migrate up|down [n]|status"
+
+// runMigrate implements the migrate subcommand
+func runMigrate(m *database.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, mig := range applied {
+			fmt.Printf("Applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		rolledBack, err := m.Down(n)
+		for _, mig := range rolledBack {
+			fmt.Printf("Rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("No migrations to roll back")
+		}
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, st := range status {
+			state := "pending"
+			if st.AppliedAt != nil {
+				state = "applied " + st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", st.Version, st.Name, state)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
+
+// checkSchema refuses to serve requests against a database with pending
+// migrations when they are not applied automatically
+func checkSchema(m *database.Migrator) error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf(
+			"database schema is out of date (%d pending migrations); run \"snippet-manager migrate up\" or start with -auto-migrate",
+			len(pending),
+		)
+	}
+	return nil
+}
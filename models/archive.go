@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArchiveVersion is the version of the archive format written by exports.
+// Imports reject archives of other versions.
+const ArchiveVersion = 1
+
+// Archive is a user's library as exported: every folder and snippet, with
+// the tags of each snippet. It does not refer to the user, so it can be
+// imported into another account.
+type Archive struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Folders    []ArchiveFolder  `json:"folders"`
+	Snippets   []ArchiveSnippet `json:"snippets"`
+}
+
+type ArchiveFolder struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type ArchiveSnippet struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Language    string     `json:"language"`
+	Code        string     `json:"code"`
+	FolderID    *uuid.UUID `json:"folder_id"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ImportResult reports what an import did. IDs maps the archive IDs of
+// objects that were imported under a new ID to that ID.
+type ImportResult struct {
+	Folders  ImportCounts            `json:"folders"`
+	Snippets ImportCounts            `json:"snippets"`
+	IDs      map[uuid.UUID]uuid.UUID `json:"ids" swaggertype:"object,string"`
+}
+
+type ImportCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// DirectoryImportResult reports what an import of a directory of source
+// files did
+type DirectoryImportResult struct {
+	Folders      ImportCounts  `json:"folders"`
+	Snippets     ImportCounts  `json:"snippets"`
+	SkippedFiles []SkippedFile `json:"skipped_files"`
+}
+
+// SkippedFile is a file of a directory import that did not become a snippet
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
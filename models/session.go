@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login of a user on a device. Each session holds a refresh
+// token, exchanged for a new one along with a new access token whenever the
+// access token is about to expire, and lasts until the refresh token
+// expires or the user logs out.
+//
+// RefreshToken is only known when the session is created or rotated; the
+// store keeps a hash of it. TokenID is the jti of the last access token
+// issued for the session, revoked along with it.
+type Session struct {
+	ID             uuid.UUID `json:"id"`
+	UserID         uuid.UUID `json:"user_id"`
+	RefreshToken   string    `json:"-"`
+	TokenID        uuid.UUID `json:"-"`
+	TokenExpiresAt time.Time `json:"-"`
+	UserAgent      string    `json:"user_agent"`
+	IP             string    `json:"ip"`
+	// Current is set on the session of the request listing the sessions
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareLink gives anyone holding its token read access to a snippet or a
+// folder, at /s/{token}. Exactly one of SnippetID and FolderID is set.
+//
+// Token is only known when the link is created; the store keeps a hash of
+// it. Like User.Password, Password is the plain password when creating a
+// link and its hash when reading one from the store.
+type ShareLink struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	SnippetID   *uuid.UUID `json:"snippet_id,omitempty"`
+	FolderID    *uuid.UUID `json:"folder_id,omitempty"`
+	Token       string     `json:"token,omitempty"`
+	Password    string     `json:"password,omitempty"`
+	HasPassword bool       `json:"has_password"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	MaxViews    *int       `json:"max_views"`
+	Views       int        `json:"views"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Active reports whether the link can still be used at the given time
+func (l ShareLink) Active(now time.Time) bool {
+	if l.ExpiresAt != nil && !now.Before(*l.ExpiresAt) {
+		return false
+	}
+	return l.MaxViews == nil || l.Views < *l.MaxViews
+}
+
+// SharedSnippet is a snippet as shown through a share link, without the
+// IDs of its owner and folder
+type SharedSnippet struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Language    string    `json:"language"`
+	Code        string    `json:"code"`
+	Tags        []string  `json:"tags"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SharedFolder is a folder as shown through a share link, with everything
+// below it
+type SharedFolder struct {
+	Name     string          `json:"name"`
+	Snippets []SharedSnippet `json:"snippets"`
+	Folders  []SharedFolder  `json:"folders"`
+}
+
+// Permission is the access a folder share grants
+type Permission string
+
+const (
+	// PermissionRead lets the user read the folder and its snippets
+	PermissionRead Permission = "read"
+	// PermissionWrite also lets the user update the snippets
+	PermissionWrite Permission = "write"
+)
+
+// Valid reports whether p is one of the permissions above
+func (p Permission) Valid() bool {
+	return p == PermissionRead || p == PermissionWrite
+}
+
+// FolderShare grants a user access to a folder of another user and to
+// everything below it
+type FolderShare struct {
+	FolderID   uuid.UUID  `json:"folder_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Username   string     `json:"username"`
+	Permission Permission `json:"permission"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IncomingShare is a folder shared with the current user
+type IncomingShare struct {
+	Folder     Folder     `json:"folder"`
+	Owner      string     `json:"owner"`
+	Permission Permission `json:"permission"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -19,6 +19,27 @@ type Snippet struct {
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
+// Revision is a saved state of a snippet. Every create, update and restore
+// records one, as does every change to its tags, including renaming, merging
+// and deleting tags; revisions are numbered from 1 and never change.
+type Revision struct {
+	SnippetID   uuid.UUID `json:"snippet_id"`
+	Number      int       `json:"revision"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Language    string    `json:"language"`
+	Code        string    `json:"code"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SnippetPage is one page of a snippet listing. NextCursor is empty on the
+// last page.
+type SnippetPage struct {
+	Snippets   []Snippet `json:"snippets"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
 type Folder struct {
 	ID        uuid.UUID  `json:"id"`
 	Name      string     `json:"name"`
@@ -28,6 +49,31 @@ type Folder struct {
 	UpdatedAt time.Time  `json:"updated_at"`
 }
 
+// FolderContents is the direct content of a folder
+type FolderContents struct {
+	Snippets []Snippet `json:"snippets"`
+	Folders  []Folder  `json:"folders"`
+}
+
+// FolderNode is a folder along with its subfolders, as returned by the folder
+// tree. Depth is 0 for top-level folders and SnippetCount only counts the
+// snippets directly in the folder.
+type FolderNode struct {
+	Folder
+	Depth        int              `json:"depth"`
+	SnippetCount int              `json:"snippet_count"`
+	Snippets     []SnippetSummary `json:"snippets,omitempty"`
+	Children     []FolderNode     `json:"children"`
+}
+
+// SnippetSummary identifies a snippet without its content
+type SnippetSummary struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Language  string    `json:"language"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
@@ -36,3 +82,37 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// Tag is one of a user's tags along with the number of snippets carrying it
+type Tag struct {
+	Name         string `json:"name"`
+	SnippetCount int    `json:"snippet_count"`
+}
+
+// Language is a language snippets can be written in, along with the number
+// of the caller's snippets in it. Languages outside of the registry are not
+// Known and have no aliases or extensions.
+type Language struct {
+	Name         string   `json:"name"`
+	Aliases      []string `json:"aliases"`
+	Extensions   []string `json:"extensions"`
+	Known        bool     `json:"known"`
+	SnippetCount int      `json:"snippet_count"`
+}
+
+// SearchResult is a snippet matching a full-text search along with its
+// relevance and the matching fragments of each field
+type SearchResult struct {
+	Snippet    Snippet          `json:"snippet"`
+	Rank       float64          `json:"rank"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// SearchHighlights holds fragments of the fields that matched a search as
+// HTML: the text is escaped and matches are wrapped in <mark></mark>. Fields
+// without a match are empty.
+type SearchHighlights struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Code        string `json:"code,omitempty"`
+}
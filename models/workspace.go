@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role is what a member may do in a workspace
+type Role string
+
+const (
+	// RoleOwner edits the library and manages the members
+	RoleOwner Role = "owner"
+	// RoleEditor edits the library
+	RoleEditor Role = "editor"
+	// RoleViewer reads the library
+	RoleViewer Role = "viewer"
+)
+
+// Valid reports whether r is one of the roles above
+func (r Role) Valid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// CanEdit reports whether the role may change the library of a workspace
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// Workspace is a library of folders and snippets shared by its members.
+// The workspace owns them the way a user owns theirs: their UserID is the
+// ID of the workspace. Role is the role of the user who asked for it.
+type Workspace struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceMember is a user's membership of a workspace
+type WorkspaceMember struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package tui
+
+import (
+	"os"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+// copyText puts text on the clipboard and returns how it did so. Over SSH
+// the local clipboard is the wrong one, so the text is sent to the terminal
+// as an OSC 52 sequence instead, which most terminal emulators support.
+func copyText(text string) (string, error) {
+	if !overSSH() {
+		if err := clipboard.WriteAll(text); err == nil {
+			return "clipboard", nil
+		}
+	}
+
+	seq := osc52.New(text)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case os.Getenv("STY") != "":
+		seq = seq.Screen()
+	}
+	if _, err := seq.WriteTo(os.Stderr); err != nil {
+		return "", err
+	}
+	return "terminal (OSC 52)", nil
+}
+
+func overSSH() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
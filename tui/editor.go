@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"snippet-manager-go/client"
+	"snippet-manager-go/models"
+)
+
+// editedMsg carries a snippet whose code came back from the editor. The
+// snippet is new if its ID is nil.
+type editedMsg struct {
+	snippet models.Snippet
+	changed bool
+	err     error
+}
+
+// editCode suspends the program, opens the snippet's code in the user's
+// editor and resumes with an editedMsg
+func editCode(snippet models.Snippet) tea.Cmd {
+	f, err := os.CreateTemp("", "snippet-*"+extension(snippet.Language, snippet.Code))
+	if err != nil {
+		return errCmd(err)
+	}
+	path := f.Name()
+	_, err = f.WriteString(snippet.Code)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return errCmd(err)
+	}
+
+	cmd := client.EditorCommand(path)
+
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return editedMsg{err: fmt.Errorf("editor: %w", err)}
+		}
+		code, err := os.ReadFile(path)
+		if err != nil {
+			return editedMsg{err: err}
+		}
+		changed := string(code) != snippet.Code
+		snippet.Code = string(code)
+		return editedMsg{snippet: snippet, changed: changed}
+	})
+}
+
+// extension returns a file extension for the language, so that the editor
+// picks the right syntax highlighting
+func extension(language, code string) string {
+	for _, pattern := range lexerFor(language, code).Config().Filenames {
+		if ext := filepath.Ext(pattern); strings.HasPrefix(pattern, "*.") && !strings.ContainsAny(ext, "*[") {
+			return ext
+		}
+	}
+	return ".txt"
+}
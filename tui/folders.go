@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+
+	"snippet-manager-go/models"
+)
+
+// folderRow is one line of the folder pane. The first row has no ID and
+// stands for all snippets.
+type folderRow struct {
+	id    *uuid.UUID
+	name  string
+	depth int
+}
+
+// folderTree is the folder pane: the user's folders flattened in tree order
+// with a cursor
+type folderTree struct {
+	rows   []folderRow
+	cursor int
+}
+
+func (t *folderTree) set(folders []models.Folder) {
+	var selected *uuid.UUID
+	if len(t.rows) > 0 {
+		selected = t.selected()
+	}
+
+	children := make(map[uuid.UUID][]models.Folder)
+	var roots []models.Folder
+	for _, folder := range folders {
+		if folder.ParentID == nil {
+			roots = append(roots, folder)
+		} else {
+			children[*folder.ParentID] = append(children[*folder.ParentID], folder)
+		}
+	}
+
+	t.rows = []folderRow{{name: "All snippets"}}
+	var walk func(folders []models.Folder, depth int)
+	walk = func(folders []models.Folder, depth int) {
+		sort.Slice(folders, func(i, j int) bool {
+			return strings.ToLower(folders[i].Name) < strings.ToLower(folders[j].Name)
+		})
+		for _, folder := range folders {
+			id := folder.ID
+			t.rows = append(t.rows, folderRow{id: &id, name: folder.Name, depth: depth})
+			walk(children[folder.ID], depth+1)
+		}
+	}
+	walk(roots, 1)
+
+	// Keep the cursor on the same folder across reloads
+	t.cursor = 0
+	for i, row := range t.rows {
+		if row.id != nil && selected != nil && *row.id == *selected {
+			t.cursor = i
+		}
+	}
+}
+
+// selected returns the ID of the folder under the cursor, or nil for all
+// snippets
+func (t *folderTree) selected() *uuid.UUID {
+	return t.rows[t.cursor].id
+}
+
+// move moves the cursor by delta rows and reports whether it moved
+func (t *folderTree) move(delta int) bool {
+	cursor := min(max(t.cursor+delta, 0), len(t.rows)-1)
+	if cursor == t.cursor {
+		return false
+	}
+	t.cursor = cursor
+	return true
+}
+
+func (t *folderTree) view(width, height int, focused bool) string {
+	// Scroll so that the cursor stays visible
+	start := 0
+	if t.cursor >= height {
+		start = t.cursor - height + 1
+	}
+
+	var lines []string
+	for i := start; i < len(t.rows) && i < start+height; i++ {
+		row := t.rows[i]
+		line := strings.Repeat("  ", max(row.depth-1, 0)) + row.name
+		if row.id != nil {
+			line += "/"
+		}
+		style := itemStyle
+		if i == t.cursor {
+			style = cursorStyle
+			if !focused {
+				style = inactiveCursorStyle
+			}
+		}
+		lines = append(lines, style.Width(width).MaxWidth(width).Render(line))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
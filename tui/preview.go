@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+
+	"snippet-manager-go/models"
+)
+
+// lexerFor returns the lexer for a language name, falling back to guessing
+// from the code and then to plain text
+func lexerFor(language, code string) chroma.Lexer {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// highlight renders code with terminal colors, or returns it unchanged if
+// it cannot be tokenised
+func highlight(language, code string) string {
+	iterator, err := lexerFor(language, code).Tokenise(nil, code)
+	if err != nil {
+		return code
+	}
+	var b strings.Builder
+	if err := formatters.Get("terminal256").Format(&b, styles.Get("monokai"), iterator); err != nil {
+		return code
+	}
+	return b.String()
+}
+
+// previewContent is the text of the preview pane: a header with the
+// snippet's metadata followed by its highlighted code
+func previewContent(snippet models.Snippet) string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(snippet.Title))
+	b.WriteString("\n")
+	if snippet.Description != "" {
+		b.WriteString(snippet.Description)
+		b.WriteString("\n")
+	}
+	meta := snippet.Language
+	if len(snippet.Tags) > 0 {
+		meta += " · " + strings.Join(snippet.Tags, ", ")
+	}
+	b.WriteString(dimStyle.Render(meta))
+	b.WriteString("\n\n")
+	b.WriteString(strings.TrimRight(highlight(snippet.Language, snippet.Code), "\n"))
+	return b.String()
+}
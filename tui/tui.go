@@ -0,0 +1,509 @@
+// Package tui is a terminal user interface for the snippet manager API. It
+// shows the user's folders, a filterable list of the snippets in the selected
+// folder and a highlighted preview of the selected snippet.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/google/uuid"
+
+	"snippet-manager-go/client"
+	"snippet-manager-go/models"
+)
+
+const (
+	folderPaneWidth = 28
+	helpText        = "tab focus · / filter · y copy · n new · e edit · t tags · d delete · r reload · q quit"
+)
+
+var (
+	borderStyle         = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("240"))
+	focusedBorderStyle  = borderStyle.BorderForeground(lipgloss.Color("63"))
+	itemStyle           = lipgloss.NewStyle()
+	cursorStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Background(lipgloss.Color("63"))
+	inactiveCursorStyle = lipgloss.NewStyle().Background(lipgloss.Color("238"))
+	titleStyle          = lipgloss.NewStyle().Bold(true)
+	dimStyle            = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	errorStyle          = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+)
+
+// Run starts the interface for the user the client's token belongs to and
+// blocks until it is closed
+func Run(c *client.Client, userID uuid.UUID) error {
+	_, err := tea.NewProgram(newModel(c, userID), tea.WithAltScreen()).Run()
+	return err
+}
+
+type pane int
+
+const (
+	foldersPane pane = iota
+	snippetsPane
+	previewPane
+)
+
+// snippetItem adapts a snippet to the list. The filter matches the title,
+// language and tags.
+type snippetItem struct {
+	snippet models.Snippet
+}
+
+func (i snippetItem) Title() string { return i.snippet.Title }
+
+func (i snippetItem) Description() string {
+	if len(i.snippet.Tags) == 0 {
+		return i.snippet.Language
+	}
+	return i.snippet.Language + " · " + strings.Join(i.snippet.Tags, ", ")
+}
+
+func (i snippetItem) FilterValue() string {
+	return i.snippet.Title + " " + i.snippet.Language + " " + strings.Join(i.snippet.Tags, " ")
+}
+
+// prompt is a one line question shown in place of the status line. submit
+// is called with the answer; escape cancels.
+type prompt struct {
+	input  textinput.Model
+	submit func(m *model, answer string) tea.Cmd
+}
+
+type (
+	foldersMsg  []models.Folder
+	snippetsMsg struct {
+		folderID *uuid.UUID
+		snippets []models.Snippet
+	}
+	// savedMsg reports a finished change; the list is reloaded after it
+	savedMsg string
+	errMsg   struct{ err error }
+)
+
+type model struct {
+	client *client.Client
+	userID uuid.UUID
+
+	width, height int
+	focus         pane
+	folders       folderTree
+	snippets      list.Model
+	preview       viewport.Model
+	// previewID is the snippet shown in the preview
+	previewID uuid.UUID
+
+	prompt *prompt
+	// confirm is set while waiting for y/n before deleting a snippet
+	confirm *models.Snippet
+	status  string
+	failed  bool
+}
+
+func newModel(c *client.Client, userID uuid.UUID) *model {
+	snippets := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	snippets.Title = "Snippets"
+	snippets.SetShowHelp(false)
+	snippets.DisableQuitKeybindings()
+
+	m := &model{
+		client:   c,
+		userID:   userID,
+		focus:    snippetsPane,
+		snippets: snippets,
+		preview:  viewport.New(0, 0),
+	}
+	m.folders.set(nil)
+	return m
+}
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(m.loadFolders(), m.loadSnippets())
+}
+
+func (m *model) loadFolders() tea.Cmd {
+	c, userID := m.client, m.userID
+	return func() tea.Msg {
+		folders, err := c.Folders(userID)
+		if err != nil {
+			return errMsg{err}
+		}
+		return foldersMsg(folders)
+	}
+}
+
+func (m *model) loadSnippets() tea.Cmd {
+	c, folderID := m.client, m.folders.selected()
+	return func() tea.Msg {
+		snippets, err := c.AllSnippets(client.ListParams{FolderID: folderID, Sort: "title"})
+		if err != nil {
+			return errMsg{err}
+		}
+		return snippetsMsg{folderID: folderID, snippets: snippets}
+	}
+}
+
+func (m *model) selected() (models.Snippet, bool) {
+	item, ok := m.snippets.SelectedItem().(snippetItem)
+	return item.snippet, ok
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
+		return m, nil
+
+	case foldersMsg:
+		m.folders.set(msg)
+		return m, nil
+
+	case snippetsMsg:
+		if !sameFolder(msg.folderID, m.folders.selected()) {
+			// The cursor moved on while this folder was loading
+			return m, nil
+		}
+		items := make([]list.Item, len(msg.snippets))
+		for i, snippet := range msg.snippets {
+			items[i] = snippetItem{snippet}
+		}
+		cmd := m.snippets.SetItems(items)
+		m.updatePreview(true)
+		return m, cmd
+
+	case savedMsg:
+		m.setStatus(string(msg), false)
+		return m, tea.Batch(m.loadFolders(), m.loadSnippets())
+
+	case errMsg:
+		err := msg.err
+		if client.IsStatus(err, http.StatusUnauthorized) {
+			err = errors.New("not logged in or the session expired; run snippet login")
+		}
+		m.setStatus(err.Error(), true)
+		return m, nil
+
+	case editedMsg:
+		return m, m.saveEdited(msg)
+
+	case tea.KeyMsg:
+		if m.prompt != nil {
+			return m, m.updatePrompt(msg)
+		}
+		if m.confirm != nil {
+			snippet := *m.confirm
+			m.confirm = nil
+			if msg.String() != "y" {
+				m.setStatus("", false)
+				return m, nil
+			}
+			return m, m.deleteSnippet(snippet)
+		}
+		// While the filter is being typed every key belongs to the list
+		if m.focus == snippetsPane && m.snippets.SettingFilter() {
+			return m, m.updateSnippets(msg)
+		}
+		if cmd, ok := m.handleKey(msg); ok {
+			return m, cmd
+		}
+	}
+
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		// The list filters in the background and reports back with messages
+		return m, m.updateSnippets(msg)
+	}
+	switch m.focus {
+	case foldersPane:
+		return m, m.updateFolders(key)
+	case previewPane:
+		var cmd tea.Cmd
+		m.preview, cmd = m.preview.Update(key)
+		return m, cmd
+	default:
+		return m, m.updateSnippets(key)
+	}
+}
+
+// handleKey handles the global keybindings and reports whether key was one
+func (m *model) handleKey(key tea.KeyMsg) (tea.Cmd, bool) {
+	switch key.String() {
+	case "ctrl+c", "q":
+		return tea.Quit, true
+	case "tab":
+		m.focus = (m.focus + 1) % 3
+		return nil, true
+	case "shift+tab":
+		m.focus = (m.focus + 2) % 3
+		return nil, true
+	case "r":
+		m.setStatus("Reloading…", false)
+		return tea.Batch(m.loadFolders(), m.loadSnippets()), true
+	case "n":
+		return m.newSnippet(), true
+	}
+
+	snippet, ok := m.selected()
+	if !ok {
+		return nil, false
+	}
+	switch key.String() {
+	case "y", "c":
+		via, err := copyText(snippet.Code)
+		if err != nil {
+			m.setStatus("Copy failed: "+err.Error(), true)
+		} else {
+			m.setStatus(fmt.Sprintf("Copied %q to the %s", snippet.Title, via), false)
+		}
+		return nil, true
+	case "e":
+		return editCode(snippet), true
+	case "t":
+		m.ask("Tags (comma separated): ", strings.Join(snippet.Tags, ", "), func(m *model, answer string) tea.Cmd {
+			snippet.Tags = splitTags(answer)
+			return m.update(snippet, "Tags of %q saved")
+		})
+		return nil, true
+	case "d":
+		m.confirm = &snippet
+		m.setStatus(fmt.Sprintf("Delete %q? (y/n)", snippet.Title), false)
+		return nil, true
+	}
+	return nil, false
+}
+
+func (m *model) updateFolders(key tea.KeyMsg) tea.Cmd {
+	moved := false
+	switch key.String() {
+	case "up", "k":
+		moved = m.folders.move(-1)
+	case "down", "j":
+		moved = m.folders.move(1)
+	case "home", "g":
+		moved = m.folders.move(-len(m.folders.rows))
+	case "end", "G":
+		moved = m.folders.move(len(m.folders.rows))
+	case "enter", "right", "l":
+		m.focus = snippetsPane
+	}
+	if !moved {
+		return nil
+	}
+	m.snippets.ResetFilter()
+	return m.loadSnippets()
+}
+
+func (m *model) updateSnippets(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	m.snippets, cmd = m.snippets.Update(msg)
+	m.updatePreview(false)
+	return cmd
+}
+
+// updatePreview shows the selected snippet in the preview, scrolled to the
+// top if it changed. force redraws it even if the same snippet is selected,
+// after it may have been modified.
+func (m *model) updatePreview(force bool) {
+	snippet, ok := m.selected()
+	if !ok {
+		m.previewID = uuid.Nil
+		m.preview.SetContent(dimStyle.Render("No snippets"))
+		return
+	}
+	if snippet.ID == m.previewID && !force {
+		return
+	}
+	if snippet.ID != m.previewID {
+		m.preview.GotoTop()
+	}
+	m.previewID = snippet.ID
+	m.renderPreview(snippet)
+}
+
+func (m *model) renderPreview(snippet models.Snippet) {
+	// Cut long lines rather than wrapping them, as wrapped code reads badly
+	lines := strings.Split(previewContent(snippet), "\n")
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, m.preview.Width, "…")
+	}
+	m.preview.SetContent(strings.Join(lines, "\n"))
+}
+
+func (m *model) resize() {
+	// Each pane has a border of one cell on every side; the last line is
+	// the status line
+	height := max(m.height-3, 1)
+	rest := max(m.width-folderPaneWidth-2, 0)
+	listWidth := rest * 2 / 5
+	m.snippets.SetSize(max(listWidth-2, 1), height)
+	m.preview.Width = max(rest-listWidth-2, 1)
+	m.preview.Height = height
+	if snippet, ok := m.selected(); ok {
+		m.renderPreview(snippet)
+	}
+}
+
+func (m *model) setStatus(status string, failed bool) {
+	m.status = status
+	m.failed = failed
+}
+
+func (m *model) ask(question, value string, submit func(m *model, answer string) tea.Cmd) {
+	input := textinput.New()
+	input.Prompt = question
+	input.SetValue(value)
+	input.Focus()
+	m.prompt = &prompt{input: input, submit: submit}
+}
+
+func (m *model) updatePrompt(key tea.KeyMsg) tea.Cmd {
+	switch key.String() {
+	case "esc", "ctrl+c":
+		m.prompt = nil
+		m.setStatus("", false)
+		return nil
+	case "enter":
+		p := m.prompt
+		m.prompt = nil
+		return p.submit(m, strings.TrimSpace(p.input.Value()))
+	}
+	var cmd tea.Cmd
+	m.prompt.input, cmd = m.prompt.input.Update(key)
+	return cmd
+}
+
+// newSnippet asks for the title, language and tags of a new snippet, then
+// opens the editor for its code. It is created in the selected folder.
+func (m *model) newSnippet() tea.Cmd {
+	snippet := models.Snippet{FolderID: m.folders.selected()}
+	m.ask("Title: ", "", func(m *model, title string) tea.Cmd {
+		if title == "" {
+			m.setStatus("A title is required", true)
+			return nil
+		}
+		snippet.Title = title
+		m.ask("Language: ", "", func(m *model, language string) tea.Cmd {
+			if language == "" {
+				m.setStatus("A language is required", true)
+				return nil
+			}
+			snippet.Language = language
+			m.ask("Tags (comma separated): ", "", func(m *model, tags string) tea.Cmd {
+				snippet.Tags = splitTags(tags)
+				return editCode(snippet)
+			})
+			return nil
+		})
+		return nil
+	})
+	return nil
+}
+
+func (m *model) saveEdited(msg editedMsg) tea.Cmd {
+	if msg.err != nil {
+		return errCmd(msg.err)
+	}
+	snippet := msg.snippet
+	if snippet.ID == uuid.Nil {
+		if strings.TrimSpace(snippet.Code) == "" {
+			m.setStatus("Empty snippet discarded", false)
+			return nil
+		}
+		c := m.client
+		return func() tea.Msg {
+			created, err := c.CreateSnippet(snippet)
+			if err != nil {
+				return errMsg{err}
+			}
+			return savedMsg(fmt.Sprintf("Created %q", created.Title))
+		}
+	}
+	if !msg.changed {
+		m.setStatus("No changes", false)
+		return nil
+	}
+	return m.update(snippet, "Saved %q")
+}
+
+// update saves snippet and reports status, formatted with its title
+func (m *model) update(snippet models.Snippet, status string) tea.Cmd {
+	c := m.client
+	return func() tea.Msg {
+		if _, err := c.UpdateSnippet(snippet); err != nil {
+			return errMsg{err}
+		}
+		return savedMsg(fmt.Sprintf(status, snippet.Title))
+	}
+}
+
+func (m *model) deleteSnippet(snippet models.Snippet) tea.Cmd {
+	c := m.client
+	return func() tea.Msg {
+		if err := c.DeleteSnippet(snippet.ID); err != nil {
+			return errMsg{err}
+		}
+		return savedMsg(fmt.Sprintf("Deleted %q", snippet.Title))
+	}
+}
+
+func (m *model) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	height := max(m.height-3, 1)
+
+	paneStyle := func(p pane) lipgloss.Style {
+		if m.focus == p {
+			return focusedBorderStyle
+		}
+		return borderStyle
+	}
+	folders := paneStyle(foldersPane).Width(folderPaneWidth - 2).Height(height).
+		Render(m.folders.view(folderPaneWidth-2, height, m.focus == foldersPane))
+	snippets := paneStyle(snippetsPane).Width(m.snippets.Width()).Height(height).Render(m.snippets.View())
+	preview := paneStyle(previewPane).Width(m.preview.Width).Height(height).Render(m.preview.View())
+	panes := lipgloss.JoinHorizontal(lipgloss.Top, folders, snippets, preview)
+
+	var status string
+	switch {
+	case m.prompt != nil:
+		status = m.prompt.input.View()
+	case m.failed:
+		status = errorStyle.Render(m.status)
+	case m.status != "":
+		status = m.status
+	default:
+		status = dimStyle.Render(helpText)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, panes, ansi.Truncate(status, m.width, "…"))
+}
+
+func errCmd(err error) tea.Cmd {
+	return func() tea.Msg { return errMsg{err} }
+}
+
+func sameFolder(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}